@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/Arh0rn/test-task1/internal/app"
 	"log"
+	"os"
+	_ "time/tzdata" // profile timezone validation works in images without zoneinfo
 )
 
 // @title                      test-task1
@@ -17,6 +19,14 @@ import (
 // @name                       Authorization
 func main() {
 	ctx := context.Background()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := app.RunMigrate(ctx, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	a, err := app.NewApp(ctx)
 	if err != nil {
 		log.Fatal(err)
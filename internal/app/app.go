@@ -2,43 +2,71 @@ package app
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
-	redisUsersCache "github.com/Arh0rn/test-task1/internal/cache/redis/users"
+	redisLock "github.com/Arh0rn/test-task1/internal/cache/redis/lock"
+	redisSessionsCache "github.com/Arh0rn/test-task1/internal/cache/redis/sessions"
 	"github.com/Arh0rn/test-task1/internal/controller/restapi"
+	groupsController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/groups"
+	healthController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/health"
+	orgsController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/orgs"
+	sessionsController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/sessions"
 	usersController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users"
 	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/metrics"
+	postgresAuditRepo "github.com/Arh0rn/test-task1/internal/repository/postgres/audit"
+	postgresGroupsRepo "github.com/Arh0rn/test-task1/internal/repository/postgres/groups"
+	postgresImportsRepo "github.com/Arh0rn/test-task1/internal/repository/postgres/imports"
+	postgresInvitationsRepo "github.com/Arh0rn/test-task1/internal/repository/postgres/invitations"
+	postgresOrgsRepo "github.com/Arh0rn/test-task1/internal/repository/postgres/orgs"
+	postgresSessionsRepo "github.com/Arh0rn/test-task1/internal/repository/postgres/sessions"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/tenant"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
 	postgresUsersRepo "github.com/Arh0rn/test-task1/internal/repository/postgres/users"
+	accessService "github.com/Arh0rn/test-task1/internal/service/access"
+	healthService "github.com/Arh0rn/test-task1/internal/service/health"
+	orgsService "github.com/Arh0rn/test-task1/internal/service/orgs"
+	sessionsService "github.com/Arh0rn/test-task1/internal/service/sessions"
 	usersService "github.com/Arh0rn/test-task1/internal/service/users"
+	"github.com/Arh0rn/test-task1/internal/tracing"
 	"github.com/Arh0rn/test-task1/pkg/config"
 	"github.com/Arh0rn/test-task1/pkg/hash"
 	"github.com/Arh0rn/test-task1/pkg/logger"
+	"github.com/Arh0rn/test-task1/pkg/metadata"
 	"github.com/Arh0rn/test-task1/pkg/validate"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 type App struct {
-	cfg *config.Config
-	ctx context.Context
-	log *slog.Logger
+	cfg    *config.Config
+	ctx    context.Context
+	cancel context.CancelFunc // stops background listeners
+	log    *slog.Logger
 
-	db        *sql.DB
+	shutdownTracing func(context.Context) error // flushes spans
+
+	db        *databases.Cluster
+	cache     *userCache
 	hasher    *hash.Hasher
 	validator *validator.Validate
 
+	health         *healthService.HealthService
 	userRepo       *postgresUsersRepo.UserRepository
 	userService    *usersService.UserService
 	userController *usersController.UserController
 
-	handler *restapi.Handler
-	router  *http.Handler
-	server  *http.Server
+	handler       *restapi.Handler
+	router        *http.Handler
+	server        *http.Server
+	metricsServer *http.Server // nil when /metrics is on the main server
 }
 
 func NewApp(ctx context.Context) (*App, error) {
@@ -51,15 +79,33 @@ func NewApp(ctx context.Context) (*App, error) {
 	slog.SetDefault(log) //No need to inject logger to every layer ^_^
 
 	log.Debug(fmt.Sprintf("%+v", cfg))
-	db, err := databases.NewPostgresConnection(&cfg.Database)
+	shutdownTracing, err := tracing.Init(ctx, &cfg.Tracing)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to set up tracing", "error", err)
+		return nil, err
+	}
+	if cfg.Database.AutoMigrate {
+		if err := autoMigrate(ctx, &cfg.Database); err != nil {
+			slog.ErrorContext(ctx, "Failed to migrate database", "error", err)
+			return nil, err
+		}
+	}
+
+	primary, err := databases.NewPostgresPool(ctx, &cfg.Database, poolHooks())
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to connect to database", "error", err)
 		return nil, err
 	}
+	return newApp(ctx, cfg, log, shutdownTracing, primary)
+}
 
-	cache, err := databases.NewRedisClient(&cfg.Cache)
+// newApp wires everything on top of the connected primary, replicas are set up like it.
+func newApp(ctx context.Context, cfg *config.Config, log *slog.Logger, shutdownTracing func(context.Context) error,
+	primary *pgxpool.Pool) (*App, error) {
+	db, err := databases.NewCluster(ctx, primary, &cfg.Database.Replicas)
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed to connect to Redis", "error", err)
+		slog.ErrorContext(ctx, "Failed to set up read replicas", "error", err)
+		primary.Close()
 		return nil, err
 	}
 
@@ -69,11 +115,81 @@ func NewApp(ctx context.Context) (*App, error) {
 	jwtSecret := []byte(cfg.JWTSecret)
 	atttl := cfg.AccessTokenTTL
 
+	ctx, cancel := context.WithCancel(ctx)
+	go db.RunHealthChecks(ctx)
+
+	m := metrics.New()
+	m.Register(metrics.NewPoolCollector(db.Pools))
+
+	userCache, err := newUserCache(ctx, &cfg.Cache, m)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	txManager, err := transactor.New(db.Primary(), cfg.Database.Tx.Isolation, cfg.Database.Tx.MaxRetries)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 	userRepository := postgresUsersRepo.New(db)
-	userCache := redisUsersCache.New(cache, cfg.Cache.TTL)
-	userService := usersService.New(userRepository, userCache, hasher, v, jwtSecret, atttl)
-	userController := usersController.New(userService)
-	handler := restapi.NewHandler(userController)
+	orgRepository := postgresOrgsRepo.New(db)
+	groupRepository := postgresGroupsRepo.New(db)
+	invitationRepository := postgresInvitationsRepo.New(db)
+	sessionRepository := postgresSessionsRepo.New(db)
+	auditRepository := postgresAuditRepo.New(db)
+	importJobRepository := postgresImportsRepo.New(db)
+	metadataValidator, err := metadata.NewValidator(cfg.Profile.MetadataSchema, cfg.Profile.MetadataMaxBytes)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load metadata schema", "error", err)
+		cancel()
+		return nil, err
+	}
+	blobStore, err := newBlobStore(ctx, &cfg.BlobStore)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to set up blob store", "error", err)
+		cancel()
+		return nil, err
+	}
+	notifier, err := newNotifier(ctx, &cfg.Notifier)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to set up notifier", "error", err)
+		cancel()
+		return nil, err
+	}
+	sessions := sessionsService.New(sessionRepository, jwtSecret, atttl, cfg.RefreshTokenTTL).
+		WithCheckCache(cfg.Sessions.CheckCacheSize, cfg.Sessions.CheckInterval)
+	if userCache.client != nil {
+		sessions.WithRevocations(redisSessionsCache.New(userCache.client))
+		go sessions.ListenRevocations(ctx)
+	}
+	access := accessService.New(groupRepository, newPermissionCache(&cfg.Cache, userCache), txManager, v)
+	userService := usersService.New(userRepository, orgRepository, userCache, txManager, hasher, v, jwtSecret, atttl).
+		WithImport(importJobRepository, cfg.Import.BatchSize, cfg.Import.JobRetention).
+		WithMetadataValidator(metadataValidator).
+		WithAvatars(blobStore, avatarOptions(&cfg.Avatar)).
+		WithPermissionInvalidator(access).
+		WithInvitations(invitationRepository, notifier, invitationOptions(&cfg.SignUp)).
+		WithOpenSignUp(cfg.SignUp.Open).
+		WithSessions(sessions).
+		WithLoginRecorder(m).
+		WithAuditLog(auditRepository).
+		WithImpersonation(cfg.ImpersonationTTL).
+		WithAccountDeletion(cfg.Account.DeletionGrace)
+	if ll := cfg.Cache.LoadLock; ll.Enabled && userCache.client != nil {
+		userService.WithLoadLock(redisLock.New(userCache.client), ll.TTL, ll.RefreshBeta)
+	}
+	go userService.RunDeletions(ctx, cfg.Account.PurgeInterval)
+	orgService := orgsService.New(orgRepository, userCache, txManager, v, jwtSecret, atttl).
+		WithPermissionInvalidator(access).
+		WithSessions(sessions)
+	userController := usersController.New(userService, access, &cfg.Import, &cfg.Avatar)
+	orgController := orgsController.New(orgService)
+	groupController := groupsController.New(access)
+	sessionController := sessionsController.New(sessions)
+	health := newHealthService(&cfg.Health, db, userCache)
+	healthCtrl := healthController.New(health)
+	handler := restapi.NewHandler(userController, orgController, groupController, sessionController, healthCtrl, access, sessions, m)
 	router := handler.InitRoutes(&cfg.HTTPServer)
 
 	srv := &http.Server{
@@ -83,20 +199,36 @@ func NewApp(ctx context.Context) (*App, error) {
 		WriteTimeout: cfg.HTTPServer.WriteTimeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
+	var metricsSrv *http.Server
+	if cfg.MetricsAddress != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", m.Handler())
+		metricsSrv = &http.Server{
+			Addr:        cfg.MetricsAddress,
+			Handler:     metricsMux,
+			ReadTimeout: cfg.HTTPServer.ReadTimeout,
+			IdleTimeout: cfg.HTTPServer.IdleTimeout,
+		}
+	}
 
 	app := &App{
-		cfg:            cfg,
-		ctx:            ctx,
-		log:            log,
-		db:             db,
-		hasher:         hasher,
-		validator:      v,
-		userRepo:       userRepository,
-		userService:    userService,
-		userController: userController,
-		handler:        handler,
-		router:         router,
-		server:         srv,
+		cfg:             cfg,
+		ctx:             ctx,
+		cancel:          cancel,
+		log:             log,
+		shutdownTracing: shutdownTracing,
+		db:              db,
+		cache:           userCache,
+		hasher:          hasher,
+		validator:       v,
+		health:          health,
+		userRepo:        userRepository,
+		userService:     userService,
+		userController:  userController,
+		handler:         handler,
+		router:          router,
+		server:          srv,
+		metricsServer:   metricsSrv,
 	}
 
 	return app, nil
@@ -112,20 +244,80 @@ func (a *App) Run() error {
 			a.log.Error("Server starting error", "error", err)
 		}
 	}()
+	if a.metricsServer != nil {
+		go func() {
+			a.log.Info("Starting metrics server", "address", a.cfg.HTTPServer.MetricsAddress)
+			if err := a.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.log.Error("Metrics server starting error", "error", err)
+			}
+		}()
+	}
 	<-quit
+	a.shutdown()
+	return nil
+}
+
+// shutdown reports not ready, drains the servers and closes the connections.
+func (a *App) shutdown() {
 	a.log.Info("Shutting down server...")
 
+	a.health.Shutdown()
+	if d := a.cfg.Health.ShutdownDelay; d > 0 {
+		a.log.Info("Waiting for load balancer to stop sending requests", "delay", d.String())
+		time.Sleep(d)
+	}
+
 	ctx, cancel := context.WithTimeout(a.ctx, a.cfg.HTTPServer.ShutdownTimeout)
 	defer cancel()
 
 	if err := a.server.Shutdown(ctx); err != nil {
 		a.log.Error("Server shutdown error", "error", err)
 	}
+	if a.metricsServer != nil {
+		if err := a.metricsServer.Shutdown(ctx); err != nil {
+			a.log.Error("Metrics server shutdown error", "error", err)
+		}
+	}
+
+	a.cancel()
 
-	if err := a.db.Close(); err != nil {
-		a.log.Error("Database connection close error", "error", err)
+	// last spans of the drained requests
+	if err := a.shutdownTracing(ctx); err != nil {
+		a.log.Error("Tracing shutdown error", "error", err)
+	}
+
+	a.db.Close()
+
+	if a.cache.client != nil {
+		if err := a.cache.client.Close(); err != nil {
+			a.log.Error("Redis connection close error", "error", err)
+		}
 	}
 
 	a.log.Info("Server exited gracefully")
-	return nil
+}
+
+// poolHooks prepare statements of all repositories and scope connections to the request's organization.
+func poolHooks() databases.PoolHooks {
+	scope := tenant.New()
+	return databases.PoolHooks{
+		AfterConnect: func(ctx context.Context, conn *pgx.Conn) error {
+			for _, prepare := range []func(context.Context, *pgx.Conn) error{
+				postgresUsersRepo.PrepareStatements,
+				postgresOrgsRepo.PrepareStatements,
+				postgresGroupsRepo.PrepareStatements,
+				postgresInvitationsRepo.PrepareStatements,
+				postgresSessionsRepo.PrepareStatements,
+				postgresAuditRepo.PrepareStatements,
+				postgresImportsRepo.PrepareStatements,
+			} {
+				if err := prepare(ctx, conn); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		BeforeAcquire: scope.BeforeAcquire,
+		BeforeClose:   scope.BeforeClose,
+	}
 }
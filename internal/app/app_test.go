@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+// testApp is wired on a pool that never connects, nothing here needs the database.
+func testApp(t *testing.T) (*App, *bool) {
+	t.Helper()
+	t.Setenv("HASH_COST", "4")
+	t.Setenv("JWT_SECRET", "secret")
+	var cfg config.Config
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.HTTPServer.Address = "127.0.0.1:0"
+	cfg.Cache.Enabled = false
+	cfg.BlobStore.Dir = t.TempDir()
+
+	ctx := context.Background()
+	primary, err := pgxpool.New(ctx, "host=127.0.0.1 port=1 dbname=test sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracingFlushed := false
+	shutdownTracing := func(context.Context) error {
+		tracingFlushed = true
+		return nil
+	}
+	a, err := newApp(ctx, &cfg, slog.Default(), shutdownTracing, primary)
+	if err != nil {
+		t.Fatalf("newApp() error = %v", err)
+	}
+	return a, &tracingFlushed
+}
+
+func TestShutdown(t *testing.T) {
+	a, tracingFlushed := testApp(t)
+
+	a.shutdown()
+
+	if got := a.health.Ready(context.Background()).Status; got != domain.HealthShutdown {
+		t.Errorf("readiness = %s, want %s", got, domain.HealthShutdown)
+	}
+	if err := a.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("server accepts connections after shutdown, ListenAndServe() = %v", err)
+	}
+	if a.ctx.Err() == nil {
+		t.Error("background listeners are still running")
+	}
+	if !*tracingFlushed {
+		t.Error("tracing was not flushed")
+	}
+	if err := a.db.Primary().Ping(context.Background()); err == nil {
+		t.Error("database pool is still open")
+	}
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	fsBlobStore "github.com/Arh0rn/test-task1/internal/blobstore/fs"
+	s3BlobStore "github.com/Arh0rn/test-task1/internal/blobstore/s3"
+	usersService "github.com/Arh0rn/test-task1/internal/service/users"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"log/slog"
+)
+
+func newBlobStore(ctx context.Context, cfg *config.BlobStore) (usersService.BlobStore, error) {
+	switch cfg.Driver {
+	case "fs":
+		slog.InfoContext(ctx, "Storing blobs on disk", "dir", cfg.Dir)
+		return fsBlobStore.New(cfg.Dir)
+	case "s3":
+		slog.InfoContext(ctx, "Storing blobs in s3", "endpoint", cfg.S3.Endpoint, "bucket", cfg.S3.Bucket)
+		return s3BlobStore.New(&cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob store driver %q", cfg.Driver)
+	}
+}
+
+func avatarOptions(cfg *config.Avatar) usersService.AvatarOptions {
+	return usersService.AvatarOptions{
+		OriginalSize: cfg.OriginalSize,
+		Sizes:        cfg.Sizes,
+		Quality:      cfg.Quality,
+		MaxPixels:    cfg.MaxPixels,
+		PublicURL:    cfg.PublicURL,
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	breakerUsersCache "github.com/Arh0rn/test-task1/internal/cache/breaker/users"
+	metricsUsersCache "github.com/Arh0rn/test-task1/internal/cache/metrics/users"
+	noopUsersCache "github.com/Arh0rn/test-task1/internal/cache/noop/users"
+	redisPermissionsCache "github.com/Arh0rn/test-task1/internal/cache/redis/permissions"
+	redisUsersCache "github.com/Arh0rn/test-task1/internal/cache/redis/users"
+	tieredUsersCache "github.com/Arh0rn/test-task1/internal/cache/tiered/users"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/metrics"
+	accessService "github.com/Arh0rn/test-task1/internal/service/access"
+	usersService "github.com/Arh0rn/test-task1/internal/service/users"
+	"github.com/Arh0rn/test-task1/pkg/breaker"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"github.com/Arh0rn/test-task1/pkg/encrypt"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"time"
+)
+
+type userCache struct {
+	usersService.UserCache
+	client  *redis.Client    // nil when cache is disabled
+	breaker *breaker.Breaker // nil when cache is disabled
+}
+
+// newUserCache builds redis -> metrics -> breaker -> optional local tier.
+// Redis being down at start is not fatal, breaker keeps us on postgres until it is back
+// and legacy keys are migrated then.
+// Legacy keys that can neither be migrated nor deleted fail the start, they hold password hashes.
+func newUserCache(ctx context.Context, cfg *config.Cache, m *metrics.Metrics) (*userCache, error) {
+	if !cfg.Enabled {
+		slog.InfoContext(ctx, "Cache is disabled")
+		return &userCache{UserCache: noopUsersCache.New()}, nil
+	}
+
+	var encryptor *encrypt.Encryptor
+	if cfg.EncryptionKey != "" {
+		var err error
+		encryptor, err = encrypt.NewFromBase64(cfg.EncryptionKey)
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid cache encryption key", "error", err)
+			return nil, err
+		}
+	}
+
+	client, err := databases.NewRedisClient(cfg)
+	if err != nil {
+		slog.WarnContext(ctx, "Redis is not available, serving from database until it recovers", "error", err)
+	}
+
+	redisCache := redisUsersCache.New(client, cfg.TTL, cfg.ListTTL, encryptor)
+	if err == nil {
+		// Old keys hold password hashes, get rid of them before serving anything
+		n, err := redisCache.MigrateLegacyKeys(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to migrate legacy cache keys", "error", err)
+			client.Close()
+			return nil, err
+		}
+		if n > 0 {
+			slog.InfoContext(ctx, "Legacy cache keys migrated", "count", n)
+		}
+	} else {
+		slog.WarnContext(ctx, "Legacy cache keys are not migrated, retrying until redis is back")
+		go retryLegacyMigration(ctx, redisCache, cfg.Breaker.OpenTimeout)
+	}
+
+	b := breaker.New("redis", cfg.Breaker.Threshold, cfg.Breaker.OpenTimeout).
+		OnStateChange(func(_, to breaker.State) { m.BreakerState("redis", to) })
+	m.BreakerState("redis", b.State())
+	uc := &userCache{
+		UserCache: breakerUsersCache.New(metricsUsersCache.New(redisCache, m), b),
+		client:    client,
+		breaker:   b,
+	}
+
+	if cfg.Local.Enabled {
+		tieredCache := tieredUsersCache.New(uc.UserCache, client, b, cfg.Local.Size, cfg.Local.TTL, m)
+		go tieredCache.Listen(ctx)
+		uc.UserCache = tieredCache
+	}
+	return uc, nil
+}
+
+// retryLegacyMigration runs the migration skipped at start as often as the breaker probes redis,
+// blocks until it succeeds or ctx is done. Legacy keys stay readable in redis until then.
+func retryLegacyMigration(ctx context.Context, c *redisUsersCache.UserCache, interval time.Duration) {
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := c.MigrateLegacyKeys(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "Legacy cache keys are still not migrated", "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "Legacy cache keys migrated", "count", n)
+		return
+	}
+}
+
+// newPermissionCache shares redis and its breaker with the users cache, nil when cache is disabled.
+func newPermissionCache(cfg *config.Cache, uc *userCache) accessService.PermissionCache {
+	if uc.client == nil {
+		return nil
+	}
+	return redisPermissionsCache.New(uc.client, cfg.PermissionsTTL, uc.breaker)
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	redisUsersCache "github.com/Arh0rn/test-task1/internal/cache/redis/users"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+func TestRetryLegacyMigration(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.Set("user:1", `{"ID":1,"Name":"John","Password":"$2a$10$hash"}`)
+	mr.SetError("LOADING redis is loading the dataset in memory")
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	c := redisUsersCache.New(client, time.Minute, time.Minute, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		retryLegacyMigration(ctx, c, 0)
+	}()
+
+	time.Sleep(1500 * time.Millisecond) // at least one failed attempt
+	if !mr.Exists("user:1") {
+		t.Fatal("legacy key is gone while redis was failing")
+	}
+	mr.SetError("")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("migration was not retried after redis came back")
+	}
+	if mr.Exists("user:1") {
+		t.Error("legacy key with the password hash is still there")
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	healthService "github.com/Arh0rn/test-task1/internal/service/health"
+	"github.com/Arh0rn/test-task1/pkg/breaker"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"strings"
+)
+
+// newHealthService, postgres primary is required. Replicas and redis are optional,
+// reads fall back to primary and the cache to db when they are gone. Redis counts as down
+// while its breaker is open, the cache is not used then even if redis answers pings again.
+func newHealthService(cfg *config.Health, db *databases.Cluster, uc *userCache) *healthService.HealthService {
+	hs := healthService.New(cfg.CheckTimeout).
+		WithCheck("postgres", db.Primary().Ping)
+	if len(db.ReplicaStatus()) > 0 {
+		hs.WithOptionalCheck("postgres-replicas", func(context.Context) error {
+			return replicasHealth(db)
+		})
+	}
+	if uc.client != nil {
+		hs.WithOptionalCheck("redis", func(ctx context.Context) error {
+			if uc.breaker.State() == breaker.Open {
+				return breaker.ErrOpen
+			}
+			return uc.client.Ping(ctx).Err()
+		})
+	}
+	return hs
+}
+
+// replicasHealth is what the cluster's own health checks found, no extra queries.
+func replicasHealth(db *databases.Cluster) error {
+	var down []string
+	for _, r := range db.ReplicaStatus() {
+		if !r.Healthy {
+			down = append(down, r.Name)
+		}
+	}
+	if len(down) > 0 {
+		return fmt.Errorf("replicas out of rotation: %s", strings.Join(down, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/migrator"
+	"github.com/Arh0rn/test-task1/migrations"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"github.com/Arh0rn/test-task1/pkg/logger"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: app migrate up | down [n] | goto <version> | force <version> | status"
+
+var ErrMigrateUsage = errors.New(migrateUsage)
+
+// RunMigrate handles `app migrate ...` subcommands.
+func RunMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrMigrateUsage
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger.InitLogger(cfg.Env))
+
+	db, err := databases.NewPostgresPool(ctx, &cfg.Database, databases.PoolHooks{})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to connect to database", "error", err)
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrator.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return ErrMigrateUsage
+			}
+		}
+		return m.Down(ctx, n)
+	case "goto", "force":
+		if len(args) < 2 {
+			return ErrMigrateUsage
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return ErrMigrateUsage
+		}
+		if args[0] == "force" {
+			return m.Force(ctx, uint(version))
+		}
+		return m.Goto(ctx, uint(version))
+	case "status":
+		st, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(st)
+		return nil
+	}
+	return ErrMigrateUsage
+}
+
+func printStatus(st *migrator.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "current version: %d", st.Version)
+	if st.Dirty {
+		fmt.Fprint(w, " (dirty)")
+	}
+	fmt.Fprintln(w)
+	for _, mig := range st.Migrations {
+		state := "pending"
+		if mig.Applied {
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%06d\t%s\t%s\n", mig.Version, mig.Name, state)
+	}
+}
+
+// autoMigrate uses its own short-lived pool, the app pool prepares statements
+// on connect and can't open connections until the schema is there.
+func autoMigrate(ctx context.Context, cfg *config.Database) error {
+	db, err := databases.NewPostgresPool(ctx, cfg, databases.PoolHooks{})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrator.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	return m.Up(ctx)
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	logNotifier "github.com/Arh0rn/test-task1/internal/notifier/log"
+	smtpNotifier "github.com/Arh0rn/test-task1/internal/notifier/smtp"
+	usersService "github.com/Arh0rn/test-task1/internal/service/users"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"log/slog"
+)
+
+func newNotifier(ctx context.Context, cfg *config.Notifier) (usersService.Notifier, error) {
+	switch cfg.Driver {
+	case "log":
+		slog.WarnContext(ctx, "Notifications are written to the log, invite links included")
+		return logNotifier.New(), nil
+	case "smtp":
+		slog.InfoContext(ctx, "Sending notifications by mail", "host", cfg.SMTP.Host)
+		return smtpNotifier.New(&cfg.SMTP)
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", cfg.Driver)
+	}
+}
+
+func invitationOptions(cfg *config.SignUp) usersService.InvitationOptions {
+	return usersService.InvitationOptions{
+		TTL:       cfg.InvitationTTL,
+		AcceptURL: cfg.AcceptURL,
+	}
+}
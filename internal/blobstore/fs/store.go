@@ -0,0 +1,112 @@
+package fsBlobStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Store keeps blobs as files under root, key "a/b.jpg" is root/a/b.jpg.
+// Content type is derived from the key extension.
+type Store struct {
+	root string
+}
+
+func New(root string) (*Store, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create blob dir: %w", err)
+	}
+	return &Store{root: root}, nil
+}
+
+// Put writes to a temp file and renames it, readers never see a half written blob.
+func (s *Store) Put(ctx context.Context, key string, data []byte, _ string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	slog.DebugContext(ctx, "Blob stored", "key", key, "size", len(data))
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (*domain.Blob, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, domain.ErrBlobNotFound
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to read blob", "key", key, "error", err)
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return &domain.Blob{Data: data, ContentType: contentType, ModTime: info.ModTime()}, nil
+}
+
+// DeletePrefix removes the directory, prefix must end with "/".
+func (s *Store) DeletePrefix(ctx context.Context, prefix string) error {
+	if !strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("%w: prefix %q must end with /", ErrInvalidKey, prefix)
+	}
+	path, err := s.path(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Blobs deleted", "prefix", prefix)
+	return nil
+}
+
+// path keeps keys inside root.
+func (s *Store) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return filepath.Join(s.root, clean), nil
+}
@@ -0,0 +1,210 @@
+package s3BlobStore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const maxBlobSize = 32 << 20 // blobs are small, anything bigger is not ours
+
+// emptyPayloadHash is sha256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Store talks to S3 or a compatible server (MinIO, Ceph, R2...) over plain http with sigv4.
+type Store struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+}
+
+func New(cfg *config.S3) (*Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is not set")
+	}
+	return &Store{
+		client:    &http.Client{Timeout: cfg.Timeout},
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		region:    cfg.Region,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		pathStyle: cfg.PathStyle,
+	}, nil
+}
+
+func (s *Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	req, err := s.request(ctx, http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to put blob", "key", key, "error", err)
+		return err
+	}
+	resp.Body.Close()
+
+	slog.DebugContext(ctx, "Blob stored", "key", key, "size", len(data))
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (*domain.Blob, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		if !errors.Is(err, domain.ErrBlobNotFound) {
+			slog.ErrorContext(ctx, "Failed to get blob", "key", key, "error", err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBlobSize))
+	if err != nil {
+		return nil, err
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &domain.Blob{
+		Data:        data,
+		ContentType: resp.Header.Get("Content-Type"),
+		ModTime:     modTime,
+	}, nil
+}
+
+// DeletePrefix lists keys under prefix and deletes them one by one,
+// per-object DELETE is supported by every S3 compatible server unlike batch delete.
+func (s *Store) DeletePrefix(ctx context.Context, prefix string) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list blobs", "prefix", prefix, "error", err)
+			return err
+		}
+
+		var list listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("decode s3 list: %w", err)
+		}
+
+		for _, object := range list.Contents {
+			if err := s.delete(ctx, object.Key); err != nil {
+				return err
+			}
+		}
+		if !list.IsTruncated {
+			break
+		}
+		token = list.NextContinuationToken
+	}
+	slog.DebugContext(ctx, "Blobs deleted", "prefix", prefix)
+	return nil
+}
+
+func (s *Store) delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil && !errors.Is(err, domain.ErrBlobNotFound) {
+		slog.ErrorContext(ctx, "Failed to delete blob", "key", key, "error", err)
+		return err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// request builds a signed request to key, empty key is the bucket itself.
+func (s *Store) request(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	u := *s.endpoint
+	path := "/" + key
+	if s.pathStyle {
+		path = "/" + s.bucket + path
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + path
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+	s.sign(req, payloadHash, time.Now())
+	return req, nil
+}
+
+// do returns domain.ErrBlobNotFound on 404 and the s3 error message on other failures.
+func (s *Store) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && req.Method != http.MethodPut {
+		return nil, domain.ErrBlobNotFound
+	}
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3Err)
+	return nil, fmt.Errorf("s3 %s %s: %s %s: %s", req.Method, req.URL.Path, resp.Status, s3Err.Code, s3Err.Message)
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
@@ -0,0 +1,244 @@
+package s3BlobStore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBucket = "blobs"
+
+// fakeS3 keeps objects in memory and serves path style requests for one bucket,
+// listings are paged by pageSize keys, the token is the key the next page starts at like S3 does.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	pageSize int
+	lists    int
+	fail     bool // answer everything with 500
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), signAlgorithm+" Credential=AKID/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	if f.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "<Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>")
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(data)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+	case r.Method == http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+		w.Write(object.data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	f.lists++
+	query := r.URL.Query()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		start, _ = slices.BinarySearch(keys, token)
+	}
+	end := min(start+f.pageSize, len(keys))
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []struct{ Key string }
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{IsTruncated: end < len(keys)}
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, struct{ Key string }{key})
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = keys[end]
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func newTestStore(t *testing.T, objects map[string]fakeObject) (*Store, *fakeS3) {
+	t.Helper()
+	if objects == nil {
+		objects = make(map[string]fakeObject)
+	}
+	fake := &fakeS3{objects: objects, pageSize: 2}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	s, err := New(&config.S3{
+		Endpoint:  srv.URL,
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		PathStyle: true,
+		Timeout:   time.Second,
+		AccessKey: "AKID",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, fake
+}
+
+func TestPutGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		data        []byte
+		contentType string
+	}{
+		{name: "image", key: "avatars/1/original", data: []byte("\x89PNG"), contentType: "image/png"},
+		{name: "key with spaces", key: "avatars/1/a b.jpg", data: []byte("jpeg"), contentType: "image/jpeg"},
+		{name: "empty", key: "empty", data: nil, contentType: "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestStore(t, nil)
+			ctx := context.Background()
+
+			if err := s.Put(ctx, tt.key, tt.data, tt.contentType); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			blob, err := s.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if string(blob.Data) != string(tt.data) || blob.ContentType != tt.contentType {
+				t.Errorf("Get() = %q %s, want %q %s", blob.Data, blob.ContentType, tt.data, tt.contentType)
+			}
+			if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !blob.ModTime.Equal(want) {
+				t.Errorf("ModTime = %v, want %v", blob.ModTime, want)
+			}
+		})
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    bool
+		wantErr error
+		wantMsg string
+	}{
+		{name: "missing key", wantErr: domain.ErrBlobNotFound},
+		{name: "server error", fail: true, wantMsg: "InternalError: We encountered an internal error."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestStore(t, nil)
+			fake.fail = tt.fail
+
+			_, err := s.Get(context.Background(), "avatars/404/original")
+			if err == nil {
+				t.Fatal("Get() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Get() error = %v, want it to contain %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		prefix    string
+		wantLeft  []string
+		wantLists int
+	}{
+		{name: "nothing to delete", keys: []string{"avatars/2/original"}, prefix: "avatars/1/", wantLeft: []string{"avatars/2/original"}, wantLists: 1},
+		{name: "single page", keys: []string{"avatars/1/a", "avatars/1/b", "avatars/2/a"}, prefix: "avatars/1/", wantLeft: []string{"avatars/2/a"}, wantLists: 1},
+		{
+			name:      "several pages",
+			keys:      []string{"avatars/1/a", "avatars/1/b", "avatars/1/c", "avatars/1/d", "avatars/1/e", "avatars/10/a"},
+			prefix:    "avatars/1/",
+			wantLeft:  []string{"avatars/10/a"},
+			wantLists: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := make(map[string]fakeObject)
+			for _, key := range tt.keys {
+				objects[key] = fakeObject{data: []byte(key)}
+			}
+			s, fake := newTestStore(t, objects)
+
+			if err := s.DeletePrefix(context.Background(), tt.prefix); err != nil {
+				t.Fatalf("DeletePrefix() error = %v", err)
+			}
+			var left []string
+			for key := range fake.objects {
+				left = append(left, key)
+			}
+			slices.Sort(left)
+			if !slices.Equal(left, tt.wantLeft) {
+				t.Errorf("keys left = %v, want %v", left, tt.wantLeft)
+			}
+			if fake.lists != tt.wantLists {
+				t.Errorf("list requests = %d, want %d", fake.lists, tt.wantLists)
+			}
+		})
+	}
+}
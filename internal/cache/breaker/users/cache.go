@@ -0,0 +1,122 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/breaker"
+	"time"
+)
+
+// Cache is the wrapped cache, normally redis users cache.
+type Cache interface {
+	Set(context.Context, *domain.User) error
+	GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error)
+	SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error
+	InvalidateLists(ctx context.Context) error
+	GetByID(ctx context.Context, id int) (*domain.User, error)
+	UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) error
+	DeleteByID(ctx context.Context, id int) error
+}
+
+// ExpiringCache is passed through when the wrapped cache implements it.
+type ExpiringCache interface {
+	GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error)
+}
+
+// UserCache stops calling the cache while it is failing, so requests go
+// straight to db instead of waiting for redis timeouts. Writes skipped while
+// open are not replayed, cache ttl bounds how stale entries can get.
+type UserCache struct {
+	next    Cache
+	breaker *breaker.Breaker
+}
+
+func New(next Cache, b *breaker.Breaker) *UserCache {
+	return &UserCache{
+		next:    next,
+		breaker: b,
+	}
+}
+
+func (c *UserCache) Set(ctx context.Context, user *domain.User) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	return c.record(c.next.Set(ctx, user))
+}
+
+func (c *UserCache) GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, 0, err
+	}
+	list, gen, err := c.next.GetList(ctx, query)
+	return list, gen, c.record(err)
+}
+
+func (c *UserCache) SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	return c.record(c.next.SetList(ctx, gen, query, list))
+}
+
+func (c *UserCache) InvalidateLists(ctx context.Context) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	return c.record(c.next.InvalidateLists(ctx))
+}
+
+func (c *UserCache) GetByID(ctx context.Context, id int) (*domain.User, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	user, err := c.next.GetByID(ctx, id)
+	return user, c.record(err)
+}
+
+func (c *UserCache) GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error) {
+	ec, ok := c.next.(ExpiringCache)
+	if !ok {
+		user, err := c.GetByID(ctx, id)
+		return user, -1, err
+	}
+	if err := c.breaker.Allow(); err != nil {
+		return nil, 0, err
+	}
+	user, ttl, err := ec.GetByIDWithTTL(ctx, id)
+	return user, ttl, c.record(err)
+}
+
+func (c *UserCache) UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	return c.record(c.next.UpdateByID(ctx, user, id))
+}
+
+func (c *UserCache) DeleteByID(ctx context.Context, id int) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	return c.record(c.next.DeleteByID(ctx, id))
+}
+
+func (c *UserCache) State() breaker.State {
+	return c.breaker.State()
+}
+
+// record reports the call result to the breaker and returns err as is.
+// Miss is a healthy answer, canceled request says nothing about redis.
+func (c *UserCache) record(err error) error {
+	switch {
+	case err == nil, errors.Is(err, domain.ErrUserNotFound):
+		c.breaker.Success()
+	case errors.Is(err, context.Canceled):
+		c.breaker.Release()
+	default:
+		c.breaker.Failure()
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"time"
+)
+
+const (
+	cacheName = "users"
+	tier      = "redis"
+)
+
+// Cache is the wrapped cache, normally redis users cache.
+type Cache interface {
+	Set(context.Context, *domain.User) error
+	GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error)
+	SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error
+	InvalidateLists(ctx context.Context) error
+	GetByID(ctx context.Context, id int) (*domain.User, error)
+	UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) error
+	DeleteByID(ctx context.Context, id int) error
+}
+
+// ExpiringCache is passed through when the wrapped cache implements it.
+type ExpiringCache interface {
+	GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error)
+}
+
+type Recorder interface {
+	CacheResult(cache, tier, op, result string)
+}
+
+// UserCache counts hits, misses and errors of the wrapped cache. It sits under
+// the breaker, so calls skipped while the breaker is open are not counted.
+type UserCache struct {
+	next     Cache
+	recorder Recorder
+}
+
+func New(next Cache, recorder Recorder) *UserCache {
+	return &UserCache{
+		next:     next,
+		recorder: recorder,
+	}
+}
+
+func (c *UserCache) Set(ctx context.Context, user *domain.User) error {
+	return c.write("set", c.next.Set(ctx, user))
+}
+
+// GetList has no miss error, nil list is the miss.
+func (c *UserCache) GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error) {
+	list, gen, err := c.next.GetList(ctx, query)
+	switch {
+	case err != nil:
+		c.recorder.CacheResult(cacheName, tier, "get_list", "error")
+	case list == nil:
+		c.recorder.CacheResult(cacheName, tier, "get_list", "miss")
+	default:
+		c.recorder.CacheResult(cacheName, tier, "get_list", "hit")
+	}
+	return list, gen, err
+}
+
+func (c *UserCache) SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error {
+	return c.write("set_list", c.next.SetList(ctx, gen, query, list))
+}
+
+func (c *UserCache) InvalidateLists(ctx context.Context) error {
+	return c.write("invalidate_lists", c.next.InvalidateLists(ctx))
+}
+
+func (c *UserCache) GetByID(ctx context.Context, id int) (*domain.User, error) {
+	user, err := c.next.GetByID(ctx, id)
+	return user, c.read("get", err)
+}
+
+func (c *UserCache) GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error) {
+	ec, ok := c.next.(ExpiringCache)
+	if !ok {
+		user, err := c.GetByID(ctx, id)
+		return user, -1, err
+	}
+	user, ttl, err := ec.GetByIDWithTTL(ctx, id)
+	return user, ttl, c.read("get", err)
+}
+
+func (c *UserCache) UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) error {
+	return c.write("update", c.next.UpdateByID(ctx, user, id))
+}
+
+func (c *UserCache) DeleteByID(ctx context.Context, id int) error {
+	return c.write("delete", c.next.DeleteByID(ctx, id))
+}
+
+func (c *UserCache) read(op string, err error) error {
+	switch {
+	case err == nil:
+		c.recorder.CacheResult(cacheName, tier, op, "hit")
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.recorder.CacheResult(cacheName, tier, op, "miss")
+	default:
+		c.recorder.CacheResult(cacheName, tier, op, "error")
+	}
+	return err
+}
+
+// write, update of an entry that is not cached comes back as not found, that is a miss not an error.
+func (c *UserCache) write(op string, err error) error {
+	switch {
+	case err == nil:
+		c.recorder.CacheResult(cacheName, tier, op, "ok")
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.recorder.CacheResult(cacheName, tier, op, "miss")
+	default:
+		c.recorder.CacheResult(cacheName, tier, op, "error")
+	}
+	return err
+}
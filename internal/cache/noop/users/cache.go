@@ -0,0 +1,41 @@
+package users
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/domain"
+)
+
+// UserCache is used when cache is disabled, every read is a miss.
+type UserCache struct{}
+
+func New() *UserCache {
+	return &UserCache{}
+}
+
+func (c *UserCache) Set(context.Context, *domain.User) error {
+	return nil
+}
+
+func (c *UserCache) GetList(context.Context, *domain.UserListQuery) (*domain.UserList, int64, error) {
+	return nil, 0, nil
+}
+
+func (c *UserCache) SetList(context.Context, int64, *domain.UserListQuery, *domain.UserList) error {
+	return nil
+}
+
+func (c *UserCache) InvalidateLists(context.Context) error {
+	return nil
+}
+
+func (c *UserCache) GetByID(context.Context, int) (*domain.User, error) {
+	return nil, domain.ErrUserNotFound
+}
+
+func (c *UserCache) UpdateByID(context.Context, *domain.UserUpdate, int) error {
+	return nil
+}
+
+func (c *UserCache) DeleteByID(context.Context, int) error {
+	return nil
+}
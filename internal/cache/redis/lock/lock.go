@@ -0,0 +1,49 @@
+package lock
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"time"
+)
+
+const lockKey = "lock:"
+
+// Delete only if the lock is still ours, it may have expired and been taken by someone else.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Locker is a simple single instance redis lock (SET NX PX), good enough to
+// keep replicas from doing the same work, not for correctness critical sections.
+type Locker struct {
+	client *redis.Client
+}
+
+func New(client *redis.Client) *Locker {
+	return &Locker{client: client}
+}
+
+// TryLock does not wait. ok is false when somebody else holds the lock.
+func (l *Locker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(context.Context), bool, error) {
+	token := uuid.NewString()
+	ok, err := l.client.SetNX(ctx, lockKey+key, token, ttl).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to acquire lock", "key", key, "error", err)
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	unlock := func(ctx context.Context) {
+		if err := unlockScript.Run(ctx, l.client, []string{lockKey + key}, token).Err(); err != nil {
+			slog.ErrorContext(ctx, "Failed to release lock", "key", key, "error", err)
+		}
+	}
+	return unlock, true, nil
+}
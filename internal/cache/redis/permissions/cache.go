@@ -0,0 +1,133 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/breaker"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// One hash per organization, field per user, so a group change drops the whole org with one DEL.
+const permsKey = "perms:v1:"
+
+// PermissionCache keeps effective permissions of users. It shares the breaker
+// with the users cache, it's the same redis. Invalidations skipped while the
+// breaker is open are lost like users cache writes, ttl bounds the staleness.
+type PermissionCache struct {
+	client  *redis.Client
+	ttl     time.Duration
+	breaker *breaker.Breaker
+}
+
+func New(client *redis.Client, ttl time.Duration, b *breaker.Breaker) *PermissionCache {
+	return &PermissionCache{
+		client:  client,
+		ttl:     ttl,
+		breaker: b,
+	}
+}
+
+func key(orgID int) string {
+	return permsKey + fmt.Sprint(orgID)
+}
+
+// Get returns ok false on a miss. Empty permissions are cached too, they are a valid answer.
+func (c *PermissionCache) Get(ctx context.Context, orgID, userID int) ([]domain.Permission, bool, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, false, err
+	}
+	val, err := c.client.HGet(ctx, key(orgID), fmt.Sprint(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		c.breaker.Success()
+		return nil, false, nil
+	}
+	if err := c.record(err); err != nil {
+		slog.ErrorContext(ctx, "Failed to get permissions from cache", "error", err)
+		return nil, false, err
+	}
+	return decode(val), true, nil
+}
+
+// Set refreshes the ttl of the whole hash, entries of one organization expire together.
+func (c *PermissionCache) Set(ctx context.Context, orgID, userID int, perms []domain.Permission) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	pipe := c.client.TxPipeline()
+	pipe.HSet(ctx, key(orgID), fmt.Sprint(userID), encode(perms))
+	pipe.Expire(ctx, key(orgID), c.ttl)
+	_, err := pipe.Exec(ctx)
+	if err := c.record(err); err != nil {
+		slog.ErrorContext(ctx, "Failed to set permissions in cache", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (c *PermissionCache) Delete(ctx context.Context, orgID int, userIDs ...int) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	fields := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		fields[i] = fmt.Sprint(id)
+	}
+	if err := c.record(c.client.HDel(ctx, key(orgID), fields...).Err()); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete permissions from cache", "error", err)
+		return err
+	}
+	slog.DebugContext(ctx, "Permissions deleted from cache", "org_id", orgID, "user_ids", userIDs)
+	return nil
+}
+
+func (c *PermissionCache) DeleteOrg(ctx context.Context, orgID int) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	if err := c.record(c.client.Del(ctx, key(orgID)).Err()); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete permissions from cache", "error", err)
+		return err
+	}
+	slog.DebugContext(ctx, "Organization permissions deleted from cache", "org_id", orgID)
+	return nil
+}
+
+// record is the same as in the breaker users cache.
+func (c *PermissionCache) record(err error) error {
+	switch {
+	case err == nil:
+		c.breaker.Success()
+	case errors.Is(err, context.Canceled):
+		c.breaker.Release()
+	default:
+		c.breaker.Failure()
+	}
+	return err
+}
+
+func encode(perms []domain.Permission) string {
+	s := make([]string, len(perms))
+	for i, p := range perms {
+		s[i] = string(p)
+	}
+	return strings.Join(s, ",")
+}
+
+func decode(val string) []domain.Permission {
+	perms := []domain.Permission{}
+	if val == "" {
+		return perms
+	}
+	for _, p := range strings.Split(val, ",") {
+		perms = append(perms, domain.Permission(p))
+	}
+	return perms
+}
@@ -0,0 +1,72 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+)
+
+const revocationChannel = "sessions:revoke"
+
+type revocation struct {
+	Instance   string   `json:"instance"`
+	SessionIDs []string `json:"session_ids"`
+}
+
+// Revocations tells other replicas about revoked sessions, so they stop trusting
+// the live sessions they remember without asking db.
+type Revocations struct {
+	client   *redis.Client
+	instance string // to skip our own messages
+}
+
+func New(client *redis.Client) *Revocations {
+	return &Revocations{client: client, instance: uuid.NewString()}
+}
+
+func (r *Revocations) Publish(ctx context.Context, ids ...string) {
+	data, err := json.Marshal(revocation{Instance: r.instance, SessionIDs: ids})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to marshal session revocation", "error", err)
+		return
+	}
+	if err := r.client.Publish(ctx, revocationChannel, data).Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish session revocation", "count", len(ids), "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "Session revocation published", "count", len(ids))
+}
+
+// Listen calls fn with every session revoked by other replicas, blocks until ctx is done.
+// Messages published while the subscription is reconnecting are lost, the session
+// check interval is what bounds the delay then.
+func (r *Revocations) Listen(ctx context.Context, fn func(id string)) {
+	pubsub := r.client.Subscribe(ctx, revocationChannel)
+	defer pubsub.Close()
+
+	slog.InfoContext(ctx, "Listening for session revocations", "instance", r.instance)
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var rev revocation
+			if err := json.Unmarshal([]byte(msg.Payload), &rev); err != nil {
+				slog.ErrorContext(ctx, "Failed to unmarshal session revocation", "error", err)
+				continue
+			}
+			if rev.Instance == r.instance {
+				continue
+			}
+			for _, id := range rev.SessionIDs {
+				fn(id)
+			}
+		}
+	}
+}
@@ -2,37 +2,41 @@ package users
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/encrypt"
 	"github.com/redis/go-redis/v9"
 	"log/slog"
 	"time"
 )
 
 const (
-	userKey   = "user:"
+	userKey   = "user:v5:" // v5: org ids
 	scanCount = 100
 )
 
 type UserCache struct {
-	client *redis.Client
-	ttl    time.Duration
+	client    *redis.Client
+	ttl       time.Duration
+	listTTL   time.Duration
+	encryptor *encrypt.Encryptor // nil means payloads are stored as plain json
 }
 
-func New(client *redis.Client, ttl time.Duration) *UserCache {
+func New(client *redis.Client, ttl, listTTL time.Duration, encryptor *encrypt.Encryptor) *UserCache {
 	return &UserCache{
-		client: client,
-		ttl:    ttl,
+		client:    client,
+		ttl:       ttl,
+		listTTL:   listTTL,
+		encryptor: encryptor,
 	}
 }
 
 func (c *UserCache) Set(ctx context.Context, user *domain.User) error {
 	key := userKey + fmt.Sprint(user.ID)
-	data, err := json.Marshal(user)
+	data, err := c.encode(user)
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed to marshal user", "error", err)
+		slog.ErrorContext(ctx, "Failed to encode user", "error", err)
 		return err
 	}
 	err = c.client.Set(ctx, key, data, c.ttl).Err()
@@ -44,79 +48,10 @@ func (c *UserCache) Set(ctx context.Context, user *domain.User) error {
 	return nil
 }
 
-func (c *UserCache) GetAll(ctx context.Context) ([]*domain.User, error) {
-	slog.DebugContext(ctx, "Getting all users from cache")
-	var (
-		cursor  uint64
-		allKeys []string
-	)
-	users := make([]*domain.User, 0)
-
-	for {
-		keys, nextCursor, err := c.client.Scan(ctx, cursor, userKey+"*", scanCount).Result()
-		if err != nil {
-			slog.ErrorContext(ctx, "Failed to scan keys", "error", err)
-			return nil, err
-		}
-		allKeys = append(allKeys, keys...)
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
-	}
-
-	if len(allKeys) == 0 {
-		slog.InfoContext(ctx, "No users found in cache")
-		return nil, nil
-	}
-
-	values, err := c.client.MGet(ctx, allKeys...).Result()
-	if err != nil {
-		slog.ErrorContext(ctx, "Failed to get users from cache", "error", err)
-		return nil, err
-	}
-	for _, val := range values {
-		if val == "" || val == "nil" {
-			continue
-		}
-		str, ok := val.(string)
-		if !ok {
-			continue
-		}
-		var user domain.User
-		if err := json.Unmarshal([]byte(str), &user); err != nil {
-			slog.ErrorContext(ctx, "Failed to unmarshal user", "error", err)
-			continue
-		}
-		users = append(users, &user)
-	}
-	slog.DebugContext(ctx, "Users found in cache", "user_count", len(users))
-	return users, nil
-}
-
-func (c *UserCache) SetAll(ctx context.Context, users []*domain.User) error {
-	pipe := c.client.Pipeline()
-	for _, user := range users {
-		key := userKey + fmt.Sprint(user.ID)
-		data, err := json.Marshal(user)
-		if err != nil {
-			slog.ErrorContext(ctx, "Failed to marshal user", "error", err)
-			continue
-		}
-		pipe.Set(ctx, key, data, c.ttl)
-	}
-	_, err := pipe.Exec(ctx)
-	if err != nil {
-		slog.ErrorContext(ctx, "Failed to execute pipeline", "error", err)
-		return err
-	}
-	slog.DebugContext(ctx, "Users set in cache", "user_count", len(users))
-	return nil
-}
-
+// GetByID returns the user without password, cache never stores it.
 func (c *UserCache) GetByID(ctx context.Context, id int) (*domain.User, error) {
 	key := userKey + fmt.Sprint(id)
-	val, err := c.client.Get(ctx, key).Result()
+	val, err := c.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			slog.InfoContext(ctx, "User not found in cache", "user_id", id)
@@ -126,14 +61,13 @@ func (c *UserCache) GetByID(ctx context.Context, id int) (*domain.User, error) {
 		return nil, err
 	}
 
-	var user domain.User
-	if err := json.Unmarshal([]byte(val), &user); err != nil {
-		slog.ErrorContext(ctx, "Failed to unmarshal user", "error", err)
+	user, err := c.decode(val)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to decode user", "error", err)
 		return nil, err
-
 	}
 	slog.DebugContext(ctx, "User found in cache", "user_id", user.ID)
-	return &user, nil
+	return user, nil
 }
 
 func (c *UserCache) UpdateByID(ctx context.Context, update *domain.UserUpdate, id int) error {
@@ -145,6 +79,8 @@ func (c *UserCache) UpdateByID(ctx context.Context, update *domain.UserUpdate, i
 
 	user.Name = update.Name
 	user.Email = update.Email
+	user.UpdatedAt = update.UpdatedAt
+	user.Profile = update.Profile
 
 	err = c.Set(ctx, user)
 	if err != nil {
@@ -166,3 +102,32 @@ func (c *UserCache) DeleteByID(ctx context.Context, id int) error {
 	slog.DebugContext(ctx, "User deleted from cache", "user_id", id)
 	return nil
 }
+
+// GetByIDWithTTL is GetByID that also tells how long the entry has left, for early refresh.
+func (c *UserCache) GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error) {
+	key := userKey + fmt.Sprint(id)
+	pipe := c.client.Pipeline()
+	getCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		slog.ErrorContext(ctx, "Failed to get user from cache", "error", err)
+		return nil, 0, err
+	}
+
+	val, err := getCmd.Bytes()
+	if errors.Is(err, redis.Nil) {
+		slog.InfoContext(ctx, "User not found in cache", "user_id", id)
+		return nil, 0, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	user, err := c.decode(val)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to decode user", "error", err)
+		return nil, 0, err
+	}
+	return user, ttlCmd.Val(), nil
+}
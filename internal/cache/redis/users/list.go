@@ -0,0 +1,75 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+)
+
+// Every create/update/delete bumps the generation, pages of older generations
+// are never read again and just expire. So a cached page is always a whole page.
+// Pages are per organization, the one from ctx.
+const (
+	listGenerationKey = "users:list:gen"
+	listKey           = "users:list:v4:"
+)
+
+func pageKey(ctx context.Context, gen int64, query *domain.UserListQuery) string {
+	org, _ := domain.OrgID(ctx)
+	return fmt.Sprintf("%s%d:org=%d:limit=%d:offset=%d", listKey, gen, org, query.Limit, query.Offset)
+}
+
+// GetList returns nil list on miss. Generation is returned in any case
+// and must be passed to SetList, so a page read before a write is stored under the old one.
+func (c *UserCache) GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error) {
+	gen, err := c.client.Get(ctx, listGenerationKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		slog.ErrorContext(ctx, "Failed to get list generation", "error", err)
+		return nil, 0, err
+	}
+
+	val, err := c.client.Get(ctx, pageKey(ctx, gen, query)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		slog.DebugContext(ctx, "User list not found in cache", "generation", gen)
+		return nil, gen, nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user list from cache", "error", err)
+		return nil, 0, err
+	}
+
+	var cl cachedUserList
+	if err := c.open(val, &cl); err != nil {
+		slog.ErrorContext(ctx, "Failed to decode user list", "error", err)
+		return nil, gen, nil // treat as miss, SetList will overwrite it
+	}
+	slog.DebugContext(ctx, "User list found in cache", "generation", gen, "user_count", len(cl.Users))
+	return cl.toUserList(), gen, nil
+}
+
+func (c *UserCache) SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error {
+	data, err := c.seal(toCachedUserList(list))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode user list", "error", err)
+		return err
+	}
+	if err := c.client.Set(ctx, pageKey(ctx, gen, query), data, c.listTTL).Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to set user list in cache", "error", err)
+		return err
+	}
+	slog.DebugContext(ctx, "User list set in cache", "generation", gen)
+	return nil
+}
+
+func (c *UserCache) InvalidateLists(ctx context.Context) error {
+	gen, err := c.client.Incr(ctx, listGenerationKey).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to bump list generation", "error", err)
+		return err
+	}
+	slog.DebugContext(ctx, "User lists invalidated", "generation", gen)
+	return nil
+}
@@ -0,0 +1,88 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"strings"
+)
+
+// Unversioned keys from before v2, they hold the whole domain.User with the password hash.
+const legacyUserKey = "user:"
+
+// MigrateLegacyKeys moves old "user:<id>" entries to the current schema.
+// Only the safe projection is copied (with the remaining ttl), old key is always deleted.
+// Keys that fail to copy are just deleted, the error means legacy keys may be left.
+func (c *UserCache) MigrateLegacyKeys(ctx context.Context) (int, error) {
+	var (
+		cursor   uint64
+		migrated int
+	)
+	for {
+		keys, nextCursor, err := c.client.Scan(ctx, cursor, legacyUserKey+"*", scanCount).Result()
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to scan legacy keys", "error", err)
+			return migrated, err
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, userKey) || strings.Count(key, ":") != 1 {
+				continue // versioned or something that is not ours
+			}
+			if err := c.migrateLegacyKey(ctx, key); err != nil {
+				// it holds a password hash, losing the entry is fine, keeping it is not
+				slog.WarnContext(ctx, "Failed to migrate legacy key, deleting it", "key", key, "error", err)
+				if err := c.client.Del(ctx, key).Err(); err != nil {
+					slog.ErrorContext(ctx, "Failed to delete legacy key", "key", key, "error", err)
+					return migrated, err
+				}
+				continue
+			}
+			migrated++
+		}
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	slog.DebugContext(ctx, "Legacy user keys migrated", "count", migrated)
+	return migrated, nil
+}
+
+func (c *UserCache) migrateLegacyKey(ctx context.Context, key string) error {
+	val, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil // expired in between
+	}
+	if err != nil {
+		return err
+	}
+	ttl, err := c.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	var user domain.User // legacy payload is domain.User marshaled as is
+	if err := json.Unmarshal(val, &user); err != nil || user.ID == 0 {
+		slog.WarnContext(ctx, "Dropping unreadable legacy key", "key", key)
+		return c.client.Del(ctx, key).Err()
+	}
+
+	data, err := c.encode(&user)
+	if err != nil {
+		return err
+	}
+
+	if ttl <= 0 { // no expiry or already gone
+		ttl = c.ttl
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, userKey+fmt.Sprint(user.ID), data, ttl)
+	pipe.Del(ctx, key)
+	_, err = pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,154 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errInjected = errors.New("injected failure")
+
+// failHook fails commands on the given keys: copy fails the MULTI writing the new key,
+// del fails the standalone DEL of a legacy key.
+type failHook struct {
+	copy, del []string
+}
+
+func (h failHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h failHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "del" && slices.Contains(h.del, cmdKey(cmd)) {
+			cmd.SetErr(errInjected)
+			return errInjected
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h failHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if cmd.Name() == "set" && slices.Contains(h.copy, cmdKey(cmd)) {
+				return errInjected
+			}
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func cmdKey(cmd redis.Cmder) string {
+	if args := cmd.Args(); len(args) > 1 {
+		key, _ := args[1].(string)
+		return key
+	}
+	return ""
+}
+
+func legacyPayload(t *testing.T, id int) string {
+	t.Helper()
+	data, err := json.Marshal(domain.User{ID: id, Name: "John", Email: "john@example.com", Password: "$2a$10$hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		legacy       map[string]string // key -> payload, "" for a valid user of the key's id
+		hook         failHook
+		wantMigrated int
+		wantErr      bool
+		wantLeft     []string // legacy keys still there
+		wantNew      []string
+	}{
+		{
+			name:         "all migrated",
+			legacy:       map[string]string{"user:1": "", "user:2": ""},
+			wantMigrated: 2,
+			wantNew:      []string{userKey + "1", userKey + "2"},
+		},
+		{
+			name:         "unreadable payload is dropped",
+			legacy:       map[string]string{"user:1": "", "user:2": "not json"},
+			wantMigrated: 2, // dropping is how it is migrated
+			wantNew:      []string{userKey + "1"},
+		},
+		{
+			name:         "failed copy deletes the legacy key",
+			legacy:       map[string]string{"user:1": "", "user:2": ""},
+			hook:         failHook{copy: []string{userKey + "2"}},
+			wantMigrated: 1,
+			wantNew:      []string{userKey + "1"},
+		},
+		{
+			name:     "failed copy and delete is an error",
+			legacy:   map[string]string{"user:2": ""},
+			hook:     failHook{copy: []string{userKey + "2"}, del: []string{"user:2"}},
+			wantErr:  true,
+			wantLeft: []string{"user:2"},
+		},
+		{
+			name:         "keys that are not legacy users are left alone",
+			legacy:       map[string]string{"user:1": "", "user:v4:3": "{}", "user:lists:gen": "7"},
+			wantMigrated: 1,
+			wantLeft:     []string{"user:lists:gen", "user:v4:3"},
+			wantNew:      []string{userKey + "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			for key, payload := range tt.legacy {
+				if payload == "" {
+					id, _ := strconv.Atoi(strings.TrimPrefix(key, legacyUserKey))
+					payload = legacyPayload(t, id)
+				}
+				mr.Set(key, payload)
+			}
+
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+			client.AddHook(tt.hook)
+			c := New(client, time.Minute, time.Minute, nil)
+
+			migrated, err := c.MigrateLegacyKeys(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MigrateLegacyKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if migrated != tt.wantMigrated {
+				t.Errorf("migrated = %d, want %d", migrated, tt.wantMigrated)
+			}
+
+			var left, created []string
+			for _, key := range mr.Keys() {
+				if strings.HasPrefix(key, userKey) {
+					created = append(created, key)
+					if v, _ := mr.Get(key); strings.Contains(v, "$2a$") {
+						t.Errorf("%s holds the password hash", key)
+					}
+					continue
+				}
+				left = append(left, key)
+			}
+			if !slices.Equal(left, tt.wantLeft) {
+				t.Errorf("legacy keys left = %v, want %v", left, tt.wantLeft)
+			}
+			if !slices.Equal(created, tt.wantNew) {
+				t.Errorf("new keys = %v, want %v", created, tt.wantNew)
+			}
+		})
+	}
+}
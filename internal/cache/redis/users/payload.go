@@ -0,0 +1,120 @@
+package users
+
+import (
+	"encoding/json"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"time"
+)
+
+// cachedUser is the only shape of user that goes to Redis.
+// No password hash or any other secret here, add fields with care.
+type cachedUser struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	DisplayName string         `json:"display_name,omitempty"`
+	AvatarURL   string         `json:"avatar_url,omitempty"`
+	Locale      string         `json:"locale,omitempty"`
+	Timezone    string         `json:"timezone,omitempty"`
+	Phone       string         `json:"phone,omitempty"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+
+	OrgIDs []int `json:"org_ids,omitempty"` // service checks them, entries are shared by all organizations
+}
+
+func toCachedUser(user *domain.User) *cachedUser {
+	return &cachedUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		Phone:       user.Phone,
+		Metadata:    user.Metadata,
+
+		OrgIDs: user.OrgIDs,
+	}
+}
+
+func (cu *cachedUser) toUser() *domain.User {
+	return &domain.User{
+		ID:        cu.ID,
+		Name:      cu.Name,
+		Email:     cu.Email,
+		CreatedAt: cu.CreatedAt,
+		UpdatedAt: cu.UpdatedAt,
+		Profile: domain.Profile{
+			DisplayName: cu.DisplayName,
+			AvatarURL:   cu.AvatarURL,
+			Locale:      cu.Locale,
+			Timezone:    cu.Timezone,
+			Phone:       cu.Phone,
+			Metadata:    cu.Metadata,
+		},
+		OrgIDs: cu.OrgIDs,
+	}
+}
+
+type cachedUserList struct {
+	Users []cachedUser `json:"users"`
+	Total int          `json:"total"`
+}
+
+func toCachedUserList(list *domain.UserList) *cachedUserList {
+	users := make([]cachedUser, 0, len(list.Users))
+	for _, user := range list.Users {
+		users = append(users, *toCachedUser(user))
+	}
+	return &cachedUserList{Users: users, Total: list.Total}
+}
+
+func (cl *cachedUserList) toUserList() *domain.UserList {
+	users := make([]*domain.User, 0, len(cl.Users))
+	for i := range cl.Users {
+		users = append(users, cl.Users[i].toUser())
+	}
+	return &domain.UserList{Users: users, Total: cl.Total}
+}
+
+func (c *UserCache) encode(user *domain.User) ([]byte, error) {
+	return c.seal(toCachedUser(user))
+}
+
+func (c *UserCache) decode(data []byte) (*domain.User, error) {
+	var cu cachedUser
+	if err := c.open(data, &cu); err != nil {
+		return nil, err
+	}
+	return cu.toUser(), nil
+}
+
+// seal marshals v and encrypts it if encryption is on.
+func (c *UserCache) seal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if c.encryptor == nil {
+		return data, nil
+	}
+	return c.encryptor.Encrypt(data)
+}
+
+func (c *UserCache) open(data []byte, v any) error {
+	if c.encryptor != nil {
+		var err error
+		data, err = c.encryptor.Decrypt(data)
+		if err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,185 @@
+package users
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/breaker"
+	"github.com/Arh0rn/test-task1/pkg/lru"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	cacheName = "users"
+	tier      = "local"
+)
+
+// RemoteCache is the shared tier, normally redis users cache.
+type RemoteCache interface {
+	Set(context.Context, *domain.User) error
+	GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error)
+	SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error
+	InvalidateLists(ctx context.Context) error
+	GetByID(ctx context.Context, id int) (*domain.User, error)
+	UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) error
+	DeleteByID(ctx context.Context, id int) error
+}
+
+// ExpiringCache is implemented by remote caches that can report entry ttl.
+type ExpiringCache interface {
+	GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error)
+}
+
+// Recorder counts local hits and misses, the remote tier counts its own.
+type Recorder interface {
+	CacheResult(cache, tier, op, result string)
+}
+
+// UserCache keeps hot users in process memory in front of the remote cache.
+// Replicas drop their local copies when another replica publishes an invalidation.
+type UserCache struct {
+	local  *lru.Cache[int, localEntry]
+	remote RemoteCache
+
+	// invalidations counts local deletes, a remote read that raced with one is not kept locally
+	mu            sync.Mutex
+	invalidations uint64
+
+	client   *redis.Client    // for pub/sub only
+	breaker  *breaker.Breaker // of the remote cache, publishing goes through it too
+	instance string           // to skip our own invalidation messages
+
+	recorder Recorder
+}
+
+func New(remote RemoteCache, client *redis.Client, b *breaker.Breaker, size int, ttl time.Duration, recorder Recorder) *UserCache {
+	return &UserCache{
+		local:    lru.New[int, localEntry](size, ttl),
+		remote:   remote,
+		client:   client,
+		breaker:  b,
+		instance: uuid.NewString(),
+		recorder: recorder,
+	}
+}
+
+// Set publishes an invalidation, other replicas may hold an older version of the user.
+func (c *UserCache) Set(ctx context.Context, user *domain.User) error {
+	if err := c.remote.Set(ctx, user); err != nil {
+		c.invalidate(user.ID)
+		return err
+	}
+	c.setLocal(user, time.Time{})
+	c.publishInvalidation(ctx, user.ID)
+	return nil
+}
+
+// Lists are not kept locally, generation check needs a redis round trip anyway.
+func (c *UserCache) GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error) {
+	return c.remote.GetList(ctx, query)
+}
+
+func (c *UserCache) SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error {
+	return c.remote.SetList(ctx, gen, query, list)
+}
+
+func (c *UserCache) InvalidateLists(ctx context.Context) error {
+	return c.remote.InvalidateLists(ctx)
+}
+
+func (c *UserCache) GetByID(ctx context.Context, id int) (*domain.User, error) {
+	user, _, err := c.GetByIDWithTTL(ctx, id)
+	return user, err
+}
+
+// GetByIDWithTTL reports the remote entry ttl even on local hit, -1 when it is unknown.
+func (c *UserCache) GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error) {
+	if e, ok := c.local.Get(id); ok {
+		c.recorder.CacheResult(cacheName, tier, "get", "hit")
+		slog.DebugContext(ctx, "User found in local cache", "user_id", id)
+		return e.user.Clone(), e.remoteTTL(), nil // callers may modify it
+	}
+	c.recorder.CacheResult(cacheName, tier, "get", "miss")
+
+	seen := c.invalidationCount()
+	user, ttl, err := c.getRemote(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var remoteExpiresAt time.Time
+	if ttl > 0 {
+		remoteExpiresAt = time.Now().Add(ttl)
+	}
+	c.fillLocal(user, remoteExpiresAt, seen)
+	return user, ttl, nil
+}
+
+// UpdateByID drops the local entry before and after the remote write, a read in between
+// may have got the old version from remote and must not keep it.
+func (c *UserCache) UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) error {
+	c.invalidate(id)
+	err := c.remote.UpdateByID(ctx, user, id)
+	c.invalidate(id)
+	c.publishInvalidation(ctx, id) // others must forget the old version even if remote failed
+	return err
+}
+
+func (c *UserCache) DeleteByID(ctx context.Context, id int) error {
+	c.invalidate(id)
+	err := c.remote.DeleteByID(ctx, id)
+	c.invalidate(id)
+	c.publishInvalidation(ctx, id)
+	return err
+}
+
+func (c *UserCache) getRemote(ctx context.Context, id int) (*domain.User, time.Duration, error) {
+	if ec, ok := c.remote.(ExpiringCache); ok {
+		return ec.GetByIDWithTTL(ctx, id)
+	}
+	user, err := c.remote.GetByID(ctx, id)
+	return user, -1, err
+}
+
+type localEntry struct {
+	user            domain.User
+	remoteExpiresAt time.Time // zero when unknown
+}
+
+func (e *localEntry) remoteTTL() time.Duration {
+	if e.remoteExpiresAt.IsZero() {
+		return -1
+	}
+	return time.Until(e.remoteExpiresAt)
+}
+
+func (c *UserCache) setLocal(user *domain.User, remoteExpiresAt time.Time) {
+	c.local.Set(user.ID, localEntry{user: *user.Clone(), remoteExpiresAt: remoteExpiresAt})
+}
+
+func (c *UserCache) invalidate(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidations++
+	c.local.Delete(id)
+}
+
+func (c *UserCache) invalidationCount() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.invalidations
+}
+
+// fillLocal keeps a remote read unless something was invalidated since seen, the read may be older
+// than the invalidation then. Any invalidation counts, they are rare next to reads.
+func (c *UserCache) fillLocal(user *domain.User, remoteExpiresAt time.Time, seen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.invalidations != seen {
+		return
+	}
+	c.setLocal(user, remoteExpiresAt)
+}
@@ -0,0 +1,264 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/breaker"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRemote embeds the interface, only what the tests call is implemented.
+type fakeRemote struct {
+	RemoteCache
+	mu    sync.Mutex
+	users map[int]*domain.User
+	gets  map[int]int
+
+	// onGet runs after GetByID read the user and before it returns it
+	onGet func()
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) CacheResult(cache, tier, op, result string) {}
+
+func newFakeRemote(users ...*domain.User) *fakeRemote {
+	r := &fakeRemote{users: make(map[int]*domain.User), gets: make(map[int]int)}
+	for _, u := range users {
+		r.users[u.ID] = u
+	}
+	return r
+}
+
+func (r *fakeRemote) GetByID(_ context.Context, id int) (*domain.User, error) {
+	r.mu.Lock()
+	r.gets[id]++
+	u, ok := r.users[id]
+	var clone *domain.User
+	if ok {
+		clone = u.Clone()
+	}
+	onGet := r.onGet
+	r.mu.Unlock()
+
+	if onGet != nil {
+		onGet()
+	}
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return clone, nil
+}
+
+func (r *fakeRemote) Set(_ context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = user.Clone()
+	return nil
+}
+
+func (r *fakeRemote) UpdateByID(_ context.Context, update *domain.UserUpdate, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		u.Name = update.Name
+	}
+	return nil
+}
+
+func (r *fakeRemote) DeleteByID(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, id)
+	return nil
+}
+
+func TestGetByIDReturnsDeepCopy(t *testing.T) {
+	remote := newFakeRemote(&domain.User{
+		ID:      1,
+		Profile: domain.Profile{Metadata: map[string]any{"plan": "pro", "tags": []any{"a"}, "nested": map[string]any{"k": "v"}}},
+		OrgIDs:  []int{10},
+	})
+	c := New(remote, nil, nil, 10, time.Minute, nopRecorder{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		mutate func(u *domain.User)
+	}{
+		{name: "metadata key", mutate: func(u *domain.User) { u.Metadata["plan"] = "free" }},
+		{name: "nested map", mutate: func(u *domain.User) { u.Metadata["nested"].(map[string]any)["k"] = "x" }},
+		{name: "nested slice", mutate: func(u *domain.User) { u.Metadata["tags"].([]any)[0] = "b" }},
+		{name: "org ids", mutate: func(u *domain.User) { u.OrgIDs[0] = 20 }},
+		{name: "org ids append", mutate: func(u *domain.User) { u.OrgIDs = append(u.OrgIDs[:0], 30, 40) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := c.GetByID(ctx, 1)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			tt.mutate(u)
+
+			got, err := c.GetByID(ctx, 1)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if want := remote.users[1]; !reflect.DeepEqual(got.Metadata, want.Metadata) || !reflect.DeepEqual(got.OrgIDs, want.OrgIDs) {
+				t.Errorf("local entry changed by caller: got %+v, want %+v", got.Profile, want.Profile)
+			}
+		})
+	}
+	if remote.gets[1] != 1 {
+		t.Errorf("remote GetByID called %d times, want 1, later reads must be local hits", remote.gets[1])
+	}
+}
+
+func TestLocalEviction(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		reads      []int
+		wantRemote map[int]int
+	}{
+		{name: "fits", size: 3, reads: []int{1, 2, 3, 1, 2, 3}, wantRemote: map[int]int{1: 1, 2: 1, 3: 1}},
+		{name: "least recently used goes", size: 2, reads: []int{1, 2, 3, 1}, wantRemote: map[int]int{1: 2, 2: 1, 3: 1}},
+		{name: "read keeps entry", size: 2, reads: []int{1, 2, 1, 3, 1, 2}, wantRemote: map[int]int{1: 1, 2: 2, 3: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := newFakeRemote(&domain.User{ID: 1}, &domain.User{ID: 2}, &domain.User{ID: 3})
+			c := New(remote, nil, nil, tt.size, time.Minute, nopRecorder{})
+			for _, id := range tt.reads {
+				if _, err := c.GetByID(context.Background(), id); err != nil {
+					t.Fatalf("GetByID(%d) error = %v", id, err)
+				}
+			}
+			if !reflect.DeepEqual(remote.gets, tt.wantRemote) {
+				t.Errorf("remote reads = %v, want %v", remote.gets, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func newRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// A read that got the old user from remote before an update must not keep it locally
+// after the update, our own invalidation message is skipped and would not fix it.
+func TestWriteDuringRemoteRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(c *UserCache) error
+		wantName string // "" for deleted
+	}{
+		{
+			name: "update",
+			write: func(c *UserCache) error {
+				return c.UpdateByID(context.Background(), &domain.UserUpdate{Name: "new"}, 1)
+			},
+			wantName: "new",
+		},
+		{
+			name:  "delete",
+			write: func(c *UserCache) error { return c.DeleteByID(context.Background(), 1) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := newFakeRemote(&domain.User{ID: 1, Name: "old"})
+			c := New(remote, newRedis(t), breaker.New("test", 1, time.Hour), 10, time.Minute, nopRecorder{})
+
+			read, release := make(chan struct{}), make(chan struct{})
+			remote.onGet = func() {
+				close(read)
+				<-release
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if u, err := c.GetByID(context.Background(), 1); err != nil || u.Name != "old" {
+					t.Errorf("racing GetByID() = %+v, %v, want the old user", u, err)
+				}
+			}()
+			<-read
+			remote.mu.Lock()
+			remote.onGet = nil
+			remote.mu.Unlock()
+			if err := tt.write(c); err != nil {
+				t.Fatal(err)
+			}
+			close(release)
+			<-done
+
+			u, err := c.GetByID(context.Background(), 1)
+			if tt.wantName == "" {
+				if !errors.Is(err, domain.ErrUserNotFound) {
+					t.Errorf("GetByID() = %+v, %v, want %v", u, err, domain.ErrUserNotFound)
+				}
+				return
+			}
+			if err != nil || u.Name != tt.wantName {
+				t.Errorf("GetByID() = %+v, %v, want name %s", u, err, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestPublishInvalidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		write       func(c *UserCache) error
+		breakerOpen bool
+		wantPublish bool
+	}{
+		{name: "set", write: func(c *UserCache) error { return c.Set(context.Background(), &domain.User{ID: 1}) }, wantPublish: true},
+		{name: "update", write: func(c *UserCache) error {
+			return c.UpdateByID(context.Background(), &domain.UserUpdate{Name: "new"}, 1)
+		}, wantPublish: true},
+		{name: "delete", write: func(c *UserCache) error { return c.DeleteByID(context.Background(), 1) }, wantPublish: true},
+		{name: "breaker open", write: func(c *UserCache) error { return c.DeleteByID(context.Background(), 1) }, breakerOpen: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newRedis(t)
+			ctx := context.Background()
+			sub := client.Subscribe(ctx, invalidationChannel)
+			defer sub.Close()
+			if _, err := sub.Receive(ctx); err != nil { // subscription confirmed
+				t.Fatal(err)
+			}
+
+			b := breaker.New("test", 1, time.Hour)
+			if tt.breakerOpen {
+				b.Failure()
+			}
+			c := New(newFakeRemote(&domain.User{ID: 1}), client, b, 10, time.Minute, nopRecorder{})
+			if err := tt.write(c); err != nil {
+				t.Fatal(err)
+			}
+
+			timeout := time.Second
+			if !tt.wantPublish {
+				timeout = 100 * time.Millisecond
+			}
+			msg, err := sub.ReceiveTimeout(ctx, timeout)
+			if published := err == nil; published != tt.wantPublish {
+				t.Fatalf("published = %v (%v, %v), want %v", published, msg, err, tt.wantPublish)
+			}
+			if tt.breakerOpen && b.State() != breaker.Open {
+				t.Errorf("breaker state = %v, want it left open", b.State())
+			}
+		})
+	}
+}
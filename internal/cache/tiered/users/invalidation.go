@@ -0,0 +1,73 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+)
+
+const invalidationChannel = "users:invalidate"
+
+type invalidation struct {
+	Instance string `json:"instance"`
+	UserID   int    `json:"user_id"`
+}
+
+// publishInvalidation is skipped while the breaker is open, other replicas' local ttl bounds staleness then.
+func (c *UserCache) publishInvalidation(ctx context.Context, id int) {
+	data, err := json.Marshal(invalidation{Instance: c.instance, UserID: id})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to marshal invalidation", "error", err)
+		return
+	}
+	if err := c.breaker.Allow(); err != nil {
+		slog.DebugContext(ctx, "Invalidation not published, redis is unavailable", "user_id", id)
+		return
+	}
+	err = c.client.Publish(ctx, invalidationChannel, data).Err()
+	switch {
+	case err == nil:
+		c.breaker.Success()
+	case errors.Is(err, context.Canceled):
+		c.breaker.Release()
+	default:
+		c.breaker.Failure()
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to publish invalidation", "user_id", id, "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "Invalidation published", "user_id", id)
+}
+
+// Listen drops local entries invalidated by other replicas, blocks until ctx is done.
+// Messages published while the subscription is reconnecting are lost,
+// local ttl is what bounds staleness in that case, so keep it short.
+func (c *UserCache) Listen(ctx context.Context) {
+	pubsub := c.client.Subscribe(ctx, invalidationChannel)
+	defer pubsub.Close()
+
+	slog.InfoContext(ctx, "Listening for user cache invalidations", "instance", c.instance)
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				slog.ErrorContext(ctx, "Failed to unmarshal invalidation", "error", err)
+				continue
+			}
+			if inv.Instance == c.instance {
+				continue
+			}
+			c.invalidate(inv.UserID)
+			slog.DebugContext(ctx, "User invalidated in local cache", "user_id", inv.UserID)
+		}
+	}
+}
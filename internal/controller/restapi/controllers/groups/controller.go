@@ -0,0 +1,322 @@
+package groupsController
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/groups/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"strconv"
+)
+
+type AccessService interface {
+	Permissions(ctx context.Context) ([]domain.Permission, error)
+	ListGroups(ctx context.Context) ([]*domain.Group, error)
+	GetGroup(ctx context.Context, id int) (*domain.Group, error)
+	CreateGroup(ctx context.Context, name string, perms []domain.Permission) (*domain.Group, error)
+	UpdateGroup(ctx context.Context, id int, name string, perms []domain.Permission) (*domain.Group, error)
+	DeleteGroup(ctx context.Context, id int) error
+	AddGroupMember(ctx context.Context, groupID, userID int) error
+	RemoveGroupMember(ctx context.Context, groupID, userID int) error
+	GetValidator() *validator.Validate
+}
+
+type GroupController struct {
+	service AccessService
+}
+
+func New(service AccessService) *GroupController {
+	return &GroupController{service: service}
+}
+
+// Permissions godoc
+// @Summary      My permissions
+// @Description  Effective permissions of the caller in the current organization: the ones of their role and groups
+// @Tags         groups
+// @Security  BearerAuth
+// @Produce      json
+// @Success      200  {object}  daos.PermissionsDAO
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /permissions [get]
+func (c *GroupController) Permissions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	perms, err := c.service.Permissions(ctx)
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToPermissionsDAO(perms)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// List godoc
+// @Summary      List groups
+// @Description  Groups of the current organization with their permissions and members, needs groups:read
+// @Tags         groups
+// @Security  BearerAuth
+// @Produce      json
+// @Success      200  {object}  daos.GroupListDAO
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /groups [get]
+func (c *GroupController) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	groups, err := c.service.ListGroups(ctx)
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToGroupListDAO(groups)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetByID godoc
+// @Summary      Get group
+// @Description  Needs groups:read
+// @Tags         groups
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id   path      int  true  "Group ID"
+// @Success      200  {object}  daos.GroupDAO
+// @Failure      400  {object}  rest_errors.ResponseError
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      404  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /groups/{id} [get]
+func (c *GroupController) GetByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	group, err := c.service.GetGroup(ctx, id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToGroupDAO(group)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Create godoc
+// @Summary      Create group
+// @Description  Needs groups:write. Only permissions the caller has can be given.
+// @Tags         groups
+// @Security  BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      daos.GroupInputDAO  true  "Group"
+// @Success      201    {object}  daos.GroupDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      403    {object}  rest_errors.ResponseError
+// @Failure      409    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Router       /groups [post]
+func (c *GroupController) Create(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	var input daos.GroupInputDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	if err := input.ValidateWith(c.service.GetValidator()); err != nil {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	group, err := c.service.CreateGroup(ctx, input.Name, input.ToPermissions())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(daos.ToGroupDAO(group)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Update godoc
+// @Summary      Update group
+// @Description  Replaces name and permissions, needs groups:write and every old and new permission of the group
+// @Tags         groups
+// @Security  BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                 true  "Group ID"
+// @Param        input  body      daos.GroupInputDAO  true  "Group"
+// @Success      200    {object}  daos.GroupDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      403    {object}  rest_errors.ResponseError
+// @Failure      404    {object}  rest_errors.ResponseError
+// @Failure      409    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Router       /groups/{id} [put]
+func (c *GroupController) Update(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	var input daos.GroupInputDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	if err := input.ValidateWith(c.service.GetValidator()); err != nil {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	group, err := c.service.UpdateGroup(ctx, id, input.Name, input.ToPermissions())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToGroupDAO(group)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Delete godoc
+// @Summary      Delete group
+// @Description  Needs groups:write, members lose the permissions of the group
+// @Tags         groups
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id   path      int  true  "Group ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  rest_errors.ResponseError
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      404  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /groups/{id} [delete]
+func (c *GroupController) Delete(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	if err := c.service.DeleteGroup(ctx, id); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddMember godoc
+// @Summary      Add group member
+// @Description  Needs groups:write and every permission of the group. The user must be a member of the organization.
+// @Tags         groups
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id       path      int  true  "Group ID"
+// @Param        user_id  path      int  true  "User ID"
+// @Success      204      "No Content"
+// @Failure      400      {object}  rest_errors.ResponseError
+// @Failure      401      {object}  rest_errors.ResponseError
+// @Failure      403      {object}  rest_errors.ResponseError
+// @Failure      404      {object}  rest_errors.ResponseError
+// @Failure      500      {object}  rest_errors.ResponseError
+// @Router       /groups/{id}/members/{user_id} [put]
+func (c *GroupController) AddMember(w http.ResponseWriter, r *http.Request) {
+	c.changeMember(w, r, c.service.AddGroupMember)
+}
+
+// RemoveMember godoc
+// @Summary      Remove group member
+// @Description  Needs groups:write
+// @Tags         groups
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id       path      int  true  "Group ID"
+// @Param        user_id  path      int  true  "User ID"
+// @Success      204      "No Content"
+// @Failure      400      {object}  rest_errors.ResponseError
+// @Failure      401      {object}  rest_errors.ResponseError
+// @Failure      403      {object}  rest_errors.ResponseError
+// @Failure      404      {object}  rest_errors.ResponseError
+// @Failure      500      {object}  rest_errors.ResponseError
+// @Router       /groups/{id}/members/{user_id} [delete]
+func (c *GroupController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	c.changeMember(w, r, c.service.RemoveGroupMember)
+}
+
+func (c *GroupController) changeMember(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, groupID, userID int) error) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	if err := change(ctx, groupID, userID); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnknownPermission):
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+	case errors.Is(err, domain.ErrPermissionDenied):
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+	case errors.Is(err, domain.ErrGroupNotFound), errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrNotInGroup):
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+	case errors.Is(err, domain.ErrGroupExists):
+		rest_errors.HandleError(w, err, http.StatusConflict)
+	default:
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+	}
+}
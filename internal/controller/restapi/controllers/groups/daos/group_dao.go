@@ -0,0 +1,74 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"time"
+)
+
+type GroupInputDAO struct {
+	Name        string   `json:"name" validate:"required,max=100" example:"Support"`
+	Permissions []string `json:"permissions" validate:"max=50,dive,required" example:"users:read,users:write"`
+}
+
+func (dao *GroupInputDAO) ValidateWith(v *validator.Validate) error {
+	return v.Struct(dao)
+}
+
+func (dao *GroupInputDAO) ToPermissions() []domain.Permission {
+	perms := make([]domain.Permission, len(dao.Permissions))
+	for i, p := range dao.Permissions {
+		perms[i] = domain.Permission(p)
+	}
+	return perms
+}
+
+type GroupDAO struct {
+	ID          int       `json:"id" example:"1"`
+	Name        string    `json:"name" example:"Support"`
+	Permissions []string  `json:"permissions" example:"users:read,users:write"`
+	MemberIDs   []int     `json:"member_ids" example:"2,3"`
+	CreatedAt   time.Time `json:"created_at" example:"2025-01-01T12:00:00Z"`
+}
+
+func ToGroupDAO(g *domain.Group) *GroupDAO {
+	perms := make([]string, len(g.Permissions))
+	for i, p := range g.Permissions {
+		perms[i] = string(p)
+	}
+	members := g.MemberIDs
+	if members == nil {
+		members = []int{}
+	}
+	return &GroupDAO{
+		ID:          g.ID,
+		Name:        g.Name,
+		Permissions: perms,
+		MemberIDs:   members,
+		CreatedAt:   g.CreatedAt,
+	}
+}
+
+type GroupListDAO struct {
+	Groups []GroupDAO `json:"groups"`
+}
+
+func ToGroupListDAO(groups []*domain.Group) *GroupListDAO {
+	out := make([]GroupDAO, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *ToGroupDAO(g))
+	}
+	return &GroupListDAO{Groups: out}
+}
+
+type PermissionsDAO struct {
+	Permissions []string `json:"permissions" example:"users:read,groups:read"`
+}
+
+func ToPermissionsDAO(perms []domain.Permission) *PermissionsDAO {
+	out := make([]string, len(perms))
+	for i, p := range perms {
+		out[i] = string(p)
+	}
+	return &PermissionsDAO{Permissions: out}
+}
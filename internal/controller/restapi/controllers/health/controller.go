@@ -0,0 +1,60 @@
+package healthController
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/health/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"net/http"
+)
+
+type HealthService interface {
+	Ready(ctx context.Context) *domain.Readiness
+}
+
+type HealthController struct {
+	service HealthService
+}
+
+func New(service HealthService) *HealthController {
+	return &HealthController{service: service}
+}
+
+// Healthz godoc
+// @Summary      Liveness probe
+// @Description  The process is up and serving, dependencies are not checked.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  daos.LivenessDAO
+// @Router       /healthz [get]
+func (c *HealthController) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(daos.LivenessDAO{Status: "ok"}); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  Checks postgres and redis. Redis is optional, the instance is degraded but ready without it. Not ready while shutting down.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  daos.ReadinessDAO
+// @Failure      503  {object}  daos.ReadinessDAO
+// @Router       /readyz [get]
+func (c *HealthController) Readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// probes must not be served from any cache
+	w.Header().Set("Cache-Control", "no-store")
+
+	res := c.service.Ready(r.Context())
+	if !res.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(daos.ToReadinessDAO(res)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
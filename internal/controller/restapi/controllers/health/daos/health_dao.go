@@ -0,0 +1,31 @@
+package daos
+
+import "github.com/Arh0rn/test-task1/internal/domain"
+
+type LivenessDAO struct {
+	Status string `json:"status" example:"ok"`
+}
+
+type DependencyDAO struct {
+	Status     string `json:"status" example:"up"`
+	Optional   bool   `json:"optional,omitempty" example:"false"`
+	DurationMS int64  `json:"duration_ms" example:"2"`
+}
+
+type ReadinessDAO struct {
+	// up, degraded (an optional dependency is down), down or shutting_down
+	Status       string                   `json:"status" example:"up"`
+	Dependencies map[string]DependencyDAO `json:"dependencies"`
+}
+
+func ToReadinessDAO(r *domain.Readiness) *ReadinessDAO {
+	deps := make(map[string]DependencyDAO, len(r.Dependencies))
+	for name, d := range r.Dependencies {
+		deps[name] = DependencyDAO{
+			Status:     string(d.Status),
+			Optional:   d.Optional,
+			DurationMS: d.Duration.Milliseconds(),
+		}
+	}
+	return &ReadinessDAO{Status: string(r.Status), Dependencies: deps}
+}
@@ -0,0 +1,204 @@
+package orgsController
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/orgs/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"strconv"
+)
+
+type OrgService interface {
+	List(ctx context.Context) ([]*domain.Membership, error)
+	Create(ctx context.Context, name string) (*domain.Membership, error)
+	Switch(ctx context.Context, orgID int) (*domain.Tokens, error)
+	SetRole(ctx context.Context, orgID, userID int, role domain.Role) (*domain.Membership, error)
+	GetValidator() *validator.Validate
+}
+
+type OrgController struct {
+	service OrgService
+}
+
+func New(service OrgService) *OrgController {
+	return &OrgController{service: service}
+}
+
+// List godoc
+// @Summary      List my organizations
+// @Description  Organizations the caller is a member of with their role, the oldest membership first
+// @Tags         orgs
+// @Security  BearerAuth
+// @Produce      json
+// @Success      200  {object}  daos.MembershipListDAO
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /orgs [get]
+func (c *OrgController) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	memberships, err := c.service.List(ctx)
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToMembershipListDAO(memberships)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Create godoc
+// @Summary      Create organization
+// @Description  Creates an organization owned by the caller. The token stays in the current one, switch to use the new one.
+// @Tags         orgs
+// @Security  BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      daos.OrgInputDAO  true  "Organization"
+// @Success      201    {object}  daos.MembershipDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Router       /orgs [post]
+func (c *OrgController) Create(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	var input daos.OrgInputDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	if err := input.ValidateWith(c.service.GetValidator()); err != nil {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	m, err := c.service.Create(ctx, input.Name)
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(daos.ToMembershipDAO(m)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Switch godoc
+// @Summary      Switch organization
+// @Description  Issues a new token for another organization of the caller, user endpoints work in that one then
+// @Tags         orgs
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id   path      int  true  "Organization ID"
+// @Success      200  {object}  daos.OrgTokenDAO
+// @Failure      400  {object}  rest_errors.ResponseError
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      404  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /orgs/{id}/switch [post]
+func (c *OrgController) Switch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.service.Switch(ctx, id)
+	if errors.Is(err, domain.ErrOrgNotFound) {
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, domain.ErrSessionRevoked) {
+		rest_errors.HandleError(w, err, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(&daos.OrgTokenDAO{Token: tokens.AccessToken}); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// SetRole godoc
+// @Summary      Change member role
+// @Description  Admins can make members admins and back, only owners can grant or take the owner role.
+// @Description  An organization always keeps at least one owner.
+// @Tags         orgs
+// @Security  BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                true  "Organization ID"
+// @Param        user_id  path      int                true  "User ID"
+// @Param        input    body      daos.RoleInputDAO  true  "Role"
+// @Success      200      {object}  daos.MembershipDAO
+// @Failure      400      {object}  rest_errors.ResponseError
+// @Failure      401      {object}  rest_errors.ResponseError
+// @Failure      403      {object}  rest_errors.ResponseError
+// @Failure      404      {object}  rest_errors.ResponseError
+// @Failure      409      {object}  rest_errors.ResponseError
+// @Failure      500      {object}  rest_errors.ResponseError
+// @Router       /orgs/{id}/members/{user_id} [put]
+func (c *OrgController) SetRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	orgID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	var input daos.RoleInputDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	if err := input.ValidateWith(c.service.GetValidator()); err != nil {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	m, err := c.service.SetRole(ctx, orgID, userID, domain.Role(input.Role))
+	switch {
+	case errors.Is(err, domain.ErrOrgNotFound), errors.Is(err, domain.ErrNotMember):
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrForbidden):
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrLastOwner):
+		rest_errors.HandleError(w, err, http.StatusConflict)
+		return
+	case err != nil:
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToMembershipDAO(m)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
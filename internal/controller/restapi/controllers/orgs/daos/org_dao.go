@@ -0,0 +1,57 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"time"
+)
+
+type OrgInputDAO struct {
+	Name string `json:"name" validate:"required,max=200" example:"Acme Inc."`
+}
+
+func (dao *OrgInputDAO) ValidateWith(v *validator.Validate) error {
+	return v.Struct(dao)
+}
+
+type RoleInputDAO struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member" example:"admin"`
+}
+
+func (dao *RoleInputDAO) ValidateWith(v *validator.Validate) error {
+	return v.Struct(dao)
+}
+
+type MembershipDAO struct {
+	OrgID    int       `json:"org_id" example:"1"`
+	OrgName  string    `json:"org_name,omitempty" example:"Acme Inc."`
+	UserID   int       `json:"user_id" example:"1"`
+	Role     string    `json:"role" example:"owner"`
+	JoinedAt time.Time `json:"joined_at" example:"2025-01-01T12:00:00Z"`
+}
+
+func ToMembershipDAO(m *domain.Membership) *MembershipDAO {
+	return &MembershipDAO{
+		OrgID:    m.Org.ID,
+		OrgName:  m.Org.Name,
+		UserID:   m.UserID,
+		Role:     string(m.Role),
+		JoinedAt: m.CreatedAt,
+	}
+}
+
+type MembershipListDAO struct {
+	Orgs []MembershipDAO `json:"orgs"`
+}
+
+func ToMembershipListDAO(memberships []*domain.Membership) *MembershipListDAO {
+	orgs := make([]MembershipDAO, 0, len(memberships))
+	for _, m := range memberships {
+		orgs = append(orgs, *ToMembershipDAO(m))
+	}
+	return &MembershipListDAO{Orgs: orgs}
+}
+
+type OrgTokenDAO struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,118 @@
+package sessionsController
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/sessions/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"net/http"
+)
+
+type SessionService interface {
+	List(ctx context.Context) ([]*domain.Session, string, error)
+	Revoke(ctx context.Context, id string) error
+	Refresh(ctx context.Context, token string) (*domain.Tokens, error)
+}
+
+type SessionController struct {
+	service SessionService
+}
+
+func New(service SessionService) *SessionController {
+	return &SessionController{service: service}
+}
+
+// List godoc
+// @Summary      List my sessions
+// @Description  Live sessions of the caller, one per login, the last used first. current marks the one of the token.
+// @Tags         sessions
+// @Security  BearerAuth
+// @Produce      json
+// @Success      200  {object}  daos.SessionListDAO
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /me/sessions [get]
+func (c *SessionController) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	sessions, current, err := c.service.List(ctx)
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToSessionListDAO(sessions, current)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Revoke godoc
+// @Summary      Revoke session
+// @Description  Logs a device out, access and refresh tokens of the session stop working. Revoking the current session is a logout.
+// @Tags         sessions
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id   path      string  true  "Session ID"
+// @Success      204  "No Content"
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      404  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /me/sessions/{id} [delete]
+func (c *SessionController) Revoke(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	err := c.service.Revoke(ctx, r.PathValue("id"))
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Refresh godoc
+// @Summary      Refresh tokens
+// @Description  Trades a refresh token for a new access token and a new refresh token of the same session. A refresh token works once, using it again revokes the session.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      daos.RefreshInputDAO  true  "Refresh token"
+// @Success      200    {object}  daos.TokensDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Router       /token/refresh [post]
+func (c *SessionController) Refresh(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	var input daos.RefreshInputDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.service.Refresh(ctx, input.RefreshToken)
+	if errors.Is(err, domain.ErrInvalidRefreshToken) {
+		rest_errors.HandleError(w, err, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToTokensDAO(tokens)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
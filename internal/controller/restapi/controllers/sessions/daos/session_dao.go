@@ -0,0 +1,59 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"time"
+)
+
+type SessionDAO struct {
+	ID         string    `json:"id" example:"0b6f7a52-3c1e-4a59-9a43-5f0d4c8e2f11"`
+	OrgID      int       `json:"org_id,omitempty" example:"1"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	CreatedAt  time.Time `json:"created_at" example:"2025-01-01T12:00:00Z"`
+	LastSeenAt time.Time `json:"last_seen_at" example:"2025-01-02T08:30:00Z"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2025-01-09T08:30:00Z"`
+	// Current is the session of the token the list was requested with
+	Current bool `json:"current" example:"true"`
+}
+
+func ToSessionDAO(s *domain.Session, current string) *SessionDAO {
+	return &SessionDAO{
+		ID:         s.ID,
+		OrgID:      s.OrgID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == current,
+	}
+}
+
+type SessionListDAO struct {
+	Sessions []SessionDAO `json:"sessions"`
+}
+
+func ToSessionListDAO(sessions []*domain.Session, current string) *SessionListDAO {
+	out := make([]SessionDAO, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, *ToSessionDAO(s, current))
+	}
+	return &SessionListDAO{Sessions: out}
+}
+
+type RefreshInputDAO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokensDAO struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func ToTokensDAO(tokens *domain.Tokens) *TokensDAO {
+	return &TokensDAO{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+}
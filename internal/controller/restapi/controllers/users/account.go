@@ -0,0 +1,95 @@
+package usersController
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"net/http"
+)
+
+// DeleteMe godoc
+// @Summary      Delete my account
+// @Description  Confirms with the current password and deletes the account after the grace period. All sessions are revoked, logging in before delete_after cancels the deletion. Sole owners of organizations with other members must hand them over first.
+// @Tags         account
+// @Security  BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      daos.DeleteAccountDAO  true  "Password"
+// @Success      202    {object}  daos.AccountDeletionDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      403    {object}  rest_errors.ResponseError
+// @Failure      409    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Router       /me [delete]
+func (c *UserController) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	var input daos.DeleteAccountDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	deleteAfter, err := c.service.ScheduleDeletion(ctx, input.Password)
+	if errors.Is(err, domain.ErrInvalidCredentials) {
+		rest_errors.HandleError(w, err, http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, domain.ErrImpersonating) {
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, domain.ErrLastOwner) {
+		rest_errors.HandleError(w, err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(&daos.AccountDeletionDAO{DeleteAfter: deleteAfter}); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// ExportMe godoc
+// @Summary      Export my data
+// @Description  Downloads everything stored about the caller as JSON: profile, organizations, sessions and audit events about them
+// @Tags         account
+// @Security  BearerAuth
+// @Produce      json
+// @Success      200  {object}  daos.AccountExportDAO
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /me/export [get]
+func (c *UserController) ExportMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	export, err := c.service.ExportAccount(ctx)
+	if errors.Is(err, domain.ErrImpersonating) {
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%d.json"`, export.Account.ID))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(daos.ToAccountExportDAO(export)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
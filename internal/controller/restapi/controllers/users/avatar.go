@@ -0,0 +1,202 @@
+package usersController
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const avatarField = "avatar"
+
+var (
+	errAvatarMissing = errors.New("multipart field avatar is required")
+	errAvatarType    = errors.New("avatar must be a jpeg, png, gif or webp image")
+	errAvatarLarge   = errors.New("avatar is too large")
+)
+
+var avatarTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// SetAvatar godoc
+// @Summary      Upload user avatar
+// @Description  Takes a jpeg, png, gif or webp image in multipart field "avatar". It is stored as jpeg together with
+// @Description  square thumbnails of avatar.sizes, avatar_url of the user points at the new picture.
+// @Description  users:write is needed for anyone but yourself.
+// @Tags         users
+// @Security  BearerAuth
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id      path      int   true  "User ID"
+// @Param        avatar  formData  file  true  "Image"
+// @Success      200     {object}  daos.UserOutputDAO
+// @Failure      400     {object}  rest_errors.ResponseError
+// @Failure      401     {object}  rest_errors.ResponseError
+// @Failure      403     {object}  rest_errors.ResponseError
+// @Failure      404     {object}  rest_errors.ResponseError
+// @Failure      413     {object}  rest_errors.ResponseError
+// @Failure      415     {object}  rest_errors.ResponseError
+// @Failure      500     {object}  rest_errors.ResponseError
+// @Router       /users/{id}/avatar [put]
+func (c *UserController) SetAvatar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	if !c.authorize(w, r, id, domain.PermUsersWrite) {
+		return
+	}
+
+	image, status, err := c.readAvatar(w, r)
+	if err != nil {
+		rest_errors.HandleError(w, err, status)
+		return
+	}
+
+	user, err := c.service.SetAvatar(ctx, id, image)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidAvatar) {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToUserOutputDAO(user)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAvatar godoc
+// @Summary      Get user avatar
+// @Description  Serves the avatar as jpeg, the original or a square thumbnail of one of avatar.sizes.
+// @Description  Requests with v (as in avatar_url) are cached for a year, content of a version never changes.
+// @Description  Like the user, only for members of the organization, users:read is needed for anyone but yourself.
+// @Tags         users
+// @Security  BearerAuth
+// @Produce      jpeg
+// @Param        id    path      int     true   "User ID"
+// @Param        size  query     int     false  "Thumbnail size"
+// @Param        v     query     string  false  "Avatar version"
+// @Success      200   {file}    file
+// @Success      304   "Not Modified"
+// @Failure      400   {object}  rest_errors.ResponseError
+// @Failure      401   {object}  rest_errors.ResponseError
+// @Failure      403   {object}  rest_errors.ResponseError
+// @Failure      404   {object}  rest_errors.ResponseError
+// @Failure      500   {object}  rest_errors.ResponseError
+// @Router       /users/{id}/avatar [get]
+func (c *UserController) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	size := 0
+	if s := r.URL.Query().Get("size"); s != "" {
+		if size, err = strconv.Atoi(s); err != nil {
+			rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+			return
+		}
+	}
+	if !c.authorize(w, r, id, domain.PermUsersRead) {
+		return
+	}
+
+	blob, err := c.service.GetAvatar(ctx, id, size)
+	if errors.Is(err, domain.ErrAvatarSize) {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, domain.ErrAvatarNotFound) || errors.Is(err, domain.ErrUserNotFound) {
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(blob.Data)
+	w.Header().Set("Content-Type", blob.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	if r.URL.Query().Has("v") {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(c.avatarCfg.CacheMaxAge.Seconds())))
+	}
+
+	// handles If-None-Match, If-Modified-Since and ranges
+	http.ServeContent(w, r, "", blob.ModTime, bytes.NewReader(blob.Data))
+}
+
+// readAvatar returns the image part of the upload, or the error and status to respond with.
+func (c *UserController) readAvatar(w http.ResponseWriter, r *http.Request) ([]byte, int, error) {
+	// room for multipart headers and boundaries on top of the image
+	r.Body = http.MaxBytesReader(w, r.Body, c.avatarCfg.MaxUploadBytes+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, http.StatusBadRequest, errAvatarMissing
+		}
+		if err != nil {
+			return nil, multipartErrorStatus(err), err
+		}
+		if part.FormName() != avatarField {
+			continue
+		}
+
+		declared, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if !slices.Contains(avatarTypes, declared) {
+			return nil, http.StatusUnsupportedMediaType, errAvatarType
+		}
+
+		image, err := io.ReadAll(io.LimitReader(part, c.avatarCfg.MaxUploadBytes+1))
+		if err != nil {
+			return nil, multipartErrorStatus(err), err
+		}
+		if int64(len(image)) > c.avatarCfg.MaxUploadBytes {
+			return nil, http.StatusRequestEntityTooLarge, errAvatarLarge
+		}
+		// the declared type is up to the client, the bytes have to agree
+		if http.DetectContentType(image) != declared {
+			return nil, http.StatusUnsupportedMediaType, errAvatarType
+		}
+		return image, 0, nil
+	}
+}
+
+func multipartErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -7,38 +7,61 @@ import (
 	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users/daos"
 	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
 	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/config"
 	"github.com/go-playground/validator/v10"
+	"iter"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type UserService interface {
 	SignUp(context.Context, *domain.SignUpInput) (*domain.User, error)
-	Login(ctx context.Context, email, password string) (string, error)
-	GetAll(context.Context) ([]*domain.User, error)
+	Login(ctx context.Context, email, password string, orgID int) (*domain.Tokens, error)
+	GetAll(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, error)
+	Search(ctx context.Context, query *domain.UserSearchQuery) (*domain.UserSearchResult, error)
 	GetByID(ctx context.Context, id int) (*domain.User, error)
 	UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) (*domain.UserUpdate, error)
 	DeleteByID(ctx context.Context, id int) error
+	Impersonate(ctx context.Context, id int) (*domain.Tokens, error)
+	ScheduleDeletion(ctx context.Context, password string) (time.Time, error)
+	ExportAccount(ctx context.Context) (*domain.AccountExport, error)
+	SetAvatar(ctx context.Context, id int, image []byte) (*domain.User, error)
+	GetAvatar(ctx context.Context, id int, size int) (*domain.Blob, error)
+	Import(ctx context.Context, rows iter.Seq2[*domain.ImportRow, error]) (*domain.ImportReport, error)
+	StartImport(ctx context.Context, rows iter.Seq2[*domain.ImportRow, error]) (*domain.ImportJob, error)
+	GetImportJob(ctx context.Context, id string) (*domain.ImportJob, error)
+	Export(ctx context.Context, fn func(*domain.User) error) error
+	Invite(ctx context.Context, input *domain.InvitationInput) (*domain.Invitation, error)
+	ListInvitations(ctx context.Context) ([]*domain.Invitation, error)
+	RevokeInvitation(ctx context.Context, id string) error
+	AcceptInvitation(ctx context.Context, token string, input *domain.AcceptInvitationInput) (*domain.Tokens, error)
+	MetadataSchema() json.RawMessage
 	GetValidator() *validator.Validate
 }
 
 type UserController struct {
-	service UserService
+	service   UserService
+	access    Authorizer
+	importCfg *config.Import
+	avatarCfg *config.Avatar
 }
 
-func New(service UserService) *UserController {
-	return &UserController{service: service}
+func New(service UserService, access Authorizer, importCfg *config.Import, avatarCfg *config.Avatar) *UserController {
+	return &UserController{service: service, access: access, importCfg: importCfg, avatarCfg: avatarCfg}
 }
 
 // SignUp godoc
 // @Summary      Register new user
-// @Description  Creates a new user with name, email, and password
+// @Description  Creates a new user with name, email, and password, and an organization owned by them.
+// @Description  403 when sign up is closed (sign-up.open: false), users join by invitation then.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        input  body      daos.SignUpInputDAO  true  "User sign up input"
 // @Success      201    {object}  daos.UserOutputDAO
 // @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      403    {object}  rest_errors.ResponseError
 // @Failure      409    {object}  rest_errors.ResponseError
 // @Failure      500    {object}  rest_errors.ResponseError
 // @Router       /users [post]
@@ -60,6 +83,14 @@ func (c *UserController) SignUp(w http.ResponseWriter, r *http.Request) {
 	singUpInput := signUpInputDao.ToSignUpInput()
 
 	user, err := c.service.SignUp(ctx, singUpInput)
+	if errors.Is(err, domain.ErrSignUpDisabled) {
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidMetadata) {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
 	if errors.Is(err, domain.ErrUserAlreadyExists) {
 		rest_errors.HandleError(w, err, http.StatusConflict) // 409
 		return
@@ -78,9 +109,30 @@ func (c *UserController) SignUp(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// MetadataSchema godoc
+// @Summary      User metadata schema
+// @Description  JSON Schema the metadata of sign up and update must match, clients can build forms from it
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  object
+// @Router       /users/metadata-schema [get]
+func (c *UserController) MetadataSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+
+	schema := c.service.MetadataSchema()
+	if schema == nil {
+		schema = json.RawMessage(`{"type":"object"}`)
+	}
+
+	if _, err := w.Write(schema); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
 // Login godoc
 // @Summary      User login
-// @Description  Authenticates a user and returns JWT token
+// @Description  Authenticates a user, starts a session and returns its JWT and refresh token for org_id, or for the organization joined first
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -88,6 +140,7 @@ func (c *UserController) SignUp(w http.ResponseWriter, r *http.Request) {
 // @Success      200    {object}  daos.TokenDAO
 // @Failure      400    {object}  rest_errors.ResponseError
 // @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      403    {object}  rest_errors.ResponseError
 // @Failure      422    {object}  rest_errors.ResponseError
 // @Failure      500    {object}  rest_errors.ResponseError
 // @Router       /login [post]
@@ -109,17 +162,21 @@ func (c *UserController) Login(w http.ResponseWriter, r *http.Request) {
 
 	LoginInput := LoginDao.ToLoginInput()
 
-	token, err := c.service.Login(ctx, LoginInput.Email, LoginInput.Password)
+	tokens, err := c.service.Login(ctx, LoginInput.Email, LoginInput.Password, LoginInput.OrgID)
 	if errors.Is(err, domain.ErrInvalidCredentials) {
 		rest_errors.HandleError(w, err, http.StatusUnauthorized)
 		return
 	}
+	if errors.Is(err, domain.ErrNotMember) {
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		rest_errors.HandleError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	tokenOutput := daos.ToTokenDAO(token)
+	tokenOutput := daos.ToTokenDAO(tokens)
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(tokenOutput); err != nil {
@@ -130,24 +187,43 @@ func (c *UserController) Login(w http.ResponseWriter, r *http.Request) {
 
 // GetAll godoc
 // @Summary      Get all users
+// @Description  Returns a page of users of the current organization ordered by ID
 // @Tags         users
 // @Security  BearerAuth
 // @Produce      json
-// @Success      200  {array}   daos.UserOutputDAO
+// @Param        limit   query     int  false  "Page size (1-100)"  default(20)
+// @Param        offset  query     int  false  "Number of users to skip"  default(0)
+// @Success      200  {object}  daos.UserListDAO
+// @Failure      400  {object}  rest_errors.ResponseError
 // @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
 // @Failure      500  {object}  rest_errors.ResponseError
 // @Router       /users [get]
 func (c *UserController) GetAll(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	ctx := r.Context()
 
-	users, err := c.service.GetAll(ctx)
+	queryDao, err := daos.ParseUserListQueryDAO(r.URL.Query())
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	v := c.service.GetValidator()
+	if err := queryDao.ValidateWith(v); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	query := queryDao.ToUserListQuery()
+
+	users, err := c.service.GetAll(ctx, query)
 	if err != nil {
 		rest_errors.HandleError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	UserListOutput := daos.ToUserListDAO(users)
+	UserListOutput := daos.ToUserListDAO(users, query)
 
 	if err := json.NewEncoder(w).Encode(UserListOutput); err != nil {
 		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
@@ -155,9 +231,57 @@ func (c *UserController) GetAll(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Search godoc
+// @Summary      Search users
+// @Description  Finds users by partial or misspelled name or email, best matches first.
+// @Description  Matched fragments are wrapped in <mark> in highlight fields, the rest of the text is html escaped.
+// @Tags         users
+// @Security  BearerAuth
+// @Produce      json
+// @Param        q       query     string  true   "Part of name or email, 2-100 characters"
+// @Param        limit   query     int     false  "Page size (1-100)"  default(20)
+// @Param        offset  query     int     false  "Number of users to skip"  default(0)
+// @Success      200  {object}  daos.UserSearchDAO
+// @Failure      400  {object}  rest_errors.ResponseError
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /users/search [get]
+func (c *UserController) Search(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	queryDao, err := daos.ParseUserSearchQueryDAO(r.URL.Query())
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	v := c.service.GetValidator()
+	if err := queryDao.ValidateWith(v); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	query := queryDao.ToUserSearchQuery()
+
+	result, err := c.service.Search(ctx, query)
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	searchOutput := daos.ToUserSearchDAO(result, query)
+
+	if err := json.NewEncoder(w).Encode(searchOutput); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
 // GetByID godoc
 // @Summary      Get user by ID
-// @Description  Retrieves a single user by their ID
+// @Description  Retrieves a single user by their ID, users:read is needed for anyone but yourself
 // @Tags         users
 // @Security  BearerAuth
 // @Produce      json
@@ -165,6 +289,7 @@ func (c *UserController) GetAll(w http.ResponseWriter, r *http.Request) {
 // @Success      200  {object}  daos.UserOutputDAO
 // @Failure      400  {object}  rest_errors.ResponseError
 // @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
 // @Failure      404  {object}  rest_errors.ResponseError
 // @Failure      500  {object}  rest_errors.ResponseError
 // @Router       /users/{id} [get]
@@ -177,6 +302,9 @@ func (c *UserController) GetByID(w http.ResponseWriter, r *http.Request) {
 		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
 		return
 	}
+	if !c.authorize(w, r, id, domain.PermUsersRead) {
+		return
+	}
 
 	user, err := c.service.GetByID(ctx, id)
 	if errors.Is(err, domain.ErrUserNotFound) {
@@ -198,7 +326,9 @@ func (c *UserController) GetByID(w http.ResponseWriter, r *http.Request) {
 
 // UpdateByID godoc
 // @Summary      Update user by ID
-// @Description  Updates user fields like name or email by their ID
+// @Description  Updates user fields like name or email by their ID, users:write is needed for anyone but yourself.
+// @Description  Users that are members of other organizations too can only be updated by themselves.
+// @Description  Not allowed while impersonating.
 // @Tags         users
 // @Security  BearerAuth
 // @Accept       json
@@ -208,7 +338,9 @@ func (c *UserController) GetByID(w http.ResponseWriter, r *http.Request) {
 // @Success      200   {object}  daos.UserUpdateDAO
 // @Failure      400   {object}  rest_errors.ResponseError
 // @Failure      401   {object}  rest_errors.ResponseError
+// @Failure      403   {object}  rest_errors.ResponseError
 // @Failure      404   {object}  rest_errors.ResponseError
+// @Failure      409   {object}  rest_errors.ResponseError
 // @Failure      500   {object}  rest_errors.ResponseError
 // @Router       /users/{id} [put]
 func (c *UserController) UpdateByID(w http.ResponseWriter, r *http.Request) {
@@ -220,6 +352,9 @@ func (c *UserController) UpdateByID(w http.ResponseWriter, r *http.Request) {
 		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
 		return
 	}
+	if !c.authorize(w, r, id, domain.PermUsersWrite) {
+		return
+	}
 
 	var userDao daos.UserUpdateDAO
 	if err := json.NewDecoder(r.Body).Decode(&userDao); err != nil {
@@ -236,10 +371,22 @@ func (c *UserController) UpdateByID(w http.ResponseWriter, r *http.Request) {
 	userUpdate := userDao.ToUserUpdate()
 
 	userUpdateOutput, err := c.service.UpdateByID(ctx, userUpdate, id)
+	if errors.Is(err, domain.ErrInvalidMetadata) {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, domain.ErrSharedUser) {
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	}
 	if errors.Is(err, domain.ErrUserNotFound) {
 		rest_errors.HandleError(w, err, http.StatusNotFound)
 		return
 	}
+	if errors.Is(err, domain.ErrUserAlreadyExists) {
+		rest_errors.HandleError(w, err, http.StatusConflict) // 409
+		return
+	}
 	if err != nil {
 		rest_errors.HandleError(w, err, http.StatusInternalServerError)
 		return
@@ -255,7 +402,8 @@ func (c *UserController) UpdateByID(w http.ResponseWriter, r *http.Request) {
 
 // DeleteByID godoc
 // @Summary      Delete user by ID
-// @Description  Deletes a user from the system by their ID
+// @Description  Removes a user from the current organization, the user is deleted when it was their last one.
+// @Description  users:delete is needed for anyone but yourself.
 // @Tags         users
 // @Security  BearerAuth
 // @Produce      json
@@ -263,7 +411,9 @@ func (c *UserController) UpdateByID(w http.ResponseWriter, r *http.Request) {
 // @Success      204  "No Content"
 // @Failure      400  {object}  rest_errors.ResponseError
 // @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
 // @Failure      404  {object}  rest_errors.ResponseError
+// @Failure      409  {object}  rest_errors.ResponseError
 // @Failure      500  {object}  rest_errors.ResponseError
 // @Router       /users/{id} [delete]
 func (c *UserController) DeleteByID(w http.ResponseWriter, r *http.Request) {
@@ -275,12 +425,23 @@ func (c *UserController) DeleteByID(w http.ResponseWriter, r *http.Request) {
 		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
 		return
 	}
+	if !c.authorize(w, r, id, domain.PermUsersDelete) {
+		return
+	}
 
 	err = c.service.DeleteByID(ctx, id)
 	if errors.Is(err, domain.ErrUserNotFound) {
 		rest_errors.HandleError(w, err, http.StatusNotFound)
 		return
 	}
+	if errors.Is(err, domain.ErrImpersonating) {
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, domain.ErrLastOwner) {
+		rest_errors.HandleError(w, err, http.StatusConflict)
+		return
+	}
 	if err != nil {
 		rest_errors.HandleError(w, err, http.StatusInternalServerError)
 		return
@@ -0,0 +1,99 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"time"
+)
+
+type DeleteAccountDAO struct {
+	Password string `json:"password" example:"P@ssw0rd"` // current one, to confirm
+}
+
+type AccountDeletionDAO struct {
+	DeleteAfter time.Time `json:"delete_after" example:"2025-01-31T12:00:00Z"`
+}
+
+// AccountExportDAO is everything stored about the user, GET /me/export.
+type AccountExportDAO struct {
+	ExportedAt  time.Time             `json:"exported_at" example:"2025-01-01T12:00:00Z"`
+	User        UserOutputDAO         `json:"user"`
+	DeleteAfter *time.Time            `json:"delete_after,omitempty" example:"2025-01-31T12:00:00Z"`
+	Memberships []ExportMembershipDAO `json:"memberships"`
+	Sessions    []ExportSessionDAO    `json:"sessions"`
+	AuditEvents []ExportAuditEventDAO `json:"audit_events"`
+}
+
+type ExportMembershipDAO struct {
+	OrgID    int       `json:"org_id" example:"1"`
+	OrgName  string    `json:"org_name" example:"Acme Inc."`
+	Role     string    `json:"role" example:"owner"`
+	JoinedAt time.Time `json:"joined_at" example:"2025-01-01T12:00:00Z"`
+}
+
+type ExportSessionDAO struct {
+	ID         string     `json:"id" example:"0b6f7a52-3c1e-4a59-9a43-5f0d4c8e2f11"`
+	UserAgent  string     `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+	IP         string     `json:"ip" example:"203.0.113.7"`
+	CreatedAt  time.Time  `json:"created_at" example:"2025-01-01T12:00:00Z"`
+	LastSeenAt time.Time  `json:"last_seen_at" example:"2025-01-02T08:30:00Z"`
+	ExpiresAt  time.Time  `json:"expires_at" example:"2025-01-09T08:30:00Z"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" example:"2025-01-03T08:30:00Z"`
+}
+
+// ExportAuditEventDAO ids are 0 for users deleted since
+type ExportAuditEventDAO struct {
+	ID        int64          `json:"id" example:"1"`
+	OrgID     int            `json:"org_id,omitempty" example:"1"`
+	ActorID   int            `json:"actor_id,omitempty" example:"2"`
+	SubjectID int            `json:"subject_id,omitempty" example:"1"`
+	Action    string         `json:"action" example:"impersonation.started"`
+	Details   map[string]any `json:"details"`
+	CreatedAt time.Time      `json:"created_at" example:"2025-01-01T12:00:00Z"`
+}
+
+func ToAccountExportDAO(e *domain.AccountExport) *AccountExportDAO {
+	dao := &AccountExportDAO{
+		ExportedAt:  e.ExportedAt,
+		User:        *ToUserOutputDAO(&e.Account.User),
+		Memberships: make([]ExportMembershipDAO, 0, len(e.Memberships)),
+		Sessions:    make([]ExportSessionDAO, 0, len(e.Sessions)),
+		AuditEvents: make([]ExportAuditEventDAO, 0, len(e.AuditEvents)),
+	}
+	if !e.Account.DeleteAfter.IsZero() {
+		dao.DeleteAfter = &e.Account.DeleteAfter
+	}
+	for _, m := range e.Memberships {
+		dao.Memberships = append(dao.Memberships, ExportMembershipDAO{
+			OrgID:    m.Org.ID,
+			OrgName:  m.Org.Name,
+			Role:     string(m.Role),
+			JoinedAt: m.CreatedAt,
+		})
+	}
+	for _, s := range e.Sessions {
+		out := ExportSessionDAO{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+		}
+		if !s.RevokedAt.IsZero() {
+			out.RevokedAt = &s.RevokedAt
+		}
+		dao.Sessions = append(dao.Sessions, out)
+	}
+	for _, a := range e.AuditEvents {
+		dao.AuditEvents = append(dao.AuditEvents, ExportAuditEventDAO{
+			ID:        a.ID,
+			OrgID:     a.OrgID,
+			ActorID:   a.ActorID,
+			SubjectID: a.SubjectID,
+			Action:    a.Action,
+			Details:   a.Details,
+			CreatedAt: a.CreatedAt,
+		})
+	}
+	return dao
+}
@@ -0,0 +1,67 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"time"
+)
+
+type ImportRowResultDAO struct {
+	Line   int    `json:"line" example:"2"` // line in the file, csv header is line 1
+	Email  string `json:"email,omitempty" example:"john.doe@example.com"`
+	Status string `json:"status" enums:"created,exists,invalid,failed" example:"created"`
+	ID     int    `json:"id,omitempty" example:"1"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportReportDAO struct {
+	Total   int                  `json:"total" example:"3"`
+	Created int                  `json:"created" example:"1"`
+	Exists  int                  `json:"exists" example:"1"`
+	Invalid int                  `json:"invalid" example:"1"`
+	Failed  int                  `json:"failed" example:"0"`
+	Rows    []ImportRowResultDAO `json:"rows"`
+}
+
+func ToImportReportDAO(report *domain.ImportReport) *ImportReportDAO {
+	rows := make([]ImportRowResultDAO, 0, len(report.Rows))
+	for _, row := range report.Rows {
+		rows = append(rows, ImportRowResultDAO{
+			Line:   row.Line,
+			Email:  row.Email,
+			Status: string(row.Status),
+			ID:     row.ID,
+			Error:  row.Error,
+		})
+	}
+	return &ImportReportDAO{
+		Total:   report.Total,
+		Created: report.Created,
+		Exists:  report.Exists,
+		Invalid: report.Invalid,
+		Failed:  report.Failed,
+		Rows:    rows,
+	}
+}
+
+type ImportJobDAO struct {
+	ID         string           `json:"id" example:"7f9c2b4e-1d3a-4c5b-9e8f-0a1b2c3d4e5f"`
+	Status     string           `json:"status" enums:"running,done,failed" example:"running"`
+	Report     *ImportReportDAO `json:"report"`
+	Error      string           `json:"error,omitempty"`
+	StartedAt  time.Time        `json:"started_at" example:"2025-01-01T12:00:00Z"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty" example:"2025-01-01T12:01:00Z"`
+}
+
+func ToImportJobDAO(job *domain.ImportJob) *ImportJobDAO {
+	dao := &ImportJobDAO{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Report:    ToImportReportDAO(job.Report),
+		Error:     job.Error,
+		StartedAt: job.StartedAt,
+	}
+	if !job.FinishedAt.IsZero() {
+		dao.FinishedAt = &job.FinishedAt
+	}
+	return dao
+}
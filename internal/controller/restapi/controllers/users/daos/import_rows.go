@@ -0,0 +1,212 @@
+package daos
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"iter"
+	"slices"
+	"strings"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	maxNDJSONLine = 64 * 1024
+)
+
+var (
+	ErrUnknownImportFormat = errors.New("unsupported import format, use text/csv or application/x-ndjson")
+	ErrImportCSVHeader     = errors.New("csv header must have name, email and password columns")
+)
+
+// ImportFormat picks the format by Content-Type of the import body.
+func ImportFormat(contentType string) (string, error) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "text/csv":
+		return ImportFormatCSV, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return ImportFormatNDJSON, nil
+	}
+	return "", ErrUnknownImportFormat
+}
+
+// ImportRows reads r one row at a time and validates rows with sign up rules.
+// Invalid rows are yielded with Err set, a non-nil error means r can't be read further.
+func ImportRows(format string, r io.Reader, v *validator.Validate) iter.Seq2[*domain.ImportRow, error] {
+	if format == ImportFormatCSV {
+		return csvImportRows(r, v)
+	}
+	return ndjsonImportRows(r, v)
+}
+
+func csvImportRows(r io.Reader, v *validator.Validate) iter.Seq2[*domain.ImportRow, error] {
+	return func(yield func(*domain.ImportRow, error) bool) {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1 // checked per row, so a short row doesn't abort the import
+		cr.TrimLeadingSpace = true
+
+		header, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		columns, ok := csvColumns(header)
+		if !ok {
+			yield(nil, ErrImportCSVHeader)
+			return
+		}
+
+		for {
+			record, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				if !yield(&domain.ImportRow{Line: parseErr.StartLine, Err: parseErr.Err}, nil) {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			line, _ := cr.FieldPos(0)
+			if len(record) != len(header) {
+				err := fmt.Errorf("expected %d fields, got %d", len(header), len(record))
+				if !yield(&domain.ImportRow{Line: line, Err: err}, nil) {
+					return
+				}
+				continue
+			}
+
+			dao, err := csvSignUpInput(record, columns)
+			if err != nil {
+				if !yield(&domain.ImportRow{Line: line, Input: dao.ToSignUpInput(), Err: err}, nil) {
+					return
+				}
+				continue
+			}
+			if !yield(dao.toImportRow(line, v), nil) {
+				return
+			}
+		}
+	}
+}
+
+// csvColumnNames are known csv columns, the first three are required. Export writes the same ones
+// plus id and timestamps, so an export can be imported elsewhere (passwords aside).
+var csvColumnNames = []string{"name", "email", "password", "display_name", "avatar_url", "locale", "timezone", "phone", "metadata"}
+
+// csvColumns maps known header names to indexes, in any order and case. Unknown columns are ignored.
+func csvColumns(header []string) (map[string]int, bool) {
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff") // excel puts BOM in front
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		if slices.Contains(csvColumnNames, column) {
+			columns[column] = i
+		}
+	}
+	for _, required := range csvColumnNames[:3] {
+		if _, ok := columns[required]; !ok {
+			return nil, false
+		}
+	}
+	return columns, true
+}
+
+// csvSignUpInput builds the row, metadata column holds a json object.
+func csvSignUpInput(record []string, columns map[string]int) (*SignUpInputDAO, error) {
+	get := func(column string) string {
+		if i, ok := columns[column]; ok {
+			return record[i]
+		}
+		return ""
+	}
+	dao := &SignUpInputDAO{
+		Name:     get("name"),
+		Email:    get("email"),
+		Password: get("password"),
+		ProfileDAO: ProfileDAO{
+			DisplayName: get("display_name"),
+			AvatarURL:   get("avatar_url"),
+			Locale:      get("locale"),
+			Timezone:    get("timezone"),
+			Phone:       get("phone"),
+		},
+	}
+	if metadata := get("metadata"); metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &dao.Metadata); err != nil {
+			return dao, errors.New("invalid metadata json")
+		}
+	}
+	return dao, nil
+}
+
+func ndjsonImportRows(r io.Reader, v *validator.Validate) iter.Seq2[*domain.ImportRow, error] {
+	return func(yield func(*domain.ImportRow, error) bool) {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 4096), maxNDJSONLine)
+
+		line := 0
+		for sc.Scan() {
+			line++
+			text := bytes.TrimSpace(sc.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+
+			var dao SignUpInputDAO
+			if err := json.Unmarshal(text, &dao); err != nil {
+				if !yield(&domain.ImportRow{Line: line, Err: errors.New("invalid json")}, nil) {
+					return
+				}
+				continue
+			}
+			if !yield(dao.toImportRow(line, v), nil) {
+				return
+			}
+		}
+		if err := sc.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func (dao *SignUpInputDAO) toImportRow(line int, v *validator.Validate) *domain.ImportRow {
+	row := &domain.ImportRow{Line: line, Input: dao.ToSignUpInput()}
+	if err := dao.ValidateWith(v); err != nil {
+		row.Err = validationError(err)
+	}
+	return row
+}
+
+// validationError names the invalid fields instead of validator's struct dump.
+func validationError(err error) error {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	fields := make([]string, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		fields = append(fields, fe.Field())
+	}
+	return fmt.Errorf("invalid %s", strings.Join(fields, ", "))
+}
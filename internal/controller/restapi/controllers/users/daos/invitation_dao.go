@@ -0,0 +1,73 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"time"
+)
+
+type InvitationInputDAO struct {
+	Email string `json:"email" validate:"required,email" example:"jane.doe@example.com"`
+	Role  string `json:"role" validate:"required,oneof=owner admin member" example:"member"`
+}
+
+func (dao *InvitationInputDAO) ValidateWith(v *validator.Validate) error {
+	return v.Struct(dao)
+}
+
+func (dao *InvitationInputDAO) ToInvitationInput() *domain.InvitationInput {
+	return &domain.InvitationInput{Email: dao.Email, Role: domain.Role(dao.Role)}
+}
+
+// AcceptInvitationDAO is the new account, only password is needed when the email is registered already.
+type AcceptInvitationDAO struct {
+	Name     string `json:"name" validate:"omitempty,gte=3,lte=32" example:"Jane Doe"`
+	Password string `json:"password" validate:"required,gte=6,lte=32" example:"P@ssw0rd"`
+	ProfileDAO
+}
+
+func (dao *AcceptInvitationDAO) ValidateWith(v *validator.Validate) error {
+	return v.Struct(dao)
+}
+
+func (dao *AcceptInvitationDAO) ToAcceptInvitationInput() *domain.AcceptInvitationInput {
+	return &domain.AcceptInvitationInput{
+		Name:     dao.Name,
+		Password: dao.Password,
+		Profile:  dao.toProfile(),
+	}
+}
+
+type InvitationDAO struct {
+	ID        string    `json:"id" example:"7b0d4f6e-2f4a-4b8e-9a51-3c2d9f3e1a10"`
+	OrgID     int       `json:"org_id" example:"1"`
+	Email     string    `json:"email" example:"jane.doe@example.com"`
+	Role      string    `json:"role" example:"member"`
+	InvitedBy int       `json:"invited_by,omitempty" example:"1"`
+	ExpiresAt time.Time `json:"expires_at" example:"2025-01-04T12:00:00Z"`
+	CreatedAt time.Time `json:"created_at" example:"2025-01-01T12:00:00Z"`
+}
+
+func ToInvitationDAO(inv *domain.Invitation) *InvitationDAO {
+	return &InvitationDAO{
+		ID:        inv.ID,
+		OrgID:     inv.OrgID,
+		Email:     inv.Email,
+		Role:      string(inv.Role),
+		InvitedBy: inv.InvitedBy,
+		ExpiresAt: inv.ExpiresAt,
+		CreatedAt: inv.CreatedAt,
+	}
+}
+
+type InvitationListDAO struct {
+	Invitations []InvitationDAO `json:"invitations"`
+}
+
+func ToInvitationListDAO(invitations []*domain.Invitation) *InvitationListDAO {
+	out := make([]InvitationDAO, 0, len(invitations))
+	for _, inv := range invitations {
+		out = append(out, *ToInvitationDAO(inv))
+	}
+	return &InvitationListDAO{Invitations: out}
+}
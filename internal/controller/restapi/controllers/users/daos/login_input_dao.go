@@ -8,6 +8,7 @@ import (
 type LoginInputDAO struct {
 	Email    string `json:"email" validate:"required,email" example:"john.doe@example.com"`
 	Password string `json:"password" validate:"required" example:"P@ssw0rd"`
+	OrgID    int    `json:"org_id,omitempty" validate:"gte=0" example:"1"` // default is the first organization joined
 }
 
 func (dao *LoginInputDAO) ValidateWith(v *validator.Validate) error {
@@ -18,5 +19,6 @@ func (dao *LoginInputDAO) ToLoginInput() *domain.LoginInput {
 	return &domain.LoginInput{
 		Email:    dao.Email,
 		Password: dao.Password,
+		OrgID:    dao.OrgID,
 	}
 }
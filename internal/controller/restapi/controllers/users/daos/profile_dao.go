@@ -0,0 +1,40 @@
+package daos
+
+import "github.com/Arh0rn/test-task1/internal/domain"
+
+// ProfileDAO is embedded into user input and output, its fields are flat in json.
+type ProfileDAO struct {
+	DisplayName string `json:"display_name" validate:"omitempty,lte=64" example:"Johnny"`
+	AvatarURL   string `json:"avatar_url" validate:"omitempty,http_url,lte=2048" example:"https://example.com/avatar.png"`
+	Locale      string `json:"locale" validate:"omitempty,bcp47_language_tag" example:"en-US"`
+	Timezone    string `json:"timezone" validate:"omitempty,timezone" example:"Europe/Berlin"`
+	Phone       string `json:"phone" validate:"omitempty,e164" example:"+14155552671"`
+	// Custom attributes, must match GET /users/metadata-schema
+	Metadata map[string]any `json:"metadata" swaggertype:"object"`
+}
+
+func (dao *ProfileDAO) toProfile() domain.Profile {
+	return domain.Profile{
+		DisplayName: dao.DisplayName,
+		AvatarURL:   dao.AvatarURL,
+		Locale:      dao.Locale,
+		Timezone:    dao.Timezone,
+		Phone:       dao.Phone,
+		Metadata:    dao.Metadata,
+	}
+}
+
+func toProfileDAO(profile domain.Profile) ProfileDAO {
+	metadata := profile.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	return ProfileDAO{
+		DisplayName: profile.DisplayName,
+		AvatarURL:   profile.AvatarURL,
+		Locale:      profile.Locale,
+		Timezone:    profile.Timezone,
+		Phone:       profile.Phone,
+		Metadata:    metadata,
+	}
+}
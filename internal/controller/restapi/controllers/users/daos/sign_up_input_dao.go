@@ -9,6 +9,7 @@ type SignUpInputDAO struct {
 	Name     string `json:"name" validate:"required,gte=3,lte=32" example:"John Doe"`
 	Email    string `json:"email" validate:"required,email" example:"john.doe@example.com"`
 	Password string `json:"password" validate:"required,gte=6,lte=32" example:"P@ssw0rd"`
+	ProfileDAO
 }
 
 func (dao *SignUpInputDAO) ValidateWith(v *validator.Validate) error {
@@ -20,12 +21,14 @@ func (dao *SignUpInputDAO) ToSignUpInput() *domain.SignUpInput {
 		Name:     dao.Name,
 		Email:    dao.Email,
 		Password: dao.Password,
+		Profile:  dao.toProfile(),
 	}
 }
 func ToSignUpInputDAO(input *domain.SignUpInput) *SignUpInputDAO {
 	return &SignUpInputDAO{
-		Name:     input.Name,
-		Email:    input.Email,
-		Password: input.Password,
+		Name:       input.Name,
+		Email:      input.Email,
+		Password:   input.Password,
+		ProfileDAO: toProfileDAO(input.Profile),
 	}
 }
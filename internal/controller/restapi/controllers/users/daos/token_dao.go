@@ -1,11 +1,16 @@
 package daos
 
+import "github.com/Arh0rn/test-task1/internal/domain"
+
 type TokenDAO struct {
 	Token string `json:"token"`
+	// RefreshToken gets new tokens at POST /token/refresh, once
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
-func ToTokenDAO(token string) *TokenDAO {
+func ToTokenDAO(tokens *domain.Tokens) *TokenDAO {
 	return &TokenDAO{
-		Token: token,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}
 }
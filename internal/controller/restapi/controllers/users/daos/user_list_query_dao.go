@@ -0,0 +1,43 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"net/url"
+	"strconv"
+)
+
+const defaultLimit = 20
+
+type UserListQueryDAO struct {
+	Limit  int `validate:"gte=1,lte=100"`
+	Offset int `validate:"gte=0"`
+}
+
+// ParseUserListQueryDAO reads ?limit=&offset=, missing ones get defaults.
+func ParseUserListQueryDAO(values url.Values) (*UserListQueryDAO, error) {
+	dao := &UserListQueryDAO{Limit: defaultLimit}
+	var err error
+	if v := values.Get("limit"); v != "" {
+		if dao.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		if dao.Offset, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	return dao, nil
+}
+
+func (dao *UserListQueryDAO) ValidateWith(v *validator.Validate) error {
+	return v.Struct(dao)
+}
+
+func (dao *UserListQueryDAO) ToUserListQuery() *domain.UserListQuery {
+	return &domain.UserListQuery{
+		Limit:  dao.Limit,
+		Offset: dao.Offset,
+	}
+}
@@ -1,31 +1,46 @@
 package daos
 
-import "github.com/Arh0rn/test-task1/internal/domain"
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"time"
+)
 
 type UserOutputDAO struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at" example:"2025-01-01T12:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2025-01-01T12:00:00Z"`
+	ProfileDAO
 }
 
 func ToUserOutputDAO(user *domain.User) *UserOutputDAO {
 	return &UserOutputDAO{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
+		ID:         user.ID,
+		Name:       user.Name,
+		Email:      user.Email,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+		ProfileDAO: toProfileDAO(user.Profile),
 	}
 }
 
 type UserListDAO struct {
-	Users []UserOutputDAO `json:"users"`
+	Users  []UserOutputDAO `json:"users"`
+	Total  int             `json:"total" example:"42"`
+	Limit  int             `json:"limit" example:"20"`
+	Offset int             `json:"offset" example:"0"`
 }
 
-func ToUserListDAO(users []*domain.User) *UserListDAO {
-	var userList []UserOutputDAO
-	for _, user := range users {
+func ToUserListDAO(list *domain.UserList, query *domain.UserListQuery) *UserListDAO {
+	userList := make([]UserOutputDAO, 0, len(list.Users))
+	for _, user := range list.Users {
 		userList = append(userList, *ToUserOutputDAO(user))
 	}
 	return &UserListDAO{
-		Users: userList,
+		Users:  userList,
+		Total:  list.Total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
 	}
 }
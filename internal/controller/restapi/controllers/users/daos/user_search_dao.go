@@ -0,0 +1,75 @@
+package daos
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/highlight"
+	"github.com/go-playground/validator/v10"
+	"net/url"
+)
+
+type UserSearchQueryDAO struct {
+	Query string `validate:"required,gte=2,lte=100"`
+	UserListQueryDAO
+}
+
+// ParseUserSearchQueryDAO reads ?q= plus paging of the list endpoint.
+func ParseUserSearchQueryDAO(values url.Values) (*UserSearchQueryDAO, error) {
+	page, err := ParseUserListQueryDAO(values)
+	if err != nil {
+		return nil, err
+	}
+	return &UserSearchQueryDAO{Query: values.Get("q"), UserListQueryDAO: *page}, nil
+}
+
+func (dao *UserSearchQueryDAO) ValidateWith(v *validator.Validate) error {
+	return v.Struct(dao)
+}
+
+func (dao *UserSearchQueryDAO) ToUserSearchQuery() *domain.UserSearchQuery {
+	return &domain.UserSearchQuery{
+		Query:  dao.Query,
+		Limit:  dao.Limit,
+		Offset: dao.Offset,
+	}
+}
+
+type UserSearchHitDAO struct {
+	UserOutputDAO
+	Rank      float64          `json:"rank" example:"1.0"`
+	Highlight UserHighlightDAO `json:"highlight"`
+}
+
+type UserHighlightDAO struct {
+	Name  string `json:"name" example:"John <mark>Doe</mark>"`
+	Email string `json:"email" example:"john.<mark>doe</mark>@example.com"`
+}
+
+// UserSearchDAO is the list envelope with search hits instead of plain users.
+type UserSearchDAO struct {
+	Users  []UserSearchHitDAO `json:"users"`
+	Total  int                `json:"total" example:"42"`
+	Limit  int                `json:"limit" example:"20"`
+	Offset int                `json:"offset" example:"0"`
+	Query  string             `json:"query" example:"doe"`
+}
+
+func ToUserSearchDAO(result *domain.UserSearchResult, query *domain.UserSearchQuery) *UserSearchDAO {
+	hits := make([]UserSearchHitDAO, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		hits = append(hits, UserSearchHitDAO{
+			UserOutputDAO: *ToUserOutputDAO(hit.User),
+			Rank:          hit.Rank,
+			Highlight: UserHighlightDAO{
+				Name:  highlight.HTML(hit.User.Name, query.Query),
+				Email: highlight.HTML(hit.User.Email, query.Query),
+			},
+		})
+	}
+	return &UserSearchDAO{
+		Users:  hits,
+		Total:  result.Total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Query:  query.Query,
+	}
+}
@@ -8,6 +8,7 @@ import (
 type UserUpdateDAO struct {
 	Name  string `json:"name" validate:"required,gte=3,lte=32" example:"John Doe"`
 	Email string `json:"email" validate:"required,email" example:"john.doe@example.com"`
+	ProfileDAO
 }
 
 func (dao *UserUpdateDAO) ValidateWith(v *validator.Validate) error {
@@ -15,14 +16,16 @@ func (dao *UserUpdateDAO) ValidateWith(v *validator.Validate) error {
 }
 func (dao *UserUpdateDAO) ToUserUpdate() *domain.UserUpdate {
 	return &domain.UserUpdate{
-		Name:  dao.Name,
-		Email: dao.Email,
+		Name:    dao.Name,
+		Email:   dao.Email,
+		Profile: dao.toProfile(),
 	}
 }
 
 func ToUserUpdateDAO(user *domain.UserUpdate) *UserUpdateDAO {
 	return &UserUpdateDAO{
-		Name:  user.Name,
-		Email: user.Email,
+		Name:       user.Name,
+		Email:      user.Email,
+		ProfileDAO: toProfileDAO(user.Profile),
 	}
 }
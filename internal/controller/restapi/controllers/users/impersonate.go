@@ -0,0 +1,56 @@
+package usersController
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"net/http"
+	"strconv"
+)
+
+// Impersonate godoc
+// @Summary      Impersonate user
+// @Description  Owners and admins get a short-lived token of a member of the organization whose role is not above theirs. The token has no refresh token, carries the caller in the act claim and can't delete the account, manage sessions or switch organizations. Every start is audited.
+// @Tags         users
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  daos.TokenDAO
+// @Failure      400  {object}  rest_errors.ResponseError
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      404  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /users/{id}/impersonate [post]
+func (c *UserController) Impersonate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.service.Impersonate(ctx, id)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, domain.ErrImpersonationNotAllowed) || errors.Is(err, domain.ErrImpersonating) ||
+		errors.Is(err, domain.ErrNotMember) {
+		rest_errors.HandleError(w, err, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToTokenDAO(tokens)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,268 @@
+package usersController
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var csvExportHeader = []string{"id", "name", "email", "created_at", "updated_at",
+	"display_name", "avatar_url", "locale", "timezone", "phone", "metadata"}
+
+// ImportUsers godoc
+// @Summary      Import users
+// @Description  Creates users from CSV or NDJSON (one sign up object per line). CSV header must have name, email and password,
+// @Description  optional columns are display_name, avatar_url, locale, timezone, phone and metadata (json object).
+// @Description  Rows are validated like sign up, rows with a taken email are skipped, so a failed import can be repeated.
+// @Description  Bodies over import.sync-max-bytes or with async=true run as a background job, poll it with GET /users:import/{id}.
+// @Tags         users
+// @Security  BearerAuth
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        async  query     bool    false  "Run as background job"
+// @Param        input  body      string  true   "CSV or NDJSON rows"
+// @Success      200    {object}  daos.ImportReportDAO
+// @Success      202    {object}  daos.ImportJobDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      403    {object}  rest_errors.ResponseError
+// @Failure      413    {object}  rest_errors.ResponseError
+// @Failure      415    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Router       /users:import [post]
+func (c *UserController) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	format, err := daos.ImportFormat(r.Header.Get("Content-Type"))
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// server timeouts are sized for small json requests
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	body := http.MaxBytesReader(w, r.Body, c.importCfg.MaxBytes)
+	v := c.service.GetValidator()
+
+	async := r.URL.Query().Get("async") == "true" || r.ContentLength > c.importCfg.SyncMaxBytes
+	if !async {
+		report, err := c.service.Import(ctx, daos.ImportRows(format, body, v))
+		if err != nil {
+			handleImportBodyError(w, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(daos.ToImportReportDAO(report)); err != nil {
+			rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	// Job outlives the request, so the body is saved to disk first
+	f, err := spoolImport(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			rest_errors.HandleError(w, err, http.StatusRequestEntityTooLarge)
+			return
+		}
+		slog.ErrorContext(ctx, "Failed to save import body", "error", err)
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+
+	job, err := c.service.StartImport(ctx, removeAfter(f, daos.ImportRows(format, f, v)))
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", "/users:import/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(daos.ToImportJobDAO(job)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetImportJob godoc
+// @Summary      Get import job
+// @Description  Returns status and per-row report of a background import, from any instance. Progress is saved after every batch.
+// @Tags         users
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id   path      string  true  "Job ID"
+// @Success      200  {object}  daos.ImportJobDAO
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      404  {object}  rest_errors.ResponseError
+// @Router       /users:import/{id} [get]
+func (c *UserController) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	job, err := c.service.GetImportJob(ctx, r.PathValue("id"))
+	if errors.Is(err, domain.ErrImportJobNotFound) {
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToImportJobDAO(job)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// ExportUsers godoc
+// @Summary      Export users
+// @Description  Streams all users ordered by ID as CSV or NDJSON, without passwords
+// @Tags         users
+// @Security  BearerAuth
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        format  query     string  false  "csv or ndjson, by default taken from Accept"  Enums(csv, ndjson)
+// @Success      200     {string}  string
+// @Failure      400     {object}  rest_errors.ResponseError
+// @Failure      401     {object}  rest_errors.ResponseError
+// @Failure      403     {object}  rest_errors.ResponseError
+// @Failure      500     {object}  rest_errors.ResponseError
+// @Router       /users:export [get]
+func (c *UserController) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = daos.ImportFormatNDJSON
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			format = daos.ImportFormatCSV
+		}
+	}
+
+	var (
+		write func(*domain.User) error
+		flush func() error
+	)
+	switch format {
+	case daos.ImportFormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		header := false
+		write = func(user *domain.User) error {
+			if !header {
+				header = true
+				if err := cw.Write(csvExportHeader); err != nil {
+					return err
+				}
+			}
+			metadata, err := json.Marshal(daos.ToUserOutputDAO(user).Metadata)
+			if err != nil {
+				return err
+			}
+			return cw.Write([]string{
+				strconv.Itoa(user.ID),
+				user.Name,
+				user.Email,
+				user.CreatedAt.Format(time.RFC3339),
+				user.UpdatedAt.Format(time.RFC3339),
+				user.DisplayName,
+				user.AvatarURL,
+				user.Locale,
+				user.Timezone,
+				user.Phone,
+				string(metadata),
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case daos.ImportFormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(user *domain.User) error {
+			return enc.Encode(daos.ToUserOutputDAO(user))
+		}
+		flush = func() error { return nil }
+	default:
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="users.`+format+`"`)
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	written := false
+	err := c.service.Export(ctx, func(user *domain.User) error {
+		written = true
+		return write(user)
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to export users", "error", err)
+		if !written { // after the first row status is already sent, client sees a cut stream
+			w.Header().Del("Content-Disposition")
+			rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func handleImportBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		rest_errors.HandleError(w, err, http.StatusRequestEntityTooLarge)
+		return
+	}
+	rest_errors.HandleError(w, err, http.StatusBadRequest)
+}
+
+// spoolImport copies body to a temp file and rewinds it.
+func spoolImport(body io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "users-import-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// removeAfter deletes the spooled file once rows are read.
+func removeAfter(f *os.File, rows iter.Seq2[*domain.ImportRow, error]) iter.Seq2[*domain.ImportRow, error] {
+	return func(yield func(*domain.ImportRow, error) bool) {
+		defer os.Remove(f.Name())
+		defer f.Close()
+		rows(yield)
+	}
+}
@@ -0,0 +1,177 @@
+package usersController
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users/daos"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"net/http"
+)
+
+// Invite godoc
+// @Summary      Invite user
+// @Description  Sends an invitation to join the current organization to the email, needs users:invite.
+// @Description  The role can't be above the caller's one. The link in it carries an expiring signed token.
+// @Tags         invitations
+// @Security  BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        input  body      daos.InvitationInputDAO  true  "Invitation"
+// @Success      201    {object}  daos.InvitationDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      403    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Failure      502    {object}  rest_errors.ResponseError
+// @Router       /invitations [post]
+func (c *UserController) Invite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	var input daos.InvitationInputDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	if err := input.ValidateWith(c.service.GetValidator()); err != nil {
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	inv, err := c.service.Invite(ctx, input.ToInvitationInput())
+	switch {
+	case errors.Is(err, domain.ErrForbidden), errors.Is(err, domain.ErrNotMember):
+		rest_errors.HandleError(w, domain.ErrForbidden, http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrNotifier):
+		rest_errors.HandleError(w, domain.ErrNotifier, http.StatusBadGateway)
+		return
+	case err != nil:
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(daos.ToInvitationDAO(inv)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListInvitations godoc
+// @Summary      List pending invitations
+// @Description  Invitations of the current organization that can still be accepted, the newest first. Needs users:invite.
+// @Tags         invitations
+// @Security  BearerAuth
+// @Produce      json
+// @Success      200  {object}  daos.InvitationListDAO
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /invitations [get]
+func (c *UserController) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	invitations, err := c.service.ListInvitations(ctx)
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToInvitationListDAO(invitations)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
+
+// RevokeInvitation godoc
+// @Summary      Revoke invitation
+// @Description  Its link stops working. Needs users:invite.
+// @Tags         invitations
+// @Security  BearerAuth
+// @Produce      json
+// @Param        id   path      string  true  "Invitation ID"
+// @Success      204  "No Content"
+// @Failure      401  {object}  rest_errors.ResponseError
+// @Failure      403  {object}  rest_errors.ResponseError
+// @Failure      404  {object}  rest_errors.ResponseError
+// @Failure      500  {object}  rest_errors.ResponseError
+// @Router       /invitations/{id} [delete]
+func (c *UserController) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	err := c.service.RevokeInvitation(ctx, r.PathValue("id"))
+	if errors.Is(err, domain.ErrInvitationNotFound) {
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation godoc
+// @Summary      Accept invitation
+// @Description  Creates the account with the invited email and joins the organization, returns a token for it.
+// @Description  When the email is registered already only password is needed, it must be the account's one.
+// @Description  Works with sign up closed.
+// @Tags         invitations
+// @Accept       json
+// @Produce      json
+// @Param        token  path      string                    true  "Invite token from the link"
+// @Param        input  body      daos.AcceptInvitationDAO  true  "Account"
+// @Success      200    {object}  daos.TokenDAO
+// @Failure      400    {object}  rest_errors.ResponseError
+// @Failure      401    {object}  rest_errors.ResponseError
+// @Failure      404    {object}  rest_errors.ResponseError
+// @Failure      409    {object}  rest_errors.ResponseError
+// @Failure      410    {object}  rest_errors.ResponseError
+// @Failure      500    {object}  rest_errors.ResponseError
+// @Router       /invitations/{token}/accept [post]
+func (c *UserController) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	var input daos.AcceptInvitationDAO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrBadRequest, http.StatusBadRequest)
+		return
+	}
+	if err := input.ValidateWith(c.service.GetValidator()); err != nil {
+		rest_errors.HandleError(w, domain.ErrValidation, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.service.AcceptInvitation(ctx, r.PathValue("token"), input.ToAcceptInvitationInput())
+	switch {
+	case errors.Is(err, domain.ErrNameRequired), errors.Is(err, domain.ErrInvalidMetadata):
+		rest_errors.HandleError(w, err, http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		rest_errors.HandleError(w, err, http.StatusUnauthorized)
+		return
+	case errors.Is(err, domain.ErrInvitationNotFound):
+		rest_errors.HandleError(w, err, http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrUserAlreadyExists):
+		rest_errors.HandleError(w, err, http.StatusConflict)
+		return
+	case errors.Is(err, domain.ErrInvitationExpired):
+		rest_errors.HandleError(w, err, http.StatusGone)
+		return
+	case err != nil:
+		rest_errors.HandleError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daos.ToTokenDAO(tokens)); err != nil {
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return
+	}
+}
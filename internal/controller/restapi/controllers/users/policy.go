@@ -0,0 +1,31 @@
+package usersController
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"log/slog"
+	"net/http"
+)
+
+// Authorizer is the policy of endpoints about one user: everyone may act on
+// themselves, other users need the permission.
+type Authorizer interface {
+	CanActOn(ctx context.Context, userID int, p domain.Permission) (bool, error)
+}
+
+// authorize writes the error response when the caller may not act on userID.
+func (c *UserController) authorize(w http.ResponseWriter, r *http.Request, userID int, p domain.Permission) bool {
+	ok, err := c.access.CanActOn(r.Context(), userID, p)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to check permission", "permission", p, "error", err)
+		rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		slog.InfoContext(r.Context(), "Permission denied", "permission", p, "target_id", userID)
+		rest_errors.HandleError(w, domain.ErrPermissionDenied, http.StatusForbidden)
+		return false
+	}
+	return true
+}
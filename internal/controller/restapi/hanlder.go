@@ -1,44 +1,147 @@
 package restapi
 
 import (
+	groupsController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/groups"
+	healthController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/health"
+	orgsController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/orgs"
+	sessionsController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/sessions"
 	usersController "github.com/Arh0rn/test-task1/internal/controller/restapi/controllers/users"
 	"github.com/Arh0rn/test-task1/internal/controller/restapi/middlewares"
 	"github.com/Arh0rn/test-task1/internal/controller/restapi/swagger"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/metrics"
 	"github.com/Arh0rn/test-task1/pkg/config"
 	"net/http"
+	"strings"
 )
 
 type Handler struct {
-	UserController usersController.UserController
+	UserController    usersController.UserController
+	OrgController     orgsController.OrgController
+	GroupController   groupsController.GroupController
+	SessionController sessionsController.SessionController
+	HealthController  healthController.HealthController
+	Access            middlewares.PermissionChecker
+	Sessions          middlewares.SessionChecker
+	Metrics           *metrics.Metrics
 }
 
-func NewHandler(userController *usersController.UserController) *Handler {
+func NewHandler(
+	userController *usersController.UserController,
+	orgController *orgsController.OrgController,
+	groupController *groupsController.GroupController,
+	sessionController *sessionsController.SessionController,
+	healthController *healthController.HealthController,
+	access middlewares.PermissionChecker,
+	sessions middlewares.SessionChecker,
+	m *metrics.Metrics,
+) *Handler {
 	return &Handler{
-		UserController: *userController,
+		UserController:    *userController,
+		OrgController:     *orgController,
+		GroupController:   *groupController,
+		SessionController: *sessionController,
+		HealthController:  *healthController,
+		Access:            access,
+		Sessions:          sessions,
+		Metrics:           m,
 	}
 }
 
 func (h *Handler) InitRoutes(cfg *config.HTTPServer) *http.Handler {
+	baseRouter := http.NewServeMux()
+	authorizedRouter := http.NewServeMux()
+	orgRouter := http.NewServeMux() // data of the current organization
+
+	route := routePattern(baseRouter, authorizedRouter, orgRouter)
 	mainStack := middlewares.CreateMiddlewareStack(
 		middlewares.SetCORS,
-		middlewares.LoggerMiddleware,
+		middlewares.MetricsMiddleware(h.Metrics, route),
+		middlewares.LoggerMiddleware(cfg.RequestLog),
+		middlewares.ClientMiddleware,
+		middlewares.TracingMiddleware(route),
 	)
 
-	baseRouter := http.NewServeMux()
-	authorizedRouter := http.NewServeMux()
-
 	baseRouter.HandleFunc("GET /swagger/", swagger.Set(cfg))
+	baseRouter.HandleFunc("GET /healthz", h.HealthController.Healthz)
+	baseRouter.HandleFunc("GET /readyz", h.HealthController.Readyz)
+	if cfg.MetricsAddress == "" {
+		baseRouter.Handle("GET /metrics", h.Metrics.Handler())
+	}
 
 	baseRouter.HandleFunc("POST /users", h.UserController.SignUp)
 	baseRouter.HandleFunc("POST /login", h.UserController.Login)
+	baseRouter.HandleFunc("POST /token/refresh", h.SessionController.Refresh)
+	baseRouter.HandleFunc("GET /users/metadata-schema", h.UserController.MetadataSchema)
+	baseRouter.HandleFunc("POST /invitations/{token}/accept", h.UserController.AcceptInvitation)
+
+	// endpoints about one user check the permission themselves, acting on yourself needs none
+	orgRouter.Handle("GET /users", h.require(domain.PermUsersRead, h.UserController.GetAll))
+	orgRouter.Handle("GET /users/search", h.require(domain.PermUsersRead, h.UserController.Search))
+	orgRouter.HandleFunc("GET /users/{id}", h.UserController.GetByID)
+	// name and email are the identity, impersonating admins must not take the account over
+	orgRouter.Handle("PUT /users/{id}", middlewares.BlockImpersonation(http.HandlerFunc(h.UserController.UpdateByID)))
+	orgRouter.HandleFunc("DELETE /users/{id}", h.UserController.DeleteByID)
+	orgRouter.HandleFunc("GET /users/{id}/avatar", h.UserController.GetAvatar)
+	orgRouter.HandleFunc("PUT /users/{id}/avatar", h.UserController.SetAvatar)
+	orgRouter.Handle("POST /users/{id}/impersonate", middlewares.BlockImpersonation(http.HandlerFunc(h.UserController.Impersonate)))
+	orgRouter.Handle("POST /users:import", h.require(domain.PermUsersImport, h.UserController.ImportUsers))
+	orgRouter.Handle("GET /users:import/{id}", h.require(domain.PermUsersImport, h.UserController.GetImportJob))
+	orgRouter.Handle("GET /users:export", h.require(domain.PermUsersExport, h.UserController.ExportUsers))
 
-	authorizedRouter.HandleFunc("GET /users", h.UserController.GetAll)
-	authorizedRouter.HandleFunc("GET /users/{id}", h.UserController.GetByID)
-	authorizedRouter.HandleFunc("PUT /users/{id}", h.UserController.UpdateByID)
-	authorizedRouter.HandleFunc("DELETE /users/{id}", h.UserController.DeleteByID)
+	orgRouter.Handle("POST /invitations", h.require(domain.PermUsersInvite, h.UserController.Invite))
+	orgRouter.Handle("GET /invitations", h.require(domain.PermUsersInvite, h.UserController.ListInvitations))
+	orgRouter.Handle("DELETE /invitations/{id}", h.require(domain.PermUsersInvite, h.UserController.RevokeInvitation))
 
-	baseRouter.Handle("/", middlewares.AuthMiddleware(cfg.JWTSecret)(authorizedRouter))
+	orgRouter.HandleFunc("GET /permissions", h.GroupController.Permissions)
+	orgRouter.Handle("GET /groups", h.require(domain.PermGroupsRead, h.GroupController.List))
+	orgRouter.Handle("POST /groups", h.require(domain.PermGroupsWrite, h.GroupController.Create))
+	orgRouter.Handle("GET /groups/{id}", h.require(domain.PermGroupsRead, h.GroupController.GetByID))
+	orgRouter.Handle("PUT /groups/{id}", h.require(domain.PermGroupsWrite, h.GroupController.Update))
+	orgRouter.Handle("DELETE /groups/{id}", h.require(domain.PermGroupsWrite, h.GroupController.Delete))
+	orgRouter.Handle("PUT /groups/{id}/members/{user_id}", h.require(domain.PermGroupsWrite, h.GroupController.AddMember))
+	orgRouter.Handle("DELETE /groups/{id}/members/{user_id}", h.require(domain.PermGroupsWrite, h.GroupController.RemoveMember))
+
+	authorizedRouter.Handle("DELETE /me", middlewares.BlockImpersonation(http.HandlerFunc(h.UserController.DeleteMe)))
+	authorizedRouter.Handle("GET /me/export", middlewares.BlockImpersonation(http.HandlerFunc(h.UserController.ExportMe)))
+	authorizedRouter.HandleFunc("GET /me/sessions", h.SessionController.List)
+	// account owner only, not while impersonating
+	authorizedRouter.Handle("DELETE /me/sessions/{id}", middlewares.BlockImpersonation(http.HandlerFunc(h.SessionController.Revoke)))
+
+	authorizedRouter.HandleFunc("GET /orgs", h.OrgController.List)
+	authorizedRouter.HandleFunc("POST /orgs", h.OrgController.Create)
+	authorizedRouter.Handle("POST /orgs/{id}/switch", middlewares.BlockImpersonation(http.HandlerFunc(h.OrgController.Switch)))
+	authorizedRouter.HandleFunc("PUT /orgs/{id}/members/{user_id}", h.OrgController.SetRole)
+	authorizedRouter.Handle("/", middlewares.RequireOrg(orgRouter))
+
+	baseRouter.Handle("/", middlewares.AuthMiddleware(cfg.JWTSecret, h.Sessions)(authorizedRouter))
 
 	router := mainStack(baseRouter)
 	return &router
 }
+
+// routePattern finds the pattern a request will be served by, walking the nested routers
+// the same way requests do. "/" only hands over to the next router.
+func routePattern(routers ...*http.ServeMux) func(*http.Request) string {
+	return func(r *http.Request) string {
+		for _, router := range routers {
+			_, pattern := router.Handler(r)
+			if pattern == "/" {
+				continue
+			}
+			if pattern == "" {
+				break
+			}
+			// method has its own label
+			if _, path, ok := strings.Cut(pattern, " "); ok {
+				return path
+			}
+			return pattern
+		}
+		return "unmatched"
+	}
+}
+
+func (h *Handler) require(p domain.Permission, fn http.HandlerFunc) http.Handler {
+	return middlewares.RequirePermission(h.Access, p)(fn)
+}
@@ -2,7 +2,9 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"github.com/Arh0rn/test-task1/internal/controller/restapi/rest_errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
 	"github.com/Arh0rn/test-task1/pkg/jwtoken"
 	"github.com/Arh0rn/test-task1/pkg/logger"
 	"log/slog"
@@ -10,7 +12,14 @@ import (
 	"strconv"
 )
 
-func AuthMiddleware(secret string) Middleware {
+// SessionChecker is ErrSessionRevoked for sessions that are over.
+type SessionChecker interface {
+	Check(ctx context.Context, id string) error
+}
+
+// AuthMiddleware accepts tokens signed with secret. With sessions, tokens must
+// also belong to a live session, ones issued before sessions existed have none.
+func AuthMiddleware(secret string, sessions SessionChecker) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -20,14 +29,43 @@ func AuthMiddleware(secret string) Middleware {
 				return
 			}
 
-			id, err := jwtoken.ParseToken(token, []byte(secret))
+			claims, err := jwtoken.ParseToken(token, []byte(secret))
 			if err != nil {
 				rest_errors.HandleError(w, rest_errors.ErrUserUnauthorized, http.StatusUnauthorized)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), "id", id)
-			ctx = logger.WithLogUserID(ctx, strconv.Itoa(id)) //To set to every log message
+			if sessions != nil {
+				if claims.SessionID == "" {
+					rest_errors.HandleError(w, rest_errors.ErrUserUnauthorized, http.StatusUnauthorized)
+					return
+				}
+				err := sessions.Check(r.Context(), claims.SessionID)
+				if errors.Is(err, domain.ErrSessionRevoked) {
+					rest_errors.HandleError(w, err, http.StatusUnauthorized)
+					return
+				}
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Failed to check session", "error", err)
+					rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+					return
+				}
+			}
+
+			ctx := domain.WithRequester(r.Context(), domain.Requester{
+				UserID:    claims.UserID,
+				Email:     claims.Email,
+				OrgID:     claims.OrgID,
+				SessionID: claims.SessionID,
+				ActorID:   claims.ActorID,
+			})
+			ctx = logger.WithLogUserID(ctx, strconv.Itoa(claims.UserID)) //To set to every log message
+			if claims.OrgID != 0 {
+				ctx = logger.WithLogOrgID(ctx, strconv.Itoa(claims.OrgID))
+			}
+			if claims.ActorID != 0 {
+				ctx = logger.WithLogActorID(ctx, strconv.Itoa(claims.ActorID))
+			}
 			slog.InfoContext(ctx, "User authenticated")
 			r = r.WithContext(ctx)
 
@@ -35,3 +73,51 @@ func AuthMiddleware(secret string) Middleware {
 		})
 	}
 }
+
+// RequireOrg rejects tokens without organization, for endpoints that work on data of one.
+func RequireOrg(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := domain.OrgID(r.Context()); !ok {
+			rest_errors.HandleError(w, domain.ErrNoOrg, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BlockImpersonation rejects impersonation tokens, for actions only the account owner may take.
+func BlockImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req, _ := domain.RequesterFrom(r.Context()); req.Impersonated() {
+			slog.InfoContext(r.Context(), "Blocked while impersonating", "path", r.URL.Path)
+			rest_errors.HandleError(w, domain.ErrImpersonating, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PermissionChecker tells if the caller has the permission in their current organization.
+type PermissionChecker interface {
+	Can(ctx context.Context, p domain.Permission) (bool, error)
+}
+
+// RequirePermission rejects callers without p, goes after RequireOrg.
+func RequirePermission(checker PermissionChecker, p domain.Permission) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, err := checker.Can(r.Context(), p)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to check permission", "permission", p, "error", err)
+				rest_errors.HandleError(w, rest_errors.ErrInternalServer, http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				slog.InfoContext(r.Context(), "Permission denied", "permission", p)
+				rest_errors.HandleError(w, domain.ErrPermissionDenied, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"net"
+	"net/http"
+)
+
+// sessions show it, a longer one is noise
+const maxUserAgent = 256
+
+// ClientMiddleware puts where the request came from in ctx, sessions remember it.
+func ClientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		ua := r.UserAgent()
+		if len(ua) > maxUserAgent {
+			ua = ua[:maxUserAgent]
+		}
+		ctx := domain.WithClient(r.Context(), domain.Client{UserAgent: ua, IP: ip})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
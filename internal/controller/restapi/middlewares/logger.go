@@ -1,55 +1,103 @@
 package middlewares
 
 import (
+	"github.com/Arh0rn/test-task1/pkg/config"
 	"github.com/Arh0rn/test-task1/pkg/logger"
 	"github.com/google/uuid"
+	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"strings"
 	"time"
 )
 
-func LoggerMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		requestID := uuid.New()
-		ctx = logger.WithLogRequestID(ctx, requestID.String())
-		r = r.WithContext(ctx)
+const (
+	requestIDHeader = "X-Request-ID"
+	maxRequestID    = 128
 
-		start := time.Now()
-		rwl := NewResponseLogger(w)
+	bodyLogOff     = "off"
+	bodyLogErrors  = "errors"
+	bodyLogSampled = "sampled"
+)
 
-		next.ServeHTTP(rwl, r)
+// LoggerMiddleware logs every request, bodies only as cfg says, cut to the max size and redacted.
+func LoggerMiddleware(cfg config.RequestLog) func(http.Handler) http.Handler {
+	redactor := newRedactor(cfg.RedactFields)
+	switch cfg.Body {
+	case bodyLogOff, bodyLogErrors, bodyLogSampled:
+	default:
+		slog.Warn("Unknown request body logging mode, logging bodies of errors only", "mode", cfg.Body)
+		cfg.Body = bodyLogErrors
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the caller's id ties our logs to theirs
+			requestID := r.Header.Get(requestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = uuid.New().String()
+			}
+			w.Header().Set(requestIDHeader, requestID)
+			ctx := logger.WithLogRequestID(r.Context(), requestID)
+			r = r.WithContext(ctx)
 
-		duration := time.Since(start)
+			start := time.Now()
+			rwl := NewResponseLogger(w)
+			rwl.MaxBody = cfg.MaxBodySize
+			// swagger html, metrics and export streams are too big to keep in memory, avatars are binary,
+			// invite tokens are in the path, nor the personal data export
+			rwl.SkipBody = strings.HasPrefix(r.URL.Path, "/swagger/") || r.URL.Path == "/metrics" || r.URL.Path == "/users:export" || r.URL.Path == "/me/export" ||
+				r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/avatar") ||
+				logPath(r.URL.Path) != r.URL.Path ||
+				cfg.Body == bodyLogOff || cfg.Body == bodyLogSampled && rand.Float64() >= cfg.SampleRate
 
-		if strings.HasPrefix(r.URL.Path, "/swagger/") {
-			slog.InfoContext(
-				r.Context(), "Request processed",
-				"method", r.Method,
-				"path", r.URL.Path,
-				"remote_addr", r.RemoteAddr,
-				"user_agent", r.UserAgent(),
-				"duration", duration.String(),
-				"status_code", rwl.StatusCode,
-				"body_size", rwl.BodySize,
-				//"body", string(rwl.Body), // With no body to swagger html
-			)
+			var reqBody *bodyCapture
+			if !rwl.SkipBody && r.Body != nil && r.Body != http.NoBody {
+				reqBody = &bodyCapture{ReadCloser: r.Body, max: cfg.MaxBodySize}
+				r.Body = reqBody
+			}
+
+			next.ServeHTTP(rwl, r)
 
-		} else {
-			slog.InfoContext(
-				r.Context(), "Request processed",
+			attrs := []any{
 				"method", r.Method,
-				"path", r.URL.Path,
+				"path", logPath(r.URL.Path),
 				"remote_addr", r.RemoteAddr,
 				"user_agent", r.UserAgent(),
-				"duration", duration.String(),
+				"duration", time.Since(start).String(),
 				"status_code", rwl.StatusCode,
 				"body_size", rwl.BodySize,
-				"body", string(rwl.Body),
-			)
+			}
+			if !rwl.SkipBody && (cfg.Body != bodyLogErrors || rwl.StatusCode >= http.StatusBadRequest) {
+				if reqBody != nil {
+					attrs = append(attrs, "request_body", loggableBody(redactor, r.Header.Get("Content-Type"), reqBody.buf, reqBody.truncated))
+				}
+				attrs = append(attrs, "body", loggableBody(redactor, rwl.Header().Get("Content-Type"), rwl.Body, rwl.Truncated))
+			}
+			slog.InfoContext(r.Context(), "Request processed", attrs...)
+		})
+	}
+}
+
+// validRequestID lets through ids that can't break a log line.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
 		}
-	})
+	}
+	return true
+}
+
+// logPath hides invite tokens, a token is enough to create an account.
+func logPath(path string) string {
+	if strings.HasPrefix(path, "/invitations/") && strings.HasSuffix(path, "/accept") {
+		return "/invitations/{token}/accept"
+	}
+	return path
 }
 
 type ResponseLogger struct {
@@ -57,6 +105,9 @@ type ResponseLogger struct {
 	StatusCode int
 	Body       []byte
 	BodySize   int
+	SkipBody   bool
+	MaxBody    int  // 0 keeps all of it
+	Truncated  bool // Body has only the first MaxBody bytes
 }
 
 func NewResponseLogger(w http.ResponseWriter) *ResponseLogger {
@@ -71,6 +122,37 @@ func (r *ResponseLogger) WriteHeader(statusCode int) {
 func (r *ResponseLogger) Write(b []byte) (int, error) {
 	size, err := r.ResponseWriter.Write(b)
 	r.BodySize += size
-	r.Body = append(r.Body, b...)
+	if !r.SkipBody {
+		r.Body, r.Truncated = appendMax(r.Body, b, r.MaxBody, r.Truncated)
+	}
 	return size, err
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *ResponseLogger) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// bodyCapture keeps what the handler read of the request body, the handler still gets all of it.
+type bodyCapture struct {
+	io.ReadCloser
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *bodyCapture) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf, b.truncated = appendMax(b.buf, p[:n], b.max, b.truncated)
+	return n, err
+}
+
+func appendMax(buf, b []byte, max int, truncated bool) ([]byte, bool) {
+	if max <= 0 {
+		return append(buf, b...), truncated
+	}
+	if room := max - len(buf); len(b) > room {
+		return append(buf, b[:room]...), true
+	}
+	return append(buf, b...), truncated
+}
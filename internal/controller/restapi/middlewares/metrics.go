@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+)
+
+type RequestRecorder interface {
+	ObserveRequest(method, route string, status int, d time.Duration)
+}
+
+// MetricsMiddleware records every request under its route pattern, route resolves it
+// before handlers run. Goes inside LoggerMiddleware to reuse its status capture.
+func MetricsMiddleware(recorder RequestRecorder, route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw, ok := w.(*ResponseLogger)
+			if !ok {
+				rw = NewResponseLogger(w)
+				rw.SkipBody = true
+			}
+			pattern := route(r)
+
+			next.ServeHTTP(rw, r)
+
+			recorder.ObserveRequest(r.Method, pattern, rw.StatusCode, time.Since(start))
+		})
+	}
+}
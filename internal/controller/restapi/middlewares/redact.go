@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"mime"
+	"regexp"
+	"strings"
+)
+
+const redacted = `"[REDACTED]"`
+
+// redactor masks string values of sensitive json keys. It works on the raw text rather
+// than parsing, so a body cut at the max size is still redacted, an unterminated value too.
+type redactor struct {
+	re *regexp.Regexp
+}
+
+// newRedactor is nil without fields, nothing is redacted then.
+func newRedactor(fields []string) *redactor {
+	var names []string
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			names = append(names, regexp.QuoteMeta(f))
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	// "refresh_token": "..." or "newPassword":"... up to the end of a cut body
+	re := regexp.MustCompile(`(?i)("[\w-]*(?:` + strings.Join(names, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*(?:"|\\?$)`)
+	return &redactor{re: re}
+}
+
+func (r *redactor) redact(b []byte) []byte {
+	if r == nil {
+		return b
+	}
+	return r.re.ReplaceAll(b, []byte("${1}"+redacted))
+}
+
+// loggableBody, only json is logged, other bodies (csv imports, images) can't be redacted.
+func loggableBody(r *redactor, contentType string, body []byte, truncated bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt != "application/json" && mt != "application/x-ndjson" && !strings.HasSuffix(mt, "+json") {
+		if contentType == "" {
+			contentType = "no content type"
+		}
+		return "[not logged: " + contentType + "]"
+	}
+	s := string(r.redact(body))
+	if truncated {
+		s += "...[truncated]"
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package middlewares
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	r := newRedactor([]string{"token", " password ", "email", ""})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "field",
+			body: `{"email":"john@example.com","name":"John"}`,
+			want: `{"email":"[REDACTED]","name":"John"}`,
+		},
+		{
+			name: "key ending with field",
+			body: `{"refresh_token":"abc","newPassword":"secret"}`,
+			want: `{"refresh_token":"[REDACTED]","newPassword":"[REDACTED]"}`,
+		},
+		{
+			name: "case and spaces",
+			body: `{"Password" : "secret"}`,
+			want: `{"Password" : "[REDACTED]"}`,
+		},
+		{
+			name: "escaped quote in value",
+			body: `{"password":"se\"cr\\et","name":"John"}`,
+			want: `{"password":"[REDACTED]","name":"John"}`,
+		},
+		{
+			name: "nested object",
+			body: `{"user":{"email":"john@example.com"}}`,
+			want: `{"user":{"email":"[REDACTED]"}}`,
+		},
+		{
+			name: "body cut inside the value",
+			body: `{"name":"John","password":"sec`,
+			want: `{"name":"John","password":"[REDACTED]"`,
+		},
+		{
+			name: "body cut after a backslash",
+			body: `{"password":"sec\`,
+			want: `{"password":"[REDACTED]"`,
+		},
+		{
+			name: "key with field in the middle is kept",
+			body: `{"password_hash":"x","tokens":"y"}`,
+			want: `{"password_hash":"x","tokens":"y"}`,
+		},
+		{
+			name: "non string values are kept",
+			body: `{"token":null,"email":3}`,
+			want: `{"token":null,"email":3}`,
+		},
+		{
+			name: "field as a value is kept",
+			body: `{"type":"password"}`,
+			want: `{"type":"password"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(r.redact([]byte(tt.body))); got != tt.want {
+				t.Errorf("redact() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRedactorWithoutFields(t *testing.T) {
+	for _, fields := range [][]string{nil, {}, {"", " "}} {
+		r := newRedactor(fields)
+		if r != nil {
+			t.Fatalf("newRedactor(%q) = %v, want nil", fields, r)
+		}
+		if got := string(r.redact([]byte(`{"password":"x"}`))); got != `{"password":"x"}` {
+			t.Errorf("nil redactor changed the body: %s", got)
+		}
+	}
+}
+
+func TestLoggableBody(t *testing.T) {
+	r := newRedactor([]string{"password"})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		truncated   bool
+		want        string
+	}{
+		{name: "empty", contentType: "application/json", body: "", want: ""},
+		{name: "json", contentType: "application/json", body: `{"password":"x"}`, want: `{"password":"[REDACTED]"}`},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", body: `{"a":1}`, want: `{"a":1}`},
+		{name: "problem json", contentType: "application/problem+json", body: `{"a":1}`, want: `{"a":1}`},
+		{name: "ndjson", contentType: "application/x-ndjson", body: "{\"password\":\"x\"}\n{\"password\":\"y\"}", want: "{\"password\":\"[REDACTED]\"}\n{\"password\":\"[REDACTED]\"}"},
+		{name: "truncated", contentType: "application/json", body: `{"password":"x`, truncated: true, want: `{"password":"[REDACTED]"...[truncated]`},
+		{name: "csv", contentType: "text/csv", body: "name,password\nJohn,x", want: "[not logged: text/csv]"},
+		{name: "image", contentType: "image/png", body: "\x89PNG", want: "[not logged: image/png]"},
+		{name: "no content type", contentType: "", body: `{"password":"x"}`, want: "[not logged: no content type]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loggableBody(r, tt.contentType, []byte(tt.body), tt.truncated); got != tt.want {
+				t.Errorf("loggableBody() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
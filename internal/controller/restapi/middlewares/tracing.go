@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"net/http"
+)
+
+// TracingMiddleware starts the request span, continuing the caller's trace when it sends traceparent.
+// Goes outermost, so every log line of the request has the trace id.
+func TracingMiddleware(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, "http",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + route(r)
+			}),
+			// scrapes and probes come every few seconds, nothing to see there
+			otelhttp.WithFilter(func(r *http.Request) bool {
+				return r.URL.Path != "/metrics" && r.URL.Path != "/healthz" && r.URL.Path != "/readyz"
+			}),
+		)
+	}
+}
@@ -0,0 +1,215 @@
+package databases
+
+import (
+	"context"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/pkg/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replay lag in seconds, 0 on primary and on a replica that replayed everything it received
+// (otherwise an idle primary would look like a lagging replica).
+const replicaLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END::float8`
+
+// Cluster routes reads to healthy replicas and everything else to primary.
+// A requester who just wrote reads from primary for a while to see their own writes.
+type Cluster struct {
+	primary  *pgxpool.Pool
+	replicas []*replica
+	next     atomic.Uint64
+	sticky   *stickiness
+	cfg      config.DBReplicas
+}
+
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+type ReplicaStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+}
+
+// NewCluster does not wait for replicas, they join the rotation after the first health check.
+// Replicas are configured like primary, see replicaPoolConfig.
+func NewCluster(ctx context.Context, primary *pgxpool.Pool, c *config.DBReplicas) (*Cluster, error) {
+	cl := &Cluster{
+		primary: primary,
+		sticky:  newStickiness(c.StickyWindow),
+		cfg:     *c,
+	}
+	for _, dsn := range c.DSNs {
+		poolCfg, err := replicaPoolConfig(primary.Config(), dsn)
+		if err != nil {
+			cl.Close()
+			return nil, err
+		}
+		pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+		if err != nil {
+			cl.Close()
+			return nil, err
+		}
+		cfg := pool.Config().ConnConfig
+		cl.replicas = append(cl.replicas, &replica{
+			name: fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+			pool: pool,
+		})
+	}
+	return cl, nil
+}
+
+func (c *Cluster) Primary() *pgxpool.Pool {
+	return c.primary
+}
+
+// Pools are all pools by name, "primary" and replica host:port, for stats.
+func (c *Cluster) Pools() map[string]*pgxpool.Pool {
+	pools := map[string]*pgxpool.Pool{"primary": c.primary}
+	for _, r := range c.replicas {
+		pools[r.name] = r.pool
+	}
+	return pools
+}
+
+// Reader picks next healthy replica, primary if there are none or the requester is sticky.
+func (c *Cluster) Reader(ctx context.Context) *pgxpool.Pool {
+	if len(c.replicas) == 0 {
+		return c.primary
+	}
+	if id, ok := domain.RequesterID(ctx); ok && c.sticky.active(id) {
+		return c.primary
+	}
+
+	start := c.next.Add(1)
+	for i := range c.replicas {
+		r := c.replicas[(int(start)+i)%len(c.replicas)]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+	return c.primary
+}
+
+// MarkWrite makes the requester read from primary for the sticky window.
+// Stickiness is per app instance, a request landing on another replica of the app
+// can still read stale data.
+func (c *Cluster) MarkWrite(ctx context.Context) {
+	if len(c.replicas) == 0 {
+		return
+	}
+	if id, ok := domain.RequesterID(ctx); ok {
+		c.sticky.mark(id)
+	}
+}
+
+// RunHealthChecks blocks until ctx is done.
+func (c *Cluster) RunHealthChecks(ctx context.Context) {
+	if len(c.replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(c.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		c.checkReplicas(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cluster) checkReplicas(ctx context.Context) {
+	for _, r := range c.replicas {
+		err := c.checkReplica(ctx, r)
+		healthy := err == nil
+		if was := r.healthy.Swap(healthy); was != healthy {
+			if healthy {
+				slog.InfoContext(ctx, "Replica is back in rotation", "replica", r.name)
+			} else {
+				slog.WarnContext(ctx, "Replica removed from rotation", "replica", r.name, "error", err)
+			}
+		}
+	}
+}
+
+func (c *Cluster) checkReplica(ctx context.Context, r *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	var lag float64
+	if err := r.pool.QueryRow(ctx, replicaLagQuery).Scan(&lag); err != nil {
+		return err
+	}
+	if c.cfg.MaxLag > 0 && time.Duration(lag*float64(time.Second)) > c.cfg.MaxLag {
+		return &lagError{lag: time.Duration(lag * float64(time.Second))}
+	}
+	return nil
+}
+
+func (c *Cluster) ReplicaStatus() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		statuses = append(statuses, ReplicaStatus{Name: r.name, Healthy: r.healthy.Load()})
+	}
+	return statuses
+}
+
+func (c *Cluster) Close() {
+	for _, r := range c.replicas {
+		r.pool.Close()
+	}
+	c.primary.Close()
+}
+
+type lagError struct {
+	lag time.Duration
+}
+
+func (e *lagError) Error() string {
+	return "replica lags behind by " + e.lag.String()
+}
+
+// stickiness remembers when requesters wrote last.
+type stickiness struct {
+	window time.Duration
+	mu     sync.Mutex
+	until  map[int]time.Time
+}
+
+func newStickiness(window time.Duration) *stickiness {
+	return &stickiness{window: window, until: make(map[int]time.Time)}
+}
+
+func (s *stickiness) mark(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.until) > 10_000 {
+		for k, t := range s.until {
+			if now.After(t) {
+				delete(s.until, k)
+			}
+		}
+	}
+	s.until[id] = now.Add(s.window)
+}
+
+func (s *stickiness) active(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.until[id]
+	return ok && time.Now().Before(until)
+}
@@ -1,40 +1,185 @@
 package databases
 
 import (
-	"database/sql"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/Arh0rn/test-task1/pkg/config"
 	"github.com/avast/retry-go"
-	_ "github.com/lib/pq"
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
 	"time"
+	"unicode"
 )
 
 var (
-	dsnTemplate      = "host=%s user=%s password=%s dbname=%s sslmode=disable"
-	attempts    uint = 3
-	delay            = time.Second
+	attempts uint = 3
+	delay         = time.Second
+
+	ErrNoDatabaseName = errors.New("db.name is required when DB_DSN is not set")
+	ErrBadSSLMode     = errors.New("unknown db.sslmode")
 )
 
-func NewPostgresConnection(c *config.Database) (*sql.DB, error) {
-	dsn := fmt.Sprintf(dsnTemplate, c.Host, c.User, c.Password, c.DBName)
-	db, err := sql.Open("postgres", dsn)
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true,
+	"require": true, "verify-ca": true, "verify-full": true,
+}
+
+// PoolHooks are pgxpool callbacks, nil ones are not set.
+type PoolHooks struct {
+	// AfterConnect runs on every new connection, repositories prepare their statements in it.
+	AfterConnect func(context.Context, *pgx.Conn) error
+	// BeforeAcquire runs every time a connection is taken, false drops the connection.
+	BeforeAcquire func(context.Context, *pgx.Conn) bool
+	BeforeClose   func(*pgx.Conn)
+}
+
+// NewPostgresPool connects and pings, pass zero hooks when there is nothing to set up.
+func NewPostgresPool(ctx context.Context, c *config.Database, hooks PoolHooks) (*pgxpool.Pool, error) {
+	pool, err := newPool(ctx, c, hooks)
 	if err != nil {
 		return nil, err
 	}
 
-	//err = db.Ping()
-	//if err != nil {
-	//	return nil, fmt.Errorf("could not ping postgres: %w", err)
-	//}
-
 	err = retry.Do( //Try to ping DB several times
-		db.Ping,
+		func() error { return pool.Ping(ctx) },
 		retry.Attempts(attempts),
 		retry.Delay(delay),
 	)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// newPool does not connect yet, connections are opened on demand.
+func newPool(ctx context.Context, c *config.Database, hooks PoolHooks) (*pgxpool.Pool, error) {
+	dsn, err := PostgresDSN(c)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	poolCfg.MaxConns = c.Pool.MaxConns
+	poolCfg.MinConns = c.Pool.MinConns
+	poolCfg.MaxConnLifetime = c.Pool.ConnMaxLifetime
+	poolCfg.MaxConnIdleTime = c.Pool.ConnMaxIdleTime
+	poolCfg.HealthCheckPeriod = c.Pool.HealthCheckPeriod
+	poolCfg.AfterConnect = hooks.AfterConnect
+	poolCfg.BeforeAcquire = hooks.BeforeAcquire
+	poolCfg.BeforeClose = hooks.BeforeClose
+	// span per query, statement text but never its arguments
+	poolCfg.ConnConfig.Tracer = otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName(), otelpgx.WithSpanNameFunc(querySpanName))
+
+	return pgxpool.NewWithConfig(ctx, poolCfg)
+}
+
+// replicaPoolConfig connects to the replica in dsn with everything else from the primary: pool settings,
+// hooks, tls and run-time parameters. Only the address, database and credentials of dsn are used,
+// so a replica is never reached with weaker tls than the primary.
+func replicaPoolConfig(primary *pgxpool.Config, dsn string) (*pgxpool.Config, error) {
+	replica, err := pgconn.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
+	cfg := primary.Copy()
+	conn := cfg.ConnConfig
+	conn.Host, conn.Port = replica.Host, replica.Port
+	conn.Database, conn.User, conn.Password = replica.Database, replica.User, replica.Password
+	conn.TLSConfig = tlsForHost(conn.TLSConfig, replica.Host)
+
+	// sslmode prefer and allow are fallbacks with and without tls, other hosts of the primary are dropped
+	conn.Fallbacks = nil
+	for _, fb := range primary.ConnConfig.Fallbacks {
+		if fb.Host == primary.ConnConfig.Host && fb.Port == primary.ConnConfig.Port {
+			conn.Fallbacks = append(conn.Fallbacks, &pgconn.FallbackConfig{
+				Host:      replica.Host,
+				Port:      replica.Port,
+				TLSConfig: tlsForHost(fb.TLSConfig, replica.Host),
+			})
+		}
+	}
+	return cfg, nil
+}
+
+// tlsForHost, verify-full checks the certificate against the host name, it has to be the replica's.
+func tlsForHost(c *tls.Config, host string) *tls.Config {
+	if c == nil {
+		return nil
+	}
+	c = c.Clone()
+	if c.ServerName != "" {
+		c.ServerName = host
+	}
+	return c
+}
+
+// querySpanName, repositories run prepared statements by name and the name says more than
+// the sql, ad hoc queries are named by their first keyword.
+func querySpanName(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexFunc(sql, unicode.IsSpace); i > 0 {
+		return strings.ToUpper(sql[:i])
+	}
+	return sql
+}
+
+// PostgresDSN builds libpq key=value connection string, DSN from config wins if set.
+func PostgresDSN(c *config.Database) (string, error) {
+	if c.DSN != "" {
+		return c.DSN, nil
+	}
+	if c.DBName == "" {
+		return "", ErrNoDatabaseName
+	}
+	if !sslModes[c.SSLMode] {
+		return "", fmt.Errorf("%w: %q", ErrBadSSLMode, c.SSLMode)
+	}
+
+	params := [][2]string{
+		{"host", c.Host},
+		{"port", fmt.Sprint(c.Port)},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.DBName},
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+		{"application_name", c.ApplicationName},
+	}
+	if c.SearchPath != "" {
+		// Unknown keys are sent to the server as run-time parameters
+		params = append(params, [2]string{"search_path", c.SearchPath})
+	}
+
+	var b strings.Builder
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p[0])
+		b.WriteByte('=')
+		b.WriteString(quoteDSNValue(p[1]))
+	}
+	return b.String(), nil
+}
 
-	return db, nil
+// quoteDSNValue quotes as libpq (and pgx) wants: single quotes, backslash escapes ' and \.
+func quoteDSNValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
 }
@@ -0,0 +1,83 @@
+package databases
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testing"
+)
+
+func TestReplicaPoolConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		primary       string
+		replica       string
+		wantTLS       bool
+		wantFallbacks int // without tls
+	}{
+		{
+			name:    "verify-full is kept over the replica's sslmode",
+			primary: "host=primary.db port=5432 dbname=app user=app sslmode=verify-full application_name=users search_path=app",
+			replica: "postgres://ro:pw@replica.db:5433/app_ro?sslmode=disable",
+			wantTLS: true,
+		},
+		{
+			name:    "require",
+			primary: "host=primary.db dbname=app sslmode=require application_name=users search_path=app",
+			replica: "host=replica.db port=5433 dbname=app_ro user=ro password=pw",
+			wantTLS: true,
+		},
+		{
+			name:          "prefer falls back to plain on the replica",
+			primary:       "host=primary.db dbname=app sslmode=prefer application_name=users search_path=app",
+			replica:       "host=replica.db port=5433 dbname=app_ro user=ro password=pw sslmode=verify-full",
+			wantTLS:       true,
+			wantFallbacks: 1,
+		},
+		{
+			name:    "disable",
+			primary: "host=primary.db dbname=app sslmode=disable application_name=users search_path=app",
+			replica: "host=replica.db port=5433 dbname=app_ro user=ro password=pw sslmode=require",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, err := pgxpool.ParseConfig(tt.primary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			primary.MaxConns = 7
+
+			cfg, err := replicaPoolConfig(primary, tt.replica)
+			if err != nil {
+				t.Fatalf("replicaPoolConfig() error = %v", err)
+			}
+			conn := cfg.ConnConfig
+			if conn.Host != "replica.db" || conn.Port != 5433 || conn.Database != "app_ro" || conn.User != "ro" || conn.Password != "pw" {
+				t.Errorf("address = %s@%s:%d/%s, want ro@replica.db:5433/app_ro", conn.User, conn.Host, conn.Port, conn.Database)
+			}
+			if cfg.MaxConns != 7 {
+				t.Errorf("MaxConns = %d, want 7 of the primary", cfg.MaxConns)
+			}
+			if conn.RuntimeParams["application_name"] != "users" || conn.RuntimeParams["search_path"] != "app" {
+				t.Errorf("RuntimeParams = %v, want the primary's", conn.RuntimeParams)
+			}
+
+			if (conn.TLSConfig != nil) != tt.wantTLS {
+				t.Fatalf("tls = %v, want %v", conn.TLSConfig != nil, tt.wantTLS)
+			}
+			if want := primary.ConnConfig.TLSConfig; want != nil && want.ServerName != "" && conn.TLSConfig.ServerName != "replica.db" {
+				t.Errorf("ServerName = %q, want replica.db", conn.TLSConfig.ServerName)
+			}
+			if len(conn.Fallbacks) != tt.wantFallbacks {
+				t.Fatalf("fallbacks = %d, want %d", len(conn.Fallbacks), tt.wantFallbacks)
+			}
+			for _, fb := range conn.Fallbacks {
+				if fb.Host != "replica.db" || fb.Port != 5433 || fb.TLSConfig != nil {
+					t.Errorf("fallback = %s:%d tls %v, want replica.db:5433 without tls", fb.Host, fb.Port, fb.TLSConfig != nil)
+				}
+			}
+			if primary.ConnConfig.Host != "primary.db" {
+				t.Errorf("primary config changed, host = %s", primary.ConnConfig.Host)
+			}
+		})
+	}
+}
@@ -4,19 +4,26 @@ import (
 	"context"
 	"fmt"
 	"github.com/Arh0rn/test-task1/pkg/config"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+// NewRedisClient returns the client even if ping failed, go-redis reconnects on its own
+// so the caller decides whether unavailable redis is fatal.
 func NewRedisClient(c *config.Cache) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%v:%v", c.Host, c.Port),
 		Password: c.Password,
 		DB:       c.DBIndex,
 	})
+	// commands carry cached users, keep them out of spans
+	if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
+		return client, err
+	}
 
 	_, err := client.Ping(context.Background()).Result()
 	if err != nil {
-		return nil, err
+		return client, err
 	}
 
 	return client, nil
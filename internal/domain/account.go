@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// Account is the user as its owner sees it, across organizations.
+type Account struct {
+	User
+	DeleteAfter time.Time // zero when no deletion is scheduled
+}
+
+// AccountExport is everything stored about a user, GET /me/export.
+type AccountExport struct {
+	Account     *Account
+	Memberships []*Membership
+	Sessions    []*Session
+	AuditEvents []*AuditEvent
+	ExportedAt  time.Time
+}
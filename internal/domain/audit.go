@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrImpersonating           = errors.New("not allowed while impersonating")
+	ErrImpersonationNotAllowed = errors.New("impersonation not allowed")
+)
+
+// Audit actions
+const (
+	AuditImpersonationStarted = "impersonation.started"
+	AuditDeletionScheduled    = "account.deletion_scheduled"
+)
+
+// AuditEvent records who did what to whom. ActorID and SubjectID become 0 when
+// the user is deleted, events are kept without them.
+type AuditEvent struct {
+	ID        int64
+	OrgID     int
+	ActorID   int
+	SubjectID int
+	Action    string
+	Details   map[string]any // ids and times only, no personal data
+	CreatedAt time.Time
+}
@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrBlobNotFound   = errors.New("blob not found")
+	ErrAvatarNotFound = errors.New("avatar not found")
+	ErrInvalidAvatar  = errors.New("invalid avatar image")
+	ErrAvatarSize     = errors.New("unknown avatar size")
+)
+
+// Blob is a small stored object, read whole into memory.
+type Blob struct {
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+}
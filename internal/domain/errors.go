@@ -7,6 +7,8 @@ var (
 	ErrUserAlreadyExists  = errors.New("user with this email already exists")
 	ErrInvalidCredentials = errors.New("email or password is incorrect")
 	ErrValidation         = errors.New("invalid email or password, password must be at least 8 characters long")
+	ErrInvalidMetadata    = errors.New("metadata does not match schema")
+	ErrSharedUser         = errors.New("user is a member of other organizations, only they can change their profile")
 
 	//ErrUserInvalid  = rest_errors.New("user invalid")
 
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+type HealthStatus string
+
+const (
+	HealthUp       HealthStatus = "up"
+	HealthDown     HealthStatus = "down"
+	HealthDegraded HealthStatus = "degraded" // only optional dependencies are down, still serving
+	HealthShutdown HealthStatus = "shutting_down"
+)
+
+// DependencyHealth is the result of one readiness check.
+type DependencyHealth struct {
+	Status   HealthStatus
+	Optional bool
+	Duration time.Duration
+}
+
+type Readiness struct {
+	Status       HealthStatus
+	Dependencies map[string]DependencyHealth
+}
+
+// Ready is false when traffic should go to other instances.
+func (r *Readiness) Ready() bool {
+	return r.Status == HealthUp || r.Status == HealthDegraded
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrImportJobNotFound = errors.New("import job not found")
+
+// ImportRow is one parsed row of an import file. Err is set when the row is invalid,
+// Input then holds whatever could be parsed or is nil.
+type ImportRow struct {
+	Line  int
+	Input *SignUpInput
+	Err   error
+}
+
+type ImportRowStatus string
+
+const (
+	ImportRowCreated ImportRowStatus = "created"
+	ImportRowExists  ImportRowStatus = "exists" // email is already taken, row skipped
+	ImportRowInvalid ImportRowStatus = "invalid"
+	ImportRowFailed  ImportRowStatus = "failed"
+)
+
+type ImportRowResult struct {
+	Line   int
+	Email  string
+	Status ImportRowStatus
+	ID     int // set for created rows
+	Error  string
+}
+
+type ImportReport struct {
+	Total   int
+	Created int
+	Exists  int
+	Invalid int
+	Failed  int
+	Rows    []ImportRowResult
+}
+
+type ImportJobStatus string
+
+const (
+	ImportJobRunning ImportJobStatus = "running"
+	ImportJobDone    ImportJobStatus = "done"
+	ImportJobFailed  ImportJobStatus = "failed"
+)
+
+type ImportJob struct {
+	ID         string
+	OrgID      int // only visible in this organization
+	Status     ImportJobStatus
+	Report     *ImportReport // progress while running
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found or already used")
+	ErrInvitationExpired  = errors.New("invitation expired")
+	ErrSignUpDisabled     = errors.New("sign up is by invitation only")
+	ErrNotifier           = errors.New("failed to deliver notification")
+	ErrNameRequired       = errors.New("name is required for a new account")
+)
+
+// Invitation lets Email join the organization with Role, once.
+type Invitation struct {
+	ID        string
+	OrgID     int
+	OrgName   string
+	Email     string
+	Role      Role
+	InvitedBy int // 0 when the inviter is gone
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type InvitationInput struct {
+	Email string
+	Role  Role
+}
+
+// AcceptInvitationInput is the new account. When the email is registered already
+// Password must be the account's one and the rest is ignored.
+type AcceptInvitationInput struct {
+	Name     string
+	Password string
+	Profile
+}
+
+// Message is a plain text notification to one address.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
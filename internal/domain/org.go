@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrOrgNotFound = errors.New("organization not found")
+	ErrNoOrg       = errors.New("no organization selected")
+	ErrNotMember   = errors.New("not a member of the organization")
+	ErrForbidden   = errors.New("not allowed for your role in the organization")
+	ErrLastOwner   = errors.New("organization must keep at least one owner")
+)
+
+// Role of a user in an organization, every role can do what the ones below it can.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+var roleRank = map[Role]int{RoleMember: 1, RoleAdmin: 2, RoleOwner: 3}
+
+func (r Role) Valid() bool {
+	return roleRank[r] != 0
+}
+
+func (r Role) AtLeast(other Role) bool {
+	return roleRank[r] >= roleRank[other]
+}
+
+type Organization struct {
+	ID        int
+	Name      string
+	CreatedAt time.Time
+}
+
+type Membership struct {
+	Org       Organization
+	UserID    int
+	Role      Role
+	CreatedAt time.Time
+}
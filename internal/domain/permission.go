@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrGroupNotFound     = errors.New("group not found")
+	ErrGroupExists       = errors.New("group with this name already exists")
+	ErrNotInGroup        = errors.New("user is not in the group")
+)
+
+// Permission is "<resource>:<action>", checked in the caller's current organization.
+type Permission string
+
+const (
+	PermUsersRead   Permission = "users:read"
+	PermUsersWrite  Permission = "users:write"
+	PermUsersDelete Permission = "users:delete"
+	PermUsersImport Permission = "users:import"
+	PermUsersExport Permission = "users:export"
+	PermUsersInvite Permission = "users:invite"
+	PermGroupsRead  Permission = "groups:read"
+	PermGroupsWrite Permission = "groups:write"
+)
+
+var AllPermissions = []Permission{
+	PermUsersRead, PermUsersWrite, PermUsersDelete, PermUsersImport, PermUsersExport, PermUsersInvite,
+	PermGroupsRead, PermGroupsWrite,
+}
+
+func (p Permission) Valid() bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions are granted by the role alone, groups add to them.
+var RolePermissions = map[Role][]Permission{
+	RoleOwner:  AllPermissions,
+	RoleAdmin:  AllPermissions,
+	RoleMember: {PermUsersRead, PermGroupsRead},
+}
+
+// Group gives its members permissions in one organization.
+type Group struct {
+	ID          int
+	Name        string
+	Permissions []Permission
+	MemberIDs   []int
+	CreatedAt   time.Time
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "context"
+
+type requesterKey struct{}
+
+// Requester is the authenticated caller, from the access token.
+type Requester struct {
+	UserID int
+	Email  string
+	OrgID  int // current organization, 0 when the token has none
+	// SessionID is the login the token belongs to, the actor's one when impersonating
+	SessionID string
+	// ActorID is the admin impersonating UserID, 0 normally
+	ActorID int
+}
+
+func (r Requester) Impersonated() bool {
+	return r.ActorID != 0
+}
+
+// WithRequester stores the caller, set by auth middleware.
+func WithRequester(ctx context.Context, r Requester) context.Context {
+	return context.WithValue(ctx, requesterKey{}, r)
+}
+
+func RequesterFrom(ctx context.Context) (Requester, bool) {
+	r, ok := ctx.Value(requesterKey{}).(Requester)
+	return r, ok
+}
+
+func RequesterID(ctx context.Context) (int, bool) {
+	r, ok := RequesterFrom(ctx)
+	return r.UserID, ok
+}
+
+// OrgID is the organization all user operations of the request are scoped to.
+func OrgID(ctx context.Context) (int, bool) {
+	r, ok := RequesterFrom(ctx)
+	return r.OrgID, ok && r.OrgID != 0
+}
+
+type unscopedKey struct{}
+
+// WithUnscoped marks operations that must see users of every organization, like login
+// by email or the account purge. Row level security is lifted for them, keep it to
+// paths without a requester to scope to.
+func WithUnscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedKey{}, true)
+}
+
+func Unscoped(ctx context.Context) bool {
+	unscoped, _ := ctx.Value(unscopedKey{}).(bool)
+	return unscoped
+}
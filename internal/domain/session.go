@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionRevoked      = errors.New("session expired or revoked")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// Session is one login of a user, tokens carry its id and stop working when it is revoked.
+type Session struct {
+	ID         string
+	UserID     int
+	OrgID      int // organization tokens of the session are issued for, 0 for none
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  time.Time // zero while the session is not revoked
+}
+
+// Tokens are issued at login. RefreshToken is empty when only the access token
+// is reissued, e.g. on organization switch.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	SessionID    string
+}
+
+type clientKey struct{}
+
+// Client is where the request came from, sessions remember it.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func WithClient(ctx context.Context, c Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+func ClientFrom(ctx context.Context) Client {
+	c, _ := ctx.Value(clientKey{}).(Client)
+	return c
+}
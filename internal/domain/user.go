@@ -1,30 +1,114 @@
 package domain
 
+import (
+	"slices"
+	"time"
+)
+
 type User struct {
-	ID       int
-	Name     string
-	Email    string
-	Password string
+	ID        int
+	Name      string
+	Email     string
+	Password  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Profile
+
+	OrgIDs []int // organizations the user is a member of, filled by GetByID only
+}
+
+// Clone copies the user with its metadata and organizations, for users shared between requests.
+func (u *User) Clone() *User {
+	clone := *u
+	clone.OrgIDs = slices.Clone(u.OrgIDs)
+	if u.Metadata != nil {
+		clone.Metadata = cloneJSON(u.Metadata).(map[string]any)
+	}
+	return &clone
+}
+
+// cloneJSON deep copies what encoding/json decodes into any.
+func cloneJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = cloneJSON(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = cloneJSON(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// Profile is optional part of the user, empty strings mean not set.
+type Profile struct {
+	DisplayName string
+	AvatarURL   string
+	Locale      string         // BCP 47, e.g. en-US
+	Timezone    string         // IANA, e.g. Europe/Berlin
+	Phone       string         // E.164
+	Metadata    map[string]any // custom attributes, validated against deployment's JSON Schema
 }
 
 type SignUpInput struct {
 	Name     string
 	Email    string
 	Password string
+	Profile
 }
 
 type LoginInput struct {
 	Email    string
 	Password string
+	OrgID    int
 }
 
 type UserOutput struct {
-	ID    int
-	Name  string
-	Email string
+	ID        int
+	Name      string
+	Email     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Profile
 }
 
+// UserUpdate replaces the user, profile fields left empty are cleared.
 type UserUpdate struct {
-	Name  string
-	Email string
+	Name      string
+	Email     string
+	UpdatedAt time.Time // set by repository
+	Profile
+}
+
+type UserListQuery struct {
+	Limit  int
+	Offset int
+}
+
+type UserList struct {
+	Users []*User
+	Total int
+}
+
+type UserSearchQuery struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+type UserSearchHit struct {
+	User *User
+	Rank float64 // higher is better, substring matches rank above fuzzy ones
+}
+
+type UserSearchResult struct {
+	Hits  []*UserSearchHit
+	Total int
 }
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"github.com/Arh0rn/test-task1/pkg/breaker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Metrics are the app's prometheus collectors. They live in their own registry,
+// so nothing registered globally by libraries ends up on /metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	cache        *prometheus.CounterVec
+	breakerState *prometheus.GaugeVec
+	breakerTrips *prometheus.CounterVec
+	logins       *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_requests_total",
+			Help: "Cache calls by tier, operation and result: hit, miss or error for reads, ok or error for writes.",
+		}, []string{"cache", "tier", "op", "result"}),
+		breakerState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cache_breaker_state",
+			Help: "Circuit breaker state: 0 closed, 1 open, 2 half-open.",
+		}, []string{"breaker"}),
+		breakerTrips: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_breaker_trips_total",
+			Help: "Times the circuit breaker opened.",
+		}, []string{"breaker"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logins_total",
+			Help: "Login attempts by result.",
+		}, []string{"result"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.cache, m.breakerState, m.breakerTrips, m.logins,
+	)
+	return m
+}
+
+// Register adds more collectors, e.g. db pool stats.
+func (m *Metrics) Register(c prometheus.Collector) {
+	m.registry.MustRegister(c)
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest, route is the pattern the request matched, not the path, to keep label values few.
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+// CacheResult, tier is local for process memory and redis for the shared one.
+func (m *Metrics) CacheResult(cache, tier, op, result string) {
+	m.cache.WithLabelValues(cache, tier, op, result).Inc()
+}
+
+// BreakerState sets the state gauge, opening counts as a trip. Call it with the initial state too,
+// so the series exist before the first transition.
+func (m *Metrics) BreakerState(name string, state breaker.State) {
+	m.breakerState.WithLabelValues(name).Set(float64(state))
+	trips := m.breakerTrips.WithLabelValues(name)
+	if state == breaker.Open {
+		trips.Inc()
+	}
+}
+
+func (m *Metrics) Login(result string) {
+	m.logins.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolLabels   = []string{"pool"}
+	acquiredDesc = prometheus.NewDesc("db_pool_acquired_conns", "Connections in use.", poolLabels, nil)
+	idleDesc     = prometheus.NewDesc("db_pool_idle_conns", "Idle connections.", poolLabels, nil)
+	totalDesc    = prometheus.NewDesc("db_pool_total_conns", "All open connections, including ones being established.", poolLabels, nil)
+	maxDesc      = prometheus.NewDesc("db_pool_max_conns", "Pool size limit.", poolLabels, nil)
+	acquiresDesc = prometheus.NewDesc("db_pool_acquires_total", "Successful connection acquires.", poolLabels, nil)
+	waitsDesc    = prometheus.NewDesc("db_pool_empty_acquires_total", "Acquires that had to wait for a connection.", poolLabels, nil)
+	waitDesc     = prometheus.NewDesc("db_pool_acquire_seconds_total", "Time spent acquiring connections.", poolLabels, nil)
+	canceledDesc = prometheus.NewDesc("db_pool_canceled_acquires_total", "Acquires canceled by context.", poolLabels, nil)
+)
+
+// PoolCollector reads pgx pool stats on every scrape.
+type PoolCollector struct {
+	pools func() map[string]*pgxpool.Pool
+}
+
+// NewPoolCollector takes a func, so pools added later are collected too.
+func NewPoolCollector(pools func() map[string]*pgxpool.Pool) *PoolCollector {
+	return &PoolCollector{pools: pools}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{acquiredDesc, idleDesc, totalDesc, maxDesc, acquiresDesc, waitsDesc, waitDesc, canceledDesc} {
+		ch <- d
+	}
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, pool := range c.pools() {
+		s := pool.Stat()
+		ch <- prometheus.MustNewConstMetric(acquiredDesc, prometheus.GaugeValue, float64(s.AcquiredConns()), name)
+		ch <- prometheus.MustNewConstMetric(idleDesc, prometheus.GaugeValue, float64(s.IdleConns()), name)
+		ch <- prometheus.MustNewConstMetric(totalDesc, prometheus.GaugeValue, float64(s.TotalConns()), name)
+		ch <- prometheus.MustNewConstMetric(maxDesc, prometheus.GaugeValue, float64(s.MaxConns()), name)
+		ch <- prometheus.MustNewConstMetric(acquiresDesc, prometheus.CounterValue, float64(s.AcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(waitsDesc, prometheus.CounterValue, float64(s.EmptyAcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(waitDesc, prometheus.CounterValue, s.AcquireDuration().Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(canceledDesc, prometheus.CounterValue, float64(s.CanceledAcquireCount()), name)
+	}
+}
@@ -0,0 +1,272 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io/fs"
+	"log/slog"
+)
+
+// Any constant works, it just has to be the same for every replica.
+const lockID = 7_356_251_042
+
+var (
+	ErrDirty          = errors.New("database is dirty, fix the schema by hand and run `migrate force <version>`")
+	ErrUnknownVersion = errors.New("no migration with such version")
+	ErrNoDownFile     = errors.New("migration has no down file")
+)
+
+// Migrator applies embedded migrations. schema_migrations has the same shape as
+// golang-migrate uses, so databases migrated by the old Makefile continue as is.
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []*Migration
+}
+
+func New(db *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+type Status struct {
+	Version    uint // 0 means nothing applied
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	var st *Status
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		version, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		st = &Status{Version: version, Dirty: dirty}
+		for _, mig := range m.migrations {
+			st.Migrations = append(st.Migrations, MigrationStatus{
+				Version: mig.Version,
+				Name:    mig.Name,
+				Applied: mig.Version <= version,
+			})
+		}
+		return nil
+	})
+	return st, err
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.Goto(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down rolls back n last applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *pgx.Conn) error {
+		version, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		i := m.index(version)
+		if i < 0 && version != 0 {
+			return fmt.Errorf("%w: %d is applied but unknown to this binary", ErrUnknownVersion, version)
+		}
+		target := uint(0)
+		if i-n >= 0 {
+			target = m.migrations[i-n].Version
+		}
+		return m.migrate(ctx, conn, version, target)
+	})
+}
+
+// Goto migrates up or down to exactly version, 0 rolls everything back.
+func (m *Migrator) Goto(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.withLock(ctx, func(conn *pgx.Conn) error {
+		current, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Force sets version without running anything and clears dirty flag.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.withLock(ctx, func(conn *pgx.Conn) error {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if err := setVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "Migration version forced", "version", version)
+		return tx.Commit(ctx)
+	})
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *pgx.Conn, from, to uint) error {
+	if from == to {
+		slog.InfoContext(ctx, "No migrations to apply", "version", from)
+		return nil
+	}
+
+	if from < to {
+		for _, mig := range m.migrations {
+			if mig.Version <= from || mig.Version > to {
+				continue
+			}
+			if err := step(ctx, conn, mig, mig.Up, mig.Version); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version > from || mig.Version <= to {
+			continue
+		}
+		if mig.Down == "" {
+			return fmt.Errorf("%w: %d_%s", ErrNoDownFile, mig.Version, mig.Name)
+		}
+		prev := uint(0)
+		if i > 0 {
+			prev = m.migrations[i-1].Version
+		}
+		if err := step(ctx, conn, mig, mig.Down, prev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// step runs one migration file and moves version in the same transaction,
+// so a failed migration leaves nothing behind.
+func step(ctx context.Context, conn *pgx.Conn, mig *Migration, query string, newVersion uint) error {
+	slog.InfoContext(ctx, "Applying migration", "version", mig.Version, "name", mig.Name, "to_version", newVersion)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// No arguments, so pgx uses simple protocol and a file may hold many statements
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return fmt.Errorf("migration %d_%s: %w%s", mig.Version, mig.Name, err, details(err))
+	}
+	if err := setVersion(ctx, tx, newVersion); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// details adds what postgres says besides the message, migrations put reports there.
+func details(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+	var s string
+	if pgErr.Detail != "" {
+		s += "\ndetail: " + pgErr.Detail
+	}
+	if pgErr.Hint != "" {
+		s += "\nhint: " + pgErr.Hint
+	}
+	return s
+}
+
+func (m *Migrator) index(version uint) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// withLock holds a session advisory lock on a dedicated connection,
+// replicas starting together wait for each other instead of racing.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	pc, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer pc.Release()
+	conn := pc.Conn()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("could not take migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			slog.ErrorContext(ctx, "Failed to release migration lock", "error", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`,
+	); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func currentVersion(ctx context.Context, conn *pgx.Conn) (uint, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	err := conn.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+func setVersion(ctx context.Context, tx pgx.Tx, version uint) error {
+	if _, err := tx.Exec(ctx, "TRUNCATE schema_migrations"); err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", int64(version))
+	return err
+}
@@ -0,0 +1,20 @@
+package logNotifier
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"log/slog"
+)
+
+// Notifier writes messages to the log instead of sending them. Messages may
+// carry credentials, like invite links, so it is for local development only.
+type Notifier struct{}
+
+func New() *Notifier {
+	return &Notifier{}
+}
+
+func (n *Notifier) Send(ctx context.Context, msg *domain.Message) error {
+	slog.InfoContext(ctx, "Notification", "to", msg.To, "subject", msg.Subject, "text", msg.Text)
+	return nil
+}
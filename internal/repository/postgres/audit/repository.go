@@ -0,0 +1,58 @@
+package postgresAuditRepo
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
+	"log/slog"
+)
+
+// AuditRepository stores events with the organization they carry, it is not scoped by ctx.
+type AuditRepository struct {
+	db *databases.Cluster
+}
+
+func New(db *databases.Cluster) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) q(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Primary())
+}
+
+func (r *AuditRepository) Create(ctx context.Context, e *domain.AuditEvent) error {
+	details := e.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+	err := r.q(ctx).QueryRow(ctx, createEvent, e.OrgID, e.ActorID, e.SubjectID, e.Action, details).
+		Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create audit event", "action", e.Action, "error", err)
+		return err
+	}
+	slog.DebugContext(ctx, "Audit event created", "event_id", e.ID, "action", e.Action)
+	return nil
+}
+
+// ListByUser returns events the user did or that were done to them, the oldest first.
+func (r *AuditRepository) ListByUser(ctx context.Context, userID int) ([]*domain.AuditEvent, error) {
+	rows, err := r.q(ctx).Query(ctx, listByUser, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list audit events", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*domain.AuditEvent{}
+	for rows.Next() {
+		var e domain.AuditEvent
+		if err := rows.Scan(&e.ID, &e.OrgID, &e.ActorID, &e.SubjectID, &e.Action, &e.Details, &e.CreatedAt); err != nil {
+			slog.ErrorContext(ctx, "Failed to list audit events", "error", err)
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,33 @@
+package postgresAuditRepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of statements prepared on every pool connection, queries use them instead of sql text.
+const (
+	createEvent = "audit_create"
+	listByUser  = "audit_list_by_user"
+)
+
+var statements = map[string]string{
+	createEvent: `INSERT INTO audit_events (org_id, actor_id, subject_id, action, details) 
+		VALUES (nullif($1, 0), nullif($2, 0), nullif($3, 0), $4, $5) 
+		RETURNING id, created_at`,
+	listByUser: `SELECT id, coalesce(org_id, 0), coalesce(actor_id, 0), coalesce(subject_id, 0), action, details, created_at 
+		FROM audit_events 
+		WHERE actor_id = $1 OR subject_id = $1 
+		ORDER BY id`,
+}
+
+// PrepareStatements is the pool AfterConnect hook, see postgresUsersRepo.PrepareStatements.
+func PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package postgresGroupsRepo
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"log/slog"
+)
+
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// constraint names from the groups migration
+const membershipConstraint = "group_members_membership_fkey"
+
+// GroupRepository is scoped to the organization from ctx like the users one.
+type GroupRepository struct {
+	db *databases.Cluster
+}
+
+func New(db *databases.Cluster) *GroupRepository {
+	return &GroupRepository{db: db}
+}
+
+// Groups decide access, they are always read from the primary.
+func (r *GroupRepository) q(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Primary())
+}
+
+func orgID(ctx context.Context) (int, error) {
+	id, ok := domain.OrgID(ctx)
+	if !ok {
+		return 0, domain.ErrNoOrg
+	}
+	return id, nil
+}
+
+func (r *GroupRepository) Create(ctx context.Context, name string, perms []domain.Permission) (*domain.Group, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slog.DebugContext(ctx, "Creating group", "name", name)
+
+	g := &domain.Group{Name: name, Permissions: perms, MemberIDs: []int{}}
+	err = r.q(ctx).QueryRow(ctx, createGroup, org, name, perms).Scan(&g.ID, &g.CreatedAt)
+	if err != nil {
+		if isCode(err, uniqueViolation) {
+			return nil, domain.ErrGroupExists
+		}
+		slog.ErrorContext(ctx, "Failed to create group", "error", err)
+		return nil, err
+	}
+
+	r.db.MarkWrite(ctx)
+	return g, nil
+}
+
+func (r *GroupRepository) List(ctx context.Context) ([]*domain.Group, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q(ctx).Query(ctx, listGroups, org)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list groups", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []*domain.Group{}
+	for rows.Next() {
+		var g domain.Group
+		if err := scanGroup(rows, &g); err != nil {
+			slog.ErrorContext(ctx, "Failed to list groups", "error", err)
+			return nil, err
+		}
+		groups = append(groups, &g)
+	}
+	return groups, rows.Err()
+}
+
+func (r *GroupRepository) GetByID(ctx context.Context, id int) (*domain.Group, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var g domain.Group
+	err = scanGroup(r.q(ctx).QueryRow(ctx, getGroup, org, id), &g)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrGroupNotFound
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get group", "error", err)
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *GroupRepository) Update(ctx context.Context, id int, name string, perms []domain.Permission) error {
+	org, err := orgID(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Updating group", "group_id", id)
+
+	tag, err := r.q(ctx).Exec(ctx, updateGroup, org, id, name, perms)
+	if err != nil {
+		if isCode(err, uniqueViolation) {
+			return domain.ErrGroupExists
+		}
+		slog.ErrorContext(ctx, "Failed to update group", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrGroupNotFound
+	}
+
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+func (r *GroupRepository) DeleteByID(ctx context.Context, id int) error {
+	org, err := orgID(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Deleting group", "group_id", id)
+
+	tag, err := r.q(ctx).Exec(ctx, deleteGroup, org, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete group", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrGroupNotFound
+	}
+
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+// AddMember is a no-op when the user is in the group already.
+func (r *GroupRepository) AddMember(ctx context.Context, groupID, userID int) error {
+	org, err := orgID(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Adding group member", "group_id", groupID, "user_id", userID)
+
+	_, err = r.q(ctx).Exec(ctx, addGroupMember, org, groupID, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			if pgErr.ConstraintName == membershipConstraint {
+				return domain.ErrUserNotFound
+			}
+			return domain.ErrGroupNotFound
+		}
+		slog.ErrorContext(ctx, "Failed to add group member", "error", err)
+		return err
+	}
+
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+func (r *GroupRepository) RemoveMember(ctx context.Context, groupID, userID int) error {
+	org, err := orgID(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Removing group member", "group_id", groupID, "user_id", userID)
+
+	tag, err := r.q(ctx).Exec(ctx, removeGroupMember, org, groupID, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to remove group member", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotInGroup
+	}
+
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+// UserPermissions returns user's role and the permissions of their groups.
+// It's ErrNotMember when the user is not in the organization.
+func (r *GroupRepository) UserPermissions(ctx context.Context, userID int) (domain.Role, []domain.Permission, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		role  domain.Role
+		perms []domain.Permission
+	)
+	err = r.q(ctx).QueryRow(ctx, userPermissions, org, userID).Scan(&role, &perms)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, domain.ErrNotMember
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user permissions", "error", err)
+		return "", nil, err
+	}
+	return role, perms, nil
+}
+
+func scanGroup(row pgx.Row, g *domain.Group) error {
+	return row.Scan(&g.ID, &g.Name, &g.Permissions, &g.CreatedAt, &g.MemberIDs)
+}
+
+func isCode(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
@@ -0,0 +1,61 @@
+package postgresGroupsRepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of statements prepared on every pool connection, queries use them instead of sql text.
+const (
+	createGroup       = "groups_create"
+	listGroups        = "groups_list"
+	getGroup          = "groups_get"
+	updateGroup       = "groups_update"
+	deleteGroup       = "groups_delete"
+	addGroupMember    = "groups_add_member"
+	removeGroupMember = "groups_remove_member"
+	userPermissions   = "groups_user_permissions"
+)
+
+const groupColumns = `g.id, g.name, g.permissions, g.created_at, 
+	array(SELECT gm.user_id FROM group_members gm WHERE gm.group_id = g.id ORDER BY gm.user_id)`
+
+var statements = map[string]string{
+	createGroup: `INSERT INTO groups (org_id, name, permissions) VALUES ($1, $2, $3) 
+		RETURNING id, created_at`,
+	listGroups: `SELECT ` + groupColumns + ` 
+		FROM groups g 
+		WHERE g.org_id = $1 
+		ORDER BY g.name, g.id`,
+	getGroup: `SELECT ` + groupColumns + ` 
+		FROM groups g 
+		WHERE g.org_id = $1 AND g.id = $2`,
+	updateGroup: `UPDATE groups SET name = $3, permissions = $4 
+		WHERE org_id = $1 AND id = $2`,
+	deleteGroup: `DELETE FROM groups WHERE org_id = $1 AND id = $2`,
+	// foreign keys check that the group is in the organization and the user is its member
+	addGroupMember: `INSERT INTO group_members (org_id, group_id, user_id) VALUES ($1, $2, $3) 
+		ON CONFLICT (group_id, user_id) DO NOTHING`,
+	removeGroupMember: `DELETE FROM group_members 
+		WHERE org_id = $1 AND group_id = $2 AND user_id = $3`,
+	// no row means the user is not a member of the organization
+	userPermissions: `SELECT m.role, array(
+			SELECT DISTINCT p 
+			FROM group_members gm 
+			JOIN groups g ON g.id = gm.group_id, unnest(g.permissions) p 
+			WHERE gm.org_id = m.org_id AND gm.user_id = m.user_id 
+			ORDER BY p) 
+		FROM memberships m 
+		WHERE m.org_id = $1 AND m.user_id = $2`,
+}
+
+// PrepareStatements is the pool AfterConnect hook, see postgresUsersRepo.PrepareStatements.
+func PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package postgresImportsRepo
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+	"time"
+)
+
+// ImportJobRepository keeps background imports. Jobs are written with the organization
+// they carry, they run after the request is gone, reads are scoped to the organization from ctx.
+type ImportJobRepository struct {
+	db *databases.Cluster
+}
+
+func New(db *databases.Cluster) *ImportJobRepository {
+	return &ImportJobRepository{db: db}
+}
+
+func (r *ImportJobRepository) q(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Primary())
+}
+
+func (r *ImportJobRepository) Create(ctx context.Context, job *domain.ImportJob) error {
+	_, err := r.q(ctx).Exec(ctx, createJob, job.ID, job.OrgID, job.Status, report(job), job.StartedAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create import job", "job_id", job.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Update stores progress, status and the end of the job.
+func (r *ImportJobRepository) Update(ctx context.Context, job *domain.ImportJob) error {
+	var finishedAt *time.Time
+	if !job.FinishedAt.IsZero() {
+		finishedAt = &job.FinishedAt
+	}
+	_, err := r.q(ctx).Exec(ctx, updateJob, job.ID, job.Status, report(job), job.Error, finishedAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update import job", "job_id", job.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *ImportJobRepository) Get(ctx context.Context, id string) (*domain.ImportJob, error) {
+	org, ok := domain.OrgID(ctx)
+	if !ok {
+		return nil, domain.ErrNoOrg
+	}
+	job := &domain.ImportJob{}
+	var finishedAt *time.Time
+	err := r.q(ctx).QueryRow(ctx, getJob, id, org).
+		Scan(&job.ID, &job.OrgID, &job.Status, &job.Report, &job.Error, &job.StartedAt, &finishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrImportJobNotFound
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get import job", "job_id", id, "error", err)
+		return nil, err
+	}
+	if finishedAt != nil {
+		job.FinishedAt = *finishedAt
+	}
+	return job, nil
+}
+
+// DeleteStale drops jobs not updated since before, finished ones and ones whose instance is gone.
+func (r *ImportJobRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	tag, err := r.q(ctx).Exec(ctx, deleteStaleJob, before)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete stale import jobs", "error", err)
+		return err
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		slog.DebugContext(ctx, "Stale import jobs deleted", "count", n)
+	}
+	return nil
+}
+
+func report(job *domain.ImportJob) *domain.ImportReport {
+	if job.Report == nil {
+		return &domain.ImportReport{}
+	}
+	return job.Report
+}
@@ -0,0 +1,38 @@
+package postgresImportsRepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of statements prepared on every pool connection, queries use them instead of sql text.
+const (
+	createJob      = "imports_create"
+	updateJob      = "imports_update"
+	getJob         = "imports_get"
+	deleteStaleJob = "imports_delete_stale"
+)
+
+var statements = map[string]string{
+	createJob: `INSERT INTO import_jobs (id, org_id, status, report, started_at) 
+		VALUES ($1, $2, $3, $4, $5)`,
+	updateJob: `UPDATE import_jobs SET status = $2, report = $3, error = $4, finished_at = $5, updated_at = now() 
+		WHERE id = $1`,
+	getJob: `SELECT id, org_id, status, report, error, started_at, finished_at 
+		FROM import_jobs 
+		WHERE id = $1 AND org_id = $2`,
+	// finished ones and running ones nobody works on anymore
+	deleteStaleJob: `DELETE FROM import_jobs 
+		WHERE updated_at < $1`,
+}
+
+// PrepareStatements is the pool AfterConnect hook, see postgresUsersRepo.PrepareStatements.
+func PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package postgresInvitationsRepo
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+)
+
+// InvitationRepository is scoped to the organization from ctx, except for
+// the accept path: the one accepting is not signed in, the token names the invitation.
+type InvitationRepository struct {
+	db *databases.Cluster
+}
+
+func New(db *databases.Cluster) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+func (r *InvitationRepository) q(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Primary())
+}
+
+func orgID(ctx context.Context) (int, error) {
+	id, ok := domain.OrgID(ctx)
+	if !ok {
+		return 0, domain.ErrNoOrg
+	}
+	return id, nil
+}
+
+// Create stores inv in the organization from ctx and drops its expired invitations on the way.
+func (r *InvitationRepository) Create(ctx context.Context, inv *domain.Invitation) error {
+	org, err := orgID(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Creating invitation", "invitation_id", inv.ID, "role", inv.Role)
+
+	if _, err := r.q(ctx).Exec(ctx, deleteExpiredInvs, org); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete expired invitations", "error", err)
+		return err
+	}
+	var invitedBy *int
+	if inv.InvitedBy != 0 {
+		invitedBy = &inv.InvitedBy
+	}
+	err = r.q(ctx).QueryRow(ctx, createInvitation, inv.ID, org, inv.Email, inv.Role, invitedBy, inv.ExpiresAt).
+		Scan(&inv.CreatedAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create invitation", "error", err)
+		return err
+	}
+
+	inv.OrgID = org
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+// ListPending returns invitations that can still be accepted, the newest first.
+func (r *InvitationRepository) ListPending(ctx context.Context) ([]*domain.Invitation, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q(ctx).Query(ctx, listPending, org)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list invitations", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*domain.Invitation{}
+	for rows.Next() {
+		var inv domain.Invitation
+		if err := scanInvitation(rows, &inv); err != nil {
+			slog.ErrorContext(ctx, "Failed to list invitations", "error", err)
+			return nil, err
+		}
+		invitations = append(invitations, &inv)
+	}
+	return invitations, rows.Err()
+}
+
+// DeleteByID revokes a pending invitation.
+func (r *InvitationRepository) DeleteByID(ctx context.Context, id string) error {
+	org, err := orgID(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Deleting invitation", "invitation_id", id)
+
+	tag, err := r.q(ctx).Exec(ctx, deleteInvitation, org, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete invitation", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrInvitationNotFound
+	}
+
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+// LockPending returns a not yet accepted invitation, expired or not, and keeps it
+// locked till the end of the transaction so it is accepted once.
+func (r *InvitationRepository) LockPending(ctx context.Context, id string) (*domain.Invitation, error) {
+	var inv domain.Invitation
+	err := scanInvitation(r.q(ctx).QueryRow(ctx, lockPending, id), &inv)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrInvitationNotFound
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get invitation", "error", err)
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *InvitationRepository) MarkAccepted(ctx context.Context, id string) error {
+	if _, err := r.q(ctx).Exec(ctx, markAccepted, id); err != nil {
+		slog.ErrorContext(ctx, "Failed to mark invitation accepted", "error", err)
+		return err
+	}
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+func scanInvitation(row pgx.Row, inv *domain.Invitation) error {
+	return row.Scan(&inv.ID, &inv.OrgID, &inv.OrgName, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt)
+}
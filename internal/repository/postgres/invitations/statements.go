@@ -0,0 +1,48 @@
+package postgresInvitationsRepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of statements prepared on every pool connection, queries use them instead of sql text.
+const (
+	createInvitation  = "invitations_create"
+	listPending       = "invitations_list_pending"
+	deleteInvitation  = "invitations_delete"
+	lockPending       = "invitations_lock_pending"
+	markAccepted      = "invitations_mark_accepted"
+	deleteExpiredInvs = "invitations_delete_expired"
+)
+
+const invitationColumns = `i.id, i.org_id, o.name, i.email, i.role, coalesce(i.invited_by, 0), i.expires_at, i.created_at`
+
+var statements = map[string]string{
+	createInvitation: `INSERT INTO invitations (id, org_id, email, role, invited_by, expires_at) 
+		VALUES ($1, $2, $3, $4, $5, $6) 
+		RETURNING created_at`,
+	listPending: `SELECT ` + invitationColumns + ` 
+		FROM invitations i JOIN organizations o ON o.id = i.org_id 
+		WHERE i.org_id = $1 AND i.accepted_at IS NULL AND i.expires_at > now() 
+		ORDER BY i.created_at DESC`,
+	deleteInvitation: `DELETE FROM invitations 
+		WHERE org_id = $1 AND id = $2 AND accepted_at IS NULL`,
+	// expired ones are returned too, the service tells them apart
+	lockPending: `SELECT ` + invitationColumns + ` 
+		FROM invitations i JOIN organizations o ON o.id = i.org_id 
+		WHERE i.id = $1 AND i.accepted_at IS NULL 
+		FOR UPDATE OF i`,
+	markAccepted:      `UPDATE invitations SET accepted_at = now() WHERE id = $1`,
+	deleteExpiredInvs: `DELETE FROM invitations WHERE org_id = $1 AND accepted_at IS NULL AND expires_at <= now()`,
+}
+
+// PrepareStatements is the pool AfterConnect hook, see postgresUsersRepo.PrepareStatements.
+func PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
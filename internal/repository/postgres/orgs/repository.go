@@ -0,0 +1,151 @@
+package postgresOrgsRepo
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"log/slog"
+)
+
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const foreignKeyViolation = "23503"
+
+// OrgRepository takes organization ids explicitly, memberships are how the
+// caller's access to an organization is checked, so they are not scoped to one.
+type OrgRepository struct {
+	db *databases.Cluster
+}
+
+func New(db *databases.Cluster) *OrgRepository {
+	return &OrgRepository{db: db}
+}
+
+// Memberships decide access, they are always read from the primary.
+func (r *OrgRepository) q(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Primary())
+}
+
+func (r *OrgRepository) Create(ctx context.Context, name string) (*domain.Organization, error) {
+	slog.DebugContext(ctx, "Creating organization", "name", name)
+	org := &domain.Organization{Name: name}
+	err := r.q(ctx).QueryRow(ctx, createOrg, name).Scan(&org.ID, &org.CreatedAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create organization", "error", err)
+		return nil, err
+	}
+
+	r.db.MarkWrite(ctx)
+	slog.DebugContext(ctx, "Organization created", "org_id", org.ID)
+	return org, nil
+}
+
+func (r *OrgRepository) AddMember(ctx context.Context, orgID, userID int, role domain.Role) (*domain.Membership, error) {
+	slog.DebugContext(ctx, "Adding member", "org_id", orgID, "user_id", userID, "role", role)
+	m := &domain.Membership{Org: domain.Organization{ID: orgID}, UserID: userID, Role: role}
+	err := r.q(ctx).QueryRow(ctx, addMember, orgID, userID, role).Scan(&m.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r.GetMembership(ctx, orgID, userID) // already a member, role is kept
+	}
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			return nil, domain.ErrOrgNotFound
+		}
+		slog.ErrorContext(ctx, "Failed to add member", "error", err)
+		return nil, err
+	}
+
+	r.db.MarkWrite(ctx)
+	return m, nil
+}
+
+// ListByUser returns user's memberships, the oldest first.
+func (r *OrgRepository) ListByUser(ctx context.Context, userID int) ([]*domain.Membership, error) {
+	rows, err := r.q(ctx).Query(ctx, listUserOrgs, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list organizations", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*domain.Membership
+	for rows.Next() {
+		var m domain.Membership
+		if err := scanMembership(rows, &m); err != nil {
+			slog.ErrorContext(ctx, "Failed to list organizations", "error", err)
+			return nil, err
+		}
+		memberships = append(memberships, &m)
+	}
+	return memberships, rows.Err()
+}
+
+func (r *OrgRepository) GetMembership(ctx context.Context, orgID, userID int) (*domain.Membership, error) {
+	var m domain.Membership
+	err := scanMembership(r.q(ctx).QueryRow(ctx, getMembership, orgID, userID), &m)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotMember
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get membership", "error", err)
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *OrgRepository) SetRole(ctx context.Context, orgID, userID int, role domain.Role) error {
+	slog.DebugContext(ctx, "Setting member role", "org_id", orgID, "user_id", userID, "role", role)
+	tag, err := r.q(ctx).Exec(ctx, setRole, orgID, userID, role)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to set member role", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotMember
+	}
+
+	r.db.MarkWrite(ctx)
+	return nil
+}
+
+// LockOwners returns owner ids and keeps their memberships locked, run it in a transaction.
+func (r *OrgRepository) LockOwners(ctx context.Context, orgID int) ([]int, error) {
+	rows, err := r.q(ctx).Query(ctx, lockOwners, orgID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to lock owners", "error", err)
+		return nil, err
+	}
+	owners, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to lock owners", "error", err)
+		return nil, err
+	}
+	return owners, nil
+}
+
+func (r *OrgRepository) CountMembers(ctx context.Context, orgID int) (int, error) {
+	var count int
+	if err := r.q(ctx).QueryRow(ctx, countMembers, orgID).Scan(&count); err != nil {
+		slog.ErrorContext(ctx, "Failed to count members", "error", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountUserOrgs counts all organizations of the user, also ones the caller is not in.
+func (r *OrgRepository) CountUserOrgs(ctx context.Context, userID int) (int, error) {
+	var count int
+	if err := r.q(ctx).QueryRow(ctx, countUserOrgs, userID).Scan(&count); err != nil {
+		slog.ErrorContext(ctx, "Failed to count user organizations", "error", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func scanMembership(row pgx.Row, m *domain.Membership) error {
+	return row.Scan(&m.Org.ID, &m.Org.Name, &m.Org.CreatedAt, &m.UserID, &m.Role, &m.CreatedAt)
+}
@@ -0,0 +1,57 @@
+package postgresOrgsRepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of statements prepared on every pool connection, queries use them instead of sql text.
+const (
+	createOrg     = "orgs_create"
+	addMember     = "orgs_add_member"
+	listUserOrgs  = "orgs_list_by_user"
+	getMembership = "orgs_get_membership"
+	setRole       = "orgs_set_role"
+	lockOwners    = "orgs_lock_owners"
+	countMembers  = "orgs_count_members"
+	countUserOrgs = "orgs_count_user_orgs"
+)
+
+const membershipColumns = `o.id, o.name, o.created_at, m.user_id, m.role, m.created_at`
+
+var statements = map[string]string{
+	createOrg: `INSERT INTO organizations (name) VALUES ($1) 
+		RETURNING id, created_at`,
+	// no row back means the user is a member already
+	addMember: `INSERT INTO memberships (org_id, user_id, role) VALUES ($1, $2, $3) 
+		ON CONFLICT (org_id, user_id) DO NOTHING 
+		RETURNING created_at`,
+	listUserOrgs: `SELECT ` + membershipColumns + ` 
+		FROM memberships m JOIN organizations o ON o.id = m.org_id 
+		WHERE m.user_id = $1 
+		ORDER BY m.created_at, o.id`,
+	getMembership: `SELECT ` + membershipColumns + ` 
+		FROM memberships m JOIN organizations o ON o.id = m.org_id 
+		WHERE m.org_id = $1 AND m.user_id = $2`,
+	setRole: `UPDATE memberships SET role = $3 
+		WHERE org_id = $1 AND user_id = $2`,
+	// rows stay locked till the end of the transaction, so two owners can't demote each other at once
+	lockOwners: `SELECT user_id FROM memberships 
+		WHERE org_id = $1 AND role = 'owner' 
+		ORDER BY user_id 
+		FOR UPDATE`,
+	countMembers: `SELECT count(*) FROM memberships WHERE org_id = $1`,
+	// memberships of other organizations are hidden by row level security, the function sees them
+	countUserOrgs: `SELECT app_membership_count($1)`,
+}
+
+// PrepareStatements is the pool AfterConnect hook, see postgresUsersRepo.PrepareStatements.
+func PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package postgresSessionsRepo
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/databases"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+	"time"
+)
+
+// SessionRepository belongs to users, not organizations, queries are not scoped.
+// Sessions decide access, they are always read from the primary.
+type SessionRepository struct {
+	db *databases.Cluster
+}
+
+func New(db *databases.Cluster) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) q(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Primary())
+}
+
+// Create stores s and drops user's sessions that are over.
+func (r *SessionRepository) Create(ctx context.Context, s *domain.Session, refreshHash []byte) error {
+	slog.DebugContext(ctx, "Creating session", "user_id", s.UserID)
+	if _, err := r.q(ctx).Exec(ctx, deleteStale, s.UserID); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete stale sessions", "error", err)
+		return err
+	}
+	err := r.q(ctx).QueryRow(ctx, createSession, s.ID, s.UserID, s.OrgID, refreshHash, s.UserAgent, s.IP, s.ExpiresAt).
+		Scan(&s.CreatedAt, &s.LastSeenAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create session", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListActive returns user's live sessions, the last used first.
+func (r *SessionRepository) ListActive(ctx context.Context, userID int) ([]*domain.Session, error) {
+	rows, err := r.q(ctx).Query(ctx, listActive, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list sessions", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*domain.Session{}
+	for rows.Next() {
+		var s domain.Session
+		err := rows.Scan(&s.ID, &s.UserID, &s.OrgID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list sessions", "error", err)
+			return nil, err
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, rows.Err()
+}
+
+// Touch marks the session used, it's ErrSessionRevoked when the session is over.
+func (r *SessionRepository) Touch(ctx context.Context, id, ip string) error {
+	tag, err := r.q(ctx).Exec(ctx, touchSession, id, ip)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to touch session", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrSessionRevoked
+	}
+	return nil
+}
+
+// Revoke ends a live session of the user.
+func (r *SessionRepository) Revoke(ctx context.Context, userID int, id string) error {
+	slog.DebugContext(ctx, "Revoking session", "session_id", id)
+	tag, err := r.q(ctx).Exec(ctx, revokeSession, id, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke session", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeReused ends a session whose refresh secret hash was rotated away already,
+// revoked is false when hash is not one of them or the session was over already.
+func (r *SessionRepository) RevokeReused(ctx context.Context, id string, hash []byte) (revoked bool, err error) {
+	tag, err := r.q(ctx).Exec(ctx, revokeReused, id, hash)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke session", "error", err)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Rotate replaces the refresh secret hash and extends the session. It's
+// ErrInvalidRefreshToken when oldHash is not the current one or the session is over.
+func (r *SessionRepository) Rotate(ctx context.Context, id string, oldHash, newHash []byte, expires time.Time, client domain.Client) (*domain.Session, string, error) {
+	s := &domain.Session{ID: id, ExpiresAt: expires}
+	var email string
+	err := r.q(ctx).QueryRow(ctx, rotateSession, id, oldHash, newHash, expires, client.IP, client.UserAgent).
+		Scan(&s.UserID, &s.OrgID, &email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", domain.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to rotate session", "error", err)
+		return nil, "", err
+	}
+	return s, email, nil
+}
+
+// SetOrg changes the organization tokens of the session are refreshed for.
+func (r *SessionRepository) SetOrg(ctx context.Context, id string, orgID int) error {
+	tag, err := r.q(ctx).Exec(ctx, setSessionOrg, id, orgID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to set session organization", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrSessionRevoked
+	}
+	return nil
+}
+
+// RevokeAll ends every live session of the user and returns their ids.
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID int) ([]string, error) {
+	rows, err := r.q(ctx).Query(ctx, revokeAll, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke sessions", "error", err)
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke sessions", "error", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ListByUser returns all stored sessions of the user, revoked and expired ones too.
+func (r *SessionRepository) ListByUser(ctx context.Context, userID int) ([]*domain.Session, error) {
+	rows, err := r.q(ctx).Query(ctx, listByUser, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list sessions", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*domain.Session{}
+	for rows.Next() {
+		var s domain.Session
+		var revokedAt *time.Time
+		err := rows.Scan(&s.ID, &s.UserID, &s.OrgID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &revokedAt)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list sessions", "error", err)
+			return nil, err
+		}
+		if revokedAt != nil {
+			s.RevokedAt = *revokedAt
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,72 @@
+package postgresSessionsRepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of statements prepared on every pool connection, queries use them instead of sql text.
+const (
+	createSession = "sessions_create"
+	deleteStale   = "sessions_delete_stale"
+	listActive    = "sessions_list_active"
+	touchSession  = "sessions_touch"
+	revokeSession = "sessions_revoke"
+	revokeReused  = "sessions_revoke_reused"
+	rotateSession = "sessions_rotate"
+	setSessionOrg = "sessions_set_org"
+	revokeAll     = "sessions_revoke_all"
+	listByUser    = "sessions_list_by_user"
+)
+
+const active = `revoked_at IS NULL AND expires_at > now()`
+
+// previousHashes is how many rotated away secrets are kept to detect reuse,
+// older ones are plain invalid tokens.
+const previousHashes = "16"
+
+var statements = map[string]string{
+	createSession: `INSERT INTO sessions (id, user_id, org_id, refresh_hash, user_agent, ip, expires_at) 
+		VALUES ($1, $2, nullif($3, 0), $4, $5, $6, $7) 
+		RETURNING created_at, last_seen_at`,
+	deleteStale: `DELETE FROM sessions 
+		WHERE user_id = $1 AND NOT (` + active + `)`,
+	listActive: `SELECT id, user_id, coalesce(org_id, 0), user_agent, ip, created_at, last_seen_at, expires_at 
+		FROM sessions 
+		WHERE user_id = $1 AND ` + active + ` 
+		ORDER BY last_seen_at DESC`,
+	touchSession: `UPDATE sessions SET last_seen_at = now(), ip = coalesce(nullif($2, ''), ip) 
+		WHERE id = $1 AND ` + active,
+	revokeSession: `UPDATE sessions SET revoked_at = now() 
+		WHERE id = $1 AND user_id = $2 AND ` + active,
+	revokeReused: `UPDATE sessions SET revoked_at = now() 
+		WHERE id = $1 AND $2 = ANY (previous_hashes) AND ` + active,
+	// the hash must match, an old refresh token does not rotate anything
+	rotateSession: `UPDATE sessions s SET refresh_hash = $3, expires_at = $4, last_seen_at = now(), 
+			previous_hashes = (array_prepend(s.refresh_hash, s.previous_hashes))[:` + previousHashes + `], 
+			ip = coalesce(nullif($5, ''), s.ip), user_agent = coalesce(nullif($6, ''), s.user_agent) 
+		FROM users u 
+		WHERE s.id = $1 AND s.refresh_hash = $2 AND s.revoked_at IS NULL AND s.expires_at > now() AND u.id = s.user_id 
+		RETURNING s.user_id, coalesce(s.org_id, 0), u.email`,
+	setSessionOrg: `UPDATE sessions SET org_id = nullif($2, 0) 
+		WHERE id = $1 AND ` + active,
+	revokeAll: `UPDATE sessions SET revoked_at = now() 
+		WHERE user_id = $1 AND ` + active + ` 
+		RETURNING id`,
+	// everything kept, for data export
+	listByUser: `SELECT id, user_id, coalesce(org_id, 0), user_agent, ip, created_at, last_seen_at, expires_at, revoked_at 
+		FROM sessions 
+		WHERE user_id = $1 
+		ORDER BY created_at`,
+}
+
+// PrepareStatements is the pool AfterConnect hook, see postgresUsersRepo.PrepareStatements.
+func PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// unscopedRole bypasses the row level security policies, see migration 000011
+const unscopedRole = "app_unscoped"
+
+// Scope keeps app.org_id and app.user_id of every pool connection equal to the organization
+// and the requester of the request that took it, row level security policies read them
+// (see migrations). Without them nothing is visible, operations marked domain.WithUnscoped
+// switch to the app_unscoped role instead.
+// Use BeforeAcquire and BeforeClose as pool hooks.
+type Scope struct {
+	mu      sync.Mutex
+	current map[*pgx.Conn]state // last values set, to skip the round trip when they are the same
+}
+
+type state struct {
+	role string
+	org  string
+	user string
+}
+
+func New() *Scope {
+	return &Scope{current: make(map[*pgx.Conn]state)}
+}
+
+func (s *Scope) BeforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	want := state{role: "none"}
+	if domain.Unscoped(ctx) {
+		want.role = unscopedRole
+	}
+	if id, ok := domain.OrgID(ctx); ok {
+		want.org = strconv.Itoa(id)
+	}
+	if id, ok := domain.RequesterID(ctx); ok {
+		want.user = strconv.Itoa(id)
+	}
+
+	s.mu.Lock()
+	current, known := s.current[conn]
+	s.mu.Unlock()
+	if known && current == want {
+		return true
+	}
+
+	// Session level, connections are taken outside of transactions so it can't be rolled back
+	if _, err := conn.Exec(ctx,
+		"SELECT set_config('role', $1, false), set_config('app.org_id', $2, false), set_config('app.user_id', $3, false)",
+		want.role, want.org, want.user,
+	); err != nil {
+		slog.ErrorContext(ctx, "Failed to set organization on connection", "error", err)
+		return false // pool closes it and takes another one
+	}
+
+	s.mu.Lock()
+	s.current[conn] = want
+	s.mu.Unlock()
+	return true
+}
+
+func (s *Scope) BeforeClose(conn *pgx.Conn) {
+	s.mu.Lock()
+	delete(s.current, conn)
+	s.mu.Unlock()
+}
@@ -0,0 +1,135 @@
+package transactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+
+	retryBaseDelay = 10 * time.Millisecond
+)
+
+// Querier is what repositories run queries on, both the pool and a tx implement it.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type txKey struct{}
+
+// Transactor runs functions in a transaction that repositories pick up from ctx.
+type Transactor struct {
+	pool       *pgxpool.Pool
+	isoLevel   pgx.TxIsoLevel
+	maxRetries int
+}
+
+var ErrUnknownIsoLevel = errors.New("unknown transaction isolation level")
+
+func New(pool *pgxpool.Pool, isoLevel string, maxRetries int) (*Transactor, error) {
+	switch level := pgx.TxIsoLevel(isoLevel); level {
+	case "", pgx.ReadCommitted, pgx.RepeatableRead, pgx.Serializable:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIsoLevel, isoLevel)
+	}
+	return &Transactor{
+		pool:       pool,
+		isoLevel:   pgx.TxIsoLevel(isoLevel),
+		maxRetries: maxRetries,
+	}, nil
+}
+
+// WithinTx commits when fn returns nil and rolls back otherwise.
+// Nested calls become savepoints, so inner failure rolls back only the inner part
+// when the outer fn handles the error. Serialization failures and deadlocks
+// restart the whole outermost transaction, fn must not have side effects outside db.
+func (t *Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return t.savepoint(ctx, tx, fn)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := t.run(ctx, fn)
+		if err == nil || !retryable(err) || attempt >= t.maxRetries {
+			return err
+		}
+
+		delay := retryBaseDelay<<attempt + rand.N(retryBaseDelay)
+		slog.WarnContext(ctx, "Retrying transaction", "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (t *Transactor) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := t.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: t.isoLevel})
+	if err != nil {
+		return err
+	}
+	defer rollback(ctx, tx)
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// savepoint: Begin on a pgx.Tx issues SAVEPOINT, Commit releases it.
+func (t *Transactor) savepoint(ctx context.Context, tx pgx.Tx, fn func(ctx context.Context) error) error {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollback(ctx, sp)
+
+	if err := fn(context.WithValue(ctx, txKey{}, sp)); err != nil {
+		return err
+	}
+	return sp.Commit(ctx)
+}
+
+// QuerierFrom returns tx from ctx if there is one and db otherwise.
+func QuerierFrom(ctx context.Context, db Querier) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// InTx tells whether ctx carries a transaction.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return ok
+}
+
+func rollback(ctx context.Context, tx pgx.Tx) {
+	// No-op after commit. Own ctx, canceled request must still roll back.
+	err := tx.Rollback(context.WithoutCancel(ctx))
+	if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		slog.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+	}
+}
+
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected
+}
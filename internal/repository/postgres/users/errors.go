@@ -0,0 +1,33 @@
+package postgresUsersRepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation = "23505"
+	queryCanceled   = "57014"
+)
+
+// mapError turns postgres errors into domain ones where we have one.
+// Statement canceled because of ctx is reported as ctx error, not as db failure.
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case uniqueViolation:
+			return domain.ErrUserAlreadyExists
+		case queryCanceled:
+			return fmt.Errorf("%w: %w", context.Canceled, err)
+		}
+	}
+	if pgconn.Timeout(err) {
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+	return err
+}
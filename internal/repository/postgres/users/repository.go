@@ -2,91 +2,212 @@ package postgresUsersRepo
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"github.com/Arh0rn/test-task1/internal/databases"
 	"github.com/Arh0rn/test-task1/internal/domain"
-	"github.com/lib/pq"
+	"github.com/Arh0rn/test-task1/internal/repository/postgres/transactor"
+	"github.com/jackc/pgx/v5"
 	"log/slog"
+	"strings"
+	"time"
 )
 
 // TODO: make soft delete
 
+// likeEscaper makes user input literal inside a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type UserRepository struct {
-	db *sql.DB
+	db *databases.Cluster
 }
 
-func New(db *sql.DB) *UserRepository {
+func New(db *databases.Cluster) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// q runs on the transaction from ctx if the caller opened one.
+func (r *UserRepository) q(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Primary())
+}
+
+// reader is q for reads, outside of a transaction they may go to a replica.
+func (r *UserRepository) reader(ctx context.Context) transactor.Querier {
+	return transactor.QuerierFrom(ctx, r.db.Reader(ctx))
+}
+
+// orgID is the organization from ctx, users of other organizations are never read or changed.
+func orgID(ctx context.Context) (int, error) {
+	id, ok := domain.OrgID(ctx)
+	if !ok {
+		return 0, domain.ErrNoOrg
+	}
+	return id, nil
+}
+
+// Create is not scoped, the caller adds the user to an organization in the same transaction.
 func (r *UserRepository) Create(ctx context.Context, user *domain.SignUpInput) (*domain.User, error) {
-	var id int
-	slog.DebugContext(ctx, "Creating user in DB", "user", user)
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO users (name, email, password) 
-		 VALUES ($1, $2, $3) 
-		 RETURNING id`,
-		user.Name, user.Email, user.Password,
-	).Scan(&id)
+	createdUser := &domain.User{
+		Name:     user.Name,
+		Email:    user.Email,
+		Password: user.Password,
+		Profile:  user.Profile,
+	}
+	slog.DebugContext(ctx, "Creating user in DB", "email", user.Email)
+	err := r.q(ctx).QueryRow(ctx, createUser,
+		withProfile(user.Profile, user.Name, user.Email, user.Password)...,
+	).Scan(&createdUser.ID, &createdUser.CreatedAt, &createdUser.UpdatedAt)
 
 	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		err = mapError(err)
+		if errors.Is(err, domain.ErrUserAlreadyExists) {
 			slog.ErrorContext(ctx, "User already exists")
-			return nil, domain.ErrUserAlreadyExists
+			return nil, err
 		}
 		slog.ErrorContext(ctx, "Failed to create user", "error", err)
 		return nil, err
 	}
-	createdUser := &domain.User{
-		ID:       id,
-		Name:     user.Name,
-		Email:    user.Email,
-		Password: user.Password,
-	}
 
-	slog.DebugContext(ctx, "User created", "user", createdUser)
+	r.db.MarkWrite(ctx)
+	slog.DebugContext(ctx, "User created", "id", createdUser.ID)
 	return createdUser, nil
 }
 
+// ImportBatch inserts all users in one round trip, skipping users whose email is already taken,
+// including by an earlier row of the same batch. Skipped users are nil in the result.
+// Imported users become members of the organization from ctx.
+func (r *UserRepository) ImportBatch(ctx context.Context, users []*domain.SignUpInput) ([]*domain.User, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slog.DebugContext(ctx, "Importing users to DB", "user_count", len(users))
+	batch := &pgx.Batch{}
+	for _, user := range users {
+		args := append(withProfile(user.Profile, user.Name, user.Email, user.Password), org)
+		batch.Queue(importUser, args...)
+	}
+
+	results := r.q(ctx).SendBatch(ctx, batch)
+	defer results.Close()
+
+	imported := make([]*domain.User, len(users))
+	for i, user := range users {
+		createdUser := &domain.User{
+			Name:     user.Name,
+			Email:    user.Email,
+			Password: user.Password,
+			Profile:  user.Profile,
+		}
+		err := results.QueryRow().Scan(&createdUser.ID, &createdUser.CreatedAt, &createdUser.UpdatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to import users", "email", user.Email, "error", err)
+			return nil, mapError(err)
+		}
+		imported[i] = createdUser
+	}
+
+	if err := results.Close(); err != nil {
+		slog.ErrorContext(ctx, "Failed to import users", "error", err)
+		return nil, mapError(err)
+	}
+
+	r.db.MarkWrite(ctx)
+	slog.DebugContext(ctx, "Users imported", "user_count", len(users))
+	return imported, nil
+}
+
+// Export streams all users ordered by ID to fn, without passwords. Stops on first fn error.
+func (r *UserRepository) Export(ctx context.Context, fn func(*domain.User) error) error {
+	org, err := orgID(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Exporting users")
+	rows, err := r.reader(ctx).Query(ctx, exportUsers, org)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to export users", "error", err)
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var user domain.User
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt,
+			&user.DisplayName, &user.AvatarURL, &user.Locale, &user.Timezone, &user.Phone, &user.Metadata)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to export users", "error", err)
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to export users", "error", err)
+		return mapError(err)
+	}
+
+	slog.DebugContext(ctx, "Users exported", "user_count", count)
+	return nil
+}
+
+// GetByEmail is for login, so it is not scoped. It reads the primary, a lagging replica
+// would still accept a password that was just changed.
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
 	slog.DebugContext(ctx, "Getting user by email", "email", email)
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, name, email, password 
-		 FROM users 
-		 WHERE email = $1`,
-		email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Password)
+	err := scanUser(r.q(ctx).QueryRow(ctx, getUserByEmail, email), &user)
 
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, pgx.ErrNoRows) {
 			slog.ErrorContext(ctx, "User not found", "email", email)
 			return nil, domain.ErrUserNotFound
 
 		}
 		slog.ErrorContext(ctx, "Failed to get user by email", "error", err)
-		return nil, err
+		return nil, mapError(err)
 	}
 	slog.DebugContext(ctx, "User found", "user", user)
 	return &user, nil
 }
 
-func (r *UserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
-	slog.DebugContext(ctx, "Getting all users")
-	rows, err := r.db.QueryContext(ctx,
-		"SELECT id, name, email, password FROM users")
+func (r *UserRepository) GetAll(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, error) {
+	org, err := orgID(ctx)
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed to get all users", "error", err)
 		return nil, err
 	}
+	slog.DebugContext(ctx, "Getting all users", "limit", query.Limit, "offset", query.Offset)
+
+	batch := &pgx.Batch{}
+	batch.Queue(countUsers, org)
+	batch.Queue(getAllUsers, query.Limit, query.Offset, org)
+	results := r.reader(ctx).SendBatch(ctx, batch)
+	defer results.Close()
+
+	var total int
+	if err := results.QueryRow().Scan(&total); err != nil {
+		slog.ErrorContext(ctx, "Failed to count users", "error", err)
+		return nil, mapError(err)
+	}
+
+	rows, err := results.Query()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get all users", "error", err)
+		return nil, mapError(err)
+	}
 	defer rows.Close()
 
-	var users []*domain.User
+	users := make([]*domain.User, 0, query.Limit)
 
 	for rows.Next() {
 		var user domain.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password); err != nil {
+		if err := scanUser(rows, &user); err != nil {
 			slog.ErrorContext(ctx, "Failed to get all users", "error", err)
 			return nil, err
 		}
@@ -94,84 +215,252 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
 	}
 
 	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	slog.DebugContext(ctx, "All users retrieved", "user_count", len(users), "total", total)
+	return &domain.UserList{Users: users, Total: total}, nil
+}
+
+// Search finds users by partial or misspelled name or email, best matches first.
+func (r *UserRepository) Search(ctx context.Context, query *domain.UserSearchQuery) (*domain.UserSearchResult, error) {
+	org, err := orgID(ctx)
+	if err != nil {
 		return nil, err
 	}
+	slog.DebugContext(ctx, "Searching users", "query", query.Query, "limit", query.Limit, "offset", query.Offset)
+	q := strings.ToLower(query.Query)
+	pattern := "%" + likeEscaper.Replace(q) + "%"
+
+	batch := &pgx.Batch{}
+	batch.Queue(countSearch, q, pattern, org)
+	batch.Queue(searchUsers, q, pattern, query.Limit, query.Offset, org)
+	results := r.reader(ctx).SendBatch(ctx, batch)
+	defer results.Close()
+
+	var total int
+	if err := results.QueryRow().Scan(&total); err != nil {
+		slog.ErrorContext(ctx, "Failed to count found users", "error", err)
+		return nil, mapError(err)
+	}
+
+	rows, err := results.Query()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to search users", "error", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	hits := make([]*domain.UserSearchHit, 0, query.Limit)
+	for rows.Next() {
+		var user domain.User
+		hit := &domain.UserSearchHit{User: &user}
+		if err := scanUser(rows, &user, &hit.Rank); err != nil {
+			slog.ErrorContext(ctx, "Failed to search users", "error", err)
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
 
-	slog.DebugContext(ctx, "All users retrieved", "users", users)
-	return users, nil
+	slog.DebugContext(ctx, "Users found", "user_count", len(hits), "total", total)
+	return &domain.UserSearchResult{Hits: hits, Total: total}, nil
 }
 
+// GetByID also fills OrgIDs.
 func (r *UserRepository) GetByID(ctx context.Context, id int) (*domain.User, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return nil, err
+	}
 	slog.DebugContext(ctx, "Getting user by ID", "id", id)
 	var user domain.User
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, name, email, password 
-		 FROM users 
-		 WHERE id = $1`,
-		id,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Password)
+	err = scanUser(r.reader(ctx).QueryRow(ctx, getUserByID, id, org), &user, &user.OrgIDs)
 
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, pgx.ErrNoRows) {
 			slog.ErrorContext(ctx, "User not found", "id", id)
 			return nil, domain.ErrUserNotFound
 		}
 		slog.ErrorContext(ctx, "Failed to get user by ID", "error", err)
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	slog.DebugContext(ctx, "User found", "user", user)
 	return &user, nil
 }
 
-func (r *UserRepository) DeleteByID(ctx context.Context, id int) error {
+// DeleteByID removes the user from the organization, the user itself is deleted
+// with the last membership and deleted reports that. Run it in a transaction.
+func (r *UserRepository) DeleteByID(ctx context.Context, id int) (deleted bool, err error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return false, err
+	}
 	slog.DebugContext(ctx, "Deleting user by ID", "id", id)
-	result, err := r.db.ExecContext(ctx,
-		`DELETE FROM users WHERE id = $1`,
-		id,
-	)
+	tag, err := r.q(ctx).Exec(ctx, removeMember, org, id)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to delete user", "error", err)
-		return err
+		return false, mapError(err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if tag.RowsAffected() == 0 {
+		slog.ErrorContext(ctx, "User does not exist", "id", id)
+		return false, domain.ErrUserNotFound
+	}
+
+	tag, err = r.q(ctx).Exec(ctx, deleteOrphan, id)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to delete user", "error", err)
+		return false, mapError(err)
+	}
+
+	r.db.MarkWrite(ctx)
+	deleted = tag.RowsAffected() == 1
+	slog.DebugContext(ctx, "User deleted", "id", id, "user_deleted", deleted)
+	return deleted, nil
+}
+
+func (r *UserRepository) UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) (*domain.UserUpdate, error) {
+	org, err := orgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slog.DebugContext(ctx, "Updating user by ID", "id", id)
+	args := append(withProfile(user.Profile, user.Name, user.Email), id, org)
+	err = r.q(ctx).QueryRow(ctx, updateUserByID, args...).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			slog.ErrorContext(ctx, "User does not exist", "id", id)
+			return nil, domain.ErrUserNotFound
+		}
+		err = mapError(err)
+		if errors.Is(err, domain.ErrUserAlreadyExists) {
+			slog.ErrorContext(ctx, "Email is taken by another user", "id", id)
+			return nil, err
+		}
+		slog.ErrorContext(ctx, "Failed to update user", "error", err)
+		return nil, err
+	}
+
+	r.db.MarkWrite(ctx)
+	slog.DebugContext(ctx, "User updated", "id", id)
+	return user, nil
+}
+
+func (r *UserRepository) UpdateAvatarURL(ctx context.Context, id int, url string) error {
+	org, err := orgID(ctx)
+	if err != nil {
 		return err
 	}
+	slog.DebugContext(ctx, "Updating user avatar", "id", id)
+	tag, err := r.q(ctx).Exec(ctx, updateAvatar, url, id, org)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update user avatar", "error", err)
+		return mapError(err)
+	}
 
-	if rowsAffected == 0 {
+	if tag.RowsAffected() == 0 {
 		slog.ErrorContext(ctx, "User does not exist", "id", id)
 		return domain.ErrUserNotFound
 	}
 
-	slog.DebugContext(ctx, "User deleted", "id", id)
+	r.db.MarkWrite(ctx)
+	slog.DebugContext(ctx, "User avatar updated", "id", id)
 	return nil
 }
 
-func (r *UserRepository) UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) (*domain.UserUpdate, error) {
-	slog.DebugContext(ctx, "Updating user by ID", "id", id)
-	result, err := r.db.ExecContext(ctx,
-		`UPDATE users SET name = $1, email = $2 WHERE id = $3`,
-		user.Name, user.Email, id,
+// scanUser reads userColumns, extra destinations are for columns after them.
+func scanUser(row pgx.Row, user *domain.User, extra ...any) error {
+	dest := append([]any{
+		&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt,
+		&user.DisplayName, &user.AvatarURL, &user.Locale, &user.Timezone, &user.Phone, &user.Metadata,
+	}, extra...)
+	return row.Scan(dest...)
+}
+
+// withProfile appends profile columns to args, in the order statements expect them.
+func withProfile(profile domain.Profile, args ...any) []any {
+	metadata := profile.Metadata
+	if metadata == nil {
+		metadata = map[string]any{} // column is NOT NULL
+	}
+	return append(args,
+		profile.DisplayName, profile.AvatarURL, profile.Locale, profile.Timezone, profile.Phone, metadata,
 	)
+}
+
+// GetAccount is not scoped, the user is the caller. Password is included.
+func (r *UserRepository) GetAccount(ctx context.Context, id int) (*domain.Account, error) {
+	var account domain.Account
+	var deleteAfter *time.Time
+	err := scanUser(r.q(ctx).QueryRow(ctx, getAccount, id), &account.User, &deleteAfter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed to update user", "error", err)
-		return nil, err
+		slog.ErrorContext(ctx, "Failed to get account", "error", err)
+		return nil, mapError(err)
+	}
+	if deleteAfter != nil {
+		account.DeleteAfter = *deleteAfter
 	}
+	return &account, nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// ScheduleDeletion marks the account to be purged after at.
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id int, at time.Time) error {
+	tag, err := r.q(ctx).Exec(ctx, scheduleDelete, id, at)
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed to update user", "error", err)
-		return nil, err
+		slog.ErrorContext(ctx, "Failed to schedule account deletion", "error", err)
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
 	}
+	r.db.MarkWrite(ctx)
+	return nil
+}
 
-	if rowsAffected == 0 {
-		slog.ErrorContext(ctx, "User does not exist", "id", id)
-		return nil, domain.ErrUserNotFound
+// CancelDeletion is false when no deletion was scheduled.
+func (r *UserRepository) CancelDeletion(ctx context.Context, id int) (bool, error) {
+	tag, err := r.q(ctx).Exec(ctx, cancelDelete, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to cancel account deletion", "error", err)
+		return false, mapError(err)
 	}
+	return tag.RowsAffected() > 0, nil
+}
 
-	slog.DebugContext(ctx, "User updated", "user", user)
-	return user, nil
+// LockDueDeletion returns an account whose deletion is due, locked till the end of
+// the transaction. It's ErrUserNotFound when there is none.
+func (r *UserRepository) LockDueDeletion(ctx context.Context) (int, error) {
+	var id int
+	err := r.q(ctx).QueryRow(ctx, lockDueDelete).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, domain.ErrUserNotFound
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to lock account for deletion", "error", err)
+		return 0, mapError(err)
+	}
+	return id, nil
+}
+
+// Purge deletes the account with all its memberships and sessions, audit events
+// keep the event without the user (see migrations).
+func (r *UserRepository) Purge(ctx context.Context, id int) error {
+	tag, err := r.q(ctx).Exec(ctx, purgeUser, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to purge account", "error", err)
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	r.db.MarkWrite(ctx)
+	return nil
 }
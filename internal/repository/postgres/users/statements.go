@@ -1,10 +1,125 @@
 package postgresUsersRepo
 
-//TODO: make statements if needed
-
-//const (
-//	createUser     = `INSERT INTO users (name, email, ) VALUES ($1, $2)`
-//	getAllUsers    = `SELECT id, name, email FROM users` //No reason to take password
-//	getUserByID    = `SELECT id, name, email, password FROM users WHERE id = $1`
-//	deleteUserByID = `DELETE FROM users WHERE id = $1`
-//)
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of statements prepared on every pool connection, queries use them instead of sql text.
+const (
+	createUser     = "users_create"
+	getUserByEmail = "users_get_by_email"
+	getUserByID    = "users_get_by_id"
+	getAllUsers    = "users_get_all"
+	countUsers     = "users_count"
+	updateUserByID = "users_update_by_id"
+	updateAvatar   = "users_update_avatar_url"
+	removeMember   = "users_remove_member"
+	deleteOrphan   = "users_delete_orphan"
+	importUser     = "users_import"
+	exportUsers    = "users_export"
+	searchUsers    = "users_search"
+	countSearch    = "users_search_count"
+	getAccount     = "users_get_account"
+	scheduleDelete = "users_schedule_delete"
+	cancelDelete   = "users_cancel_delete"
+	lockDueDelete  = "users_lock_due_delete"
+	purgeUser      = "users_purge"
+)
+
+// userColumns are scanned by scanUser, exportColumns the same without password.
+const (
+	userColumns = `id, name, email, password, created_at, updated_at, 
+		display_name, avatar_url, locale, timezone, phone, metadata`
+	exportColumns = `id, name, email, created_at, updated_at, 
+		display_name, avatar_url, locale, timezone, phone, metadata`
+)
+
+// inOrg is the tenant filter, every statement on users of an organization has it.
+// RLS policy checks the same, see migrations.
+func inOrg(param int) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM memberships m WHERE m.user_id = users.id AND m.org_id = $%d)", param)
+}
+
+// $1 is lowercased query for fuzzy match, $2 the same as LIKE pattern.
+// `<%` is word similarity over pg_trgm.word_similarity_threshold (0.6 by default).
+const searchCondition = `($1 <% lower(name) OR $1 <% lower(email) 
+		OR lower(name) LIKE $2 OR lower(email) LIKE $2)`
+
+// Sign up and login are not scoped to an organization, the rest is.
+var statements = map[string]string{
+	createUser: `INSERT INTO users (name, email, password, 
+			display_name, avatar_url, locale, timezone, phone, metadata) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
+		RETURNING id, created_at, updated_at`,
+	getUserByEmail: `SELECT ` + userColumns + ` 
+		FROM users 
+		WHERE lower(email) = lower($1)`,
+	getUserByID: `SELECT ` + userColumns + `, 
+			array(SELECT org_id FROM memberships WHERE user_id = users.id ORDER BY org_id) 
+		FROM users 
+		WHERE id = $1 AND ` + inOrg(2),
+	getAllUsers: `SELECT ` + userColumns + ` 
+		FROM users 
+		WHERE ` + inOrg(3) + ` 
+		ORDER BY id 
+		LIMIT $1 OFFSET $2`,
+	countUsers: `SELECT count(*) FROM memberships WHERE org_id = $1`,
+	updateUserByID: `UPDATE users SET name = $1, email = $2, 
+			display_name = $3, avatar_url = $4, locale = $5, timezone = $6, phone = $7, metadata = $8 
+		WHERE id = $9 AND ` + inOrg(10) + ` 
+		RETURNING updated_at`,
+	updateAvatar: `UPDATE users SET avatar_url = $1 WHERE id = $2 AND ` + inOrg(3),
+	removeMember: `DELETE FROM memberships WHERE org_id = $1 AND user_id = $2`,
+	deleteOrphan: `DELETE FROM users 
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM memberships m WHERE m.user_id = users.id)`,
+	// no row back means the email is taken, new users join the organization as members
+	importUser: `WITH u AS (
+			INSERT INTO users (name, email, password, 
+				display_name, avatar_url, locale, timezone, phone, metadata) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
+			ON CONFLICT DO NOTHING 
+			RETURNING id, created_at, updated_at
+		), m AS (
+			INSERT INTO memberships (org_id, user_id) SELECT $10::integer, id FROM u
+		)
+		SELECT id, created_at, updated_at FROM u`,
+	exportUsers: `SELECT ` + exportColumns + ` 
+		FROM users 
+		WHERE ` + inOrg(1) + ` 
+		ORDER BY id`,
+	searchUsers: `SELECT ` + userColumns + `, 
+		(greatest(word_similarity($1, lower(name)), word_similarity($1, lower(email))) 
+			+ CASE WHEN lower(name) LIKE $2 OR lower(email) LIKE $2 THEN 1 ELSE 0 END)::float8 AS rank 
+		FROM users 
+		WHERE ` + searchCondition + ` AND ` + inOrg(5) + ` 
+		ORDER BY rank DESC, id 
+		LIMIT $3 OFFSET $4`,
+	countSearch: `SELECT count(*) FROM users WHERE ` + searchCondition + ` AND ` + inOrg(3),
+	// the account of the caller, whatever organization they are in
+	getAccount: `SELECT ` + userColumns + `, delete_after 
+		FROM users 
+		WHERE id = $1`,
+	scheduleDelete: `UPDATE users SET delete_after = $2 WHERE id = $1`,
+	cancelDelete: `UPDATE users SET delete_after = NULL 
+		WHERE id = $1 AND delete_after IS NOT NULL`,
+	// replicas purging at the same time take different users
+	lockDueDelete: `SELECT id FROM users 
+		WHERE delete_after <= now() 
+		ORDER BY delete_after 
+		LIMIT 1 
+		FOR UPDATE SKIP LOCKED`,
+	purgeUser: `DELETE FROM users WHERE id = $1 AND delete_after <= now()`,
+}
+
+// PrepareStatements is the pool AfterConnect hook. Schema must be migrated
+// before the pool opens connections, otherwise preparing fails.
+func PrepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
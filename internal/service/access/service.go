@@ -0,0 +1,283 @@
+package accessService
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"slices"
+)
+
+var errNoRequester = errors.New("no authenticated user in context")
+
+// GroupRepository is scoped to the organization from ctx.
+type GroupRepository interface {
+	Create(ctx context.Context, name string, perms []domain.Permission) (*domain.Group, error)
+	List(ctx context.Context) ([]*domain.Group, error)
+	GetByID(ctx context.Context, id int) (*domain.Group, error)
+	Update(ctx context.Context, id int, name string, perms []domain.Permission) error
+	DeleteByID(ctx context.Context, id int) error
+	AddMember(ctx context.Context, groupID, userID int) error
+	RemoveMember(ctx context.Context, groupID, userID int) error
+	UserPermissions(ctx context.Context, userID int) (domain.Role, []domain.Permission, error)
+}
+
+// PermissionCache keeps effective permissions per organization and user. ok is false on a miss.
+type PermissionCache interface {
+	Get(ctx context.Context, orgID, userID int) ([]domain.Permission, bool, error)
+	Set(ctx context.Context, orgID, userID int, perms []domain.Permission) error
+	Delete(ctx context.Context, orgID int, userIDs ...int) error
+	DeleteOrg(ctx context.Context, orgID int) error
+}
+
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// AccessService answers what the caller may do in their current organization
+// and manages groups. Effective permissions are the role ones plus the ones of user's groups.
+type AccessService struct {
+	repo  GroupRepository
+	cache PermissionCache // nil when cache is disabled
+	tx    TxManager
+
+	validator *validator.Validate
+}
+
+func New(repo GroupRepository, cache PermissionCache, tx TxManager, v *validator.Validate) *AccessService {
+	return &AccessService{
+		repo:      repo,
+		cache:     cache,
+		tx:        tx,
+		validator: v,
+	}
+}
+
+// Permissions of the caller, none without an organization.
+func (s *AccessService) Permissions(ctx context.Context) ([]domain.Permission, error) {
+	ctx, span := tracing.Start(ctx, "AccessService.Permissions")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+	orgID, ok := domain.OrgID(ctx)
+	if !ok {
+		return []domain.Permission{}, nil
+	}
+
+	if s.cache != nil {
+		// errors are logged by the cache, db answers then
+		if perms, ok, err := s.cache.Get(ctx, orgID, r.UserID); err == nil && ok {
+			return perms, nil
+		}
+	}
+
+	role, groupPerms, err := s.repo.UserPermissions(ctx, r.UserID)
+	if errors.Is(err, domain.ErrNotMember) {
+		// token of a removed member, it is valid till it expires but can't do anything
+		role, err = "", nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	perms := effective(role, groupPerms)
+	if s.cache != nil {
+		_ = s.cache.Set(ctx, orgID, r.UserID, perms)
+	}
+	return perms, nil
+}
+
+func (s *AccessService) Can(ctx context.Context, p domain.Permission) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AccessService.Can")
+	defer span.End()
+
+	perms, err := s.Permissions(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(perms, p), nil
+}
+
+// CanActOn is the policy of user endpoints: everyone may act on themselves, others need p.
+func (s *AccessService) CanActOn(ctx context.Context, userID int, p domain.Permission) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AccessService.CanActOn")
+	defer span.End()
+
+	if id, ok := domain.RequesterID(ctx); ok && id == userID {
+		return true, nil
+	}
+	return s.Can(ctx, p)
+}
+
+// InvalidatePermissions is for changes made elsewhere, e.g. role changes and removed members.
+func (s *AccessService) InvalidatePermissions(ctx context.Context, orgID int, userIDs ...int) {
+	ctx, span := tracing.Start(ctx, "AccessService.InvalidatePermissions")
+	defer span.End()
+
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(ctx, orgID, userIDs...); err != nil {
+		slog.ErrorContext(ctx, "Failed to invalidate permissions", "org_id", orgID, "user_ids", userIDs, "error", err)
+	}
+}
+
+func (s *AccessService) ListGroups(ctx context.Context) ([]*domain.Group, error) {
+	ctx, span := tracing.Start(ctx, "AccessService.ListGroups")
+	defer span.End()
+
+	return s.repo.List(ctx)
+}
+
+func (s *AccessService) GetGroup(ctx context.Context, id int) (*domain.Group, error) {
+	ctx, span := tracing.Start(ctx, "AccessService.GetGroup")
+	defer span.End()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+// CreateGroup can only give permissions the caller has, so groups are no way to escalate.
+func (s *AccessService) CreateGroup(ctx context.Context, name string, perms []domain.Permission) (*domain.Group, error) {
+	ctx, span := tracing.Start(ctx, "AccessService.CreateGroup")
+	defer span.End()
+
+	perms, err := s.grantable(ctx, perms)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Create(ctx, name, perms)
+}
+
+// UpdateGroup replaces name and permissions. The caller must have the old permissions as well.
+func (s *AccessService) UpdateGroup(ctx context.Context, id int, name string, perms []domain.Permission) (*domain.Group, error) {
+	ctx, span := tracing.Start(ctx, "AccessService.UpdateGroup")
+	defer span.End()
+
+	perms, err := s.grantable(ctx, perms)
+	if err != nil {
+		return nil, err
+	}
+
+	var group *domain.Group
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		old, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := s.grantable(ctx, old.Permissions); err != nil {
+			return err
+		}
+		if err := s.repo.Update(ctx, id, name, perms); err != nil {
+			return err
+		}
+		group = old
+		group.Name, group.Permissions = name, perms
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateOrg(ctx)
+	return group, nil
+}
+
+func (s *AccessService) DeleteGroup(ctx context.Context, id int) error {
+	ctx, span := tracing.Start(ctx, "AccessService.DeleteGroup")
+	defer span.End()
+
+	if err := s.repo.DeleteByID(ctx, id); err != nil {
+		return err
+	}
+	s.invalidateOrg(ctx)
+	return nil
+}
+
+// AddGroupMember needs every permission of the group, like CreateGroup.
+func (s *AccessService) AddGroupMember(ctx context.Context, groupID, userID int) error {
+	ctx, span := tracing.Start(ctx, "AccessService.AddGroupMember")
+	defer span.End()
+
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		group, err := s.repo.GetByID(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		if _, err := s.grantable(ctx, group.Permissions); err != nil {
+			return err
+		}
+		return s.repo.AddMember(ctx, groupID, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	orgID, _ := domain.OrgID(ctx)
+	s.InvalidatePermissions(ctx, orgID, userID)
+	return nil
+}
+
+func (s *AccessService) RemoveGroupMember(ctx context.Context, groupID, userID int) error {
+	ctx, span := tracing.Start(ctx, "AccessService.RemoveGroupMember")
+	defer span.End()
+
+	if err := s.repo.RemoveMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+
+	orgID, _ := domain.OrgID(ctx)
+	s.InvalidatePermissions(ctx, orgID, userID)
+	return nil
+}
+
+func (s *AccessService) GetValidator() *validator.Validate {
+	return s.validator
+}
+
+// grantable checks the names and that the caller has all of perms, returns them sorted and deduplicated.
+func (s *AccessService) grantable(ctx context.Context, perms []domain.Permission) ([]domain.Permission, error) {
+	own, err := s.Permissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range perms {
+		if !p.Valid() {
+			return nil, domain.ErrUnknownPermission
+		}
+		if !slices.Contains(own, p) {
+			return nil, domain.ErrPermissionDenied
+		}
+	}
+	return normalize(perms), nil
+}
+
+func (s *AccessService) invalidateOrg(ctx context.Context) {
+	orgID, ok := domain.OrgID(ctx)
+	if s.cache == nil || !ok {
+		return
+	}
+	if err := s.cache.DeleteOrg(ctx, orgID); err != nil {
+		slog.ErrorContext(ctx, "Failed to invalidate permissions", "org_id", orgID, "error", err)
+	}
+}
+
+// effective joins role and group permissions. Names no longer known are dropped.
+func effective(role domain.Role, groupPerms []domain.Permission) []domain.Permission {
+	return normalize(append(slices.Clone(domain.RolePermissions[role]), groupPerms...))
+}
+
+// normalize keeps known permissions once, in the order of domain.AllPermissions.
+func normalize(perms []domain.Permission) []domain.Permission {
+	out := make([]domain.Permission, 0, len(perms))
+	for _, p := range domain.AllPermissions {
+		if slices.Contains(perms, p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
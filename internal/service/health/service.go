@@ -0,0 +1,86 @@
+package healthService
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type check struct {
+	name     string
+	optional bool
+	fn       func(context.Context) error
+}
+
+// HealthService answers readiness probes. A failing required dependency makes the
+// instance not ready, a failing optional one (the cache) only degraded.
+type HealthService struct {
+	checks   []check
+	timeout  time.Duration
+	shutdown atomic.Bool
+}
+
+func New(timeout time.Duration) *HealthService {
+	return &HealthService{timeout: timeout}
+}
+
+func (s *HealthService) WithCheck(name string, fn func(context.Context) error) *HealthService {
+	s.checks = append(s.checks, check{name: name, fn: fn})
+	return s
+}
+
+func (s *HealthService) WithOptionalCheck(name string, fn func(context.Context) error) *HealthService {
+	s.checks = append(s.checks, check{name: name, optional: true, fn: fn})
+	return s
+}
+
+// Shutdown makes the instance not ready for good, the load balancer stops sending
+// requests while in-flight ones are drained.
+func (s *HealthService) Shutdown() {
+	s.shutdown.Store(true)
+}
+
+// Ready runs all checks at once, each gets the timeout. Errors are logged only,
+// the probe endpoint is public and they tell hosts and ports.
+func (s *HealthService) Ready(ctx context.Context) *domain.Readiness {
+	res := &domain.Readiness{Status: domain.HealthUp, Dependencies: make(map[string]domain.DependencyHealth, len(s.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, s.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.fn(ctx)
+			dep := domain.DependencyHealth{Status: domain.HealthUp, Optional: c.optional, Duration: time.Since(start)}
+			if err != nil {
+				slog.WarnContext(ctx, "Health check failed", "dependency", c.name, "error", err)
+				dep.Status = domain.HealthDown
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.Dependencies[c.name] = dep
+			switch {
+			case err == nil:
+			case !c.optional:
+				res.Status = domain.HealthDown
+			case res.Status == domain.HealthUp:
+				res.Status = domain.HealthDegraded
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s.shutdown.Load() {
+		res.Status = domain.HealthShutdown
+	}
+	return res
+}
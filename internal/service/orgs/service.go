@@ -0,0 +1,214 @@
+package orgsService
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"github.com/Arh0rn/test-task1/pkg/jwtoken"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"time"
+)
+
+var errNoRequester = errors.New("no authenticated user in context")
+
+type OrgRepository interface {
+	Create(ctx context.Context, name string) (*domain.Organization, error)
+	AddMember(ctx context.Context, orgID, userID int, role domain.Role) (*domain.Membership, error)
+	ListByUser(ctx context.Context, userID int) ([]*domain.Membership, error)
+	GetMembership(ctx context.Context, orgID, userID int) (*domain.Membership, error)
+	SetRole(ctx context.Context, orgID, userID int, role domain.Role) error
+	LockOwners(ctx context.Context, orgID int) ([]int, error)
+}
+
+// UserCache entries carry user's organizations, they are dropped when those change.
+type UserCache interface {
+	DeleteByID(ctx context.Context, id int) error
+}
+
+// PermissionInvalidator drops cached permissions, they depend on the role.
+type PermissionInvalidator interface {
+	InvalidatePermissions(ctx context.Context, orgID int, userIDs ...int)
+}
+
+// Sessions moves the caller's session to another organization.
+type Sessions interface {
+	Switch(ctx context.Context, orgID int) (*domain.Tokens, error)
+}
+
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type OrgService struct {
+	repo  OrgRepository
+	cache UserCache
+	tx    TxManager
+	perms PermissionInvalidator // optional
+	sess  Sessions              // optional, plain access tokens without it
+
+	validator *validator.Validate
+	jwtSecret []byte
+	tokenTTL  time.Duration
+}
+
+func New(repo OrgRepository, cache UserCache, tx TxManager, v *validator.Validate, jwts []byte, tttl time.Duration) *OrgService {
+	return &OrgService{
+		repo:      repo,
+		cache:     cache,
+		tx:        tx,
+		validator: v,
+		jwtSecret: jwts,
+		tokenTTL:  tttl,
+	}
+}
+
+// WithPermissionInvalidator is needed when permissions are cached.
+func (s *OrgService) WithPermissionInvalidator(p PermissionInvalidator) *OrgService {
+	s.perms = p
+	return s
+}
+
+func (s *OrgService) WithSessions(sessions Sessions) *OrgService {
+	s.sess = sessions
+	return s
+}
+
+// List returns organizations of the caller.
+func (s *OrgService) List(ctx context.Context) ([]*domain.Membership, error) {
+	ctx, span := tracing.Start(ctx, "OrgService.List")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+	return s.repo.ListByUser(ctx, r.UserID)
+}
+
+// Create makes a new organization owned by the caller, Switch to work in it.
+func (s *OrgService) Create(ctx context.Context, name string) (*domain.Membership, error) {
+	ctx, span := tracing.Start(ctx, "OrgService.Create")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+
+	var m *domain.Membership
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		org, err := s.repo.Create(ctx, name)
+		if err != nil {
+			return err
+		}
+		m, err = s.repo.AddMember(ctx, org.ID, r.UserID, domain.RoleOwner)
+		if err != nil {
+			return err
+		}
+		m.Org = *org
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.dropCachedUser(ctx, r.UserID)
+	return m, nil
+}
+
+// Switch issues a token for another organization of the caller.
+func (s *OrgService) Switch(ctx context.Context, orgID int) (*domain.Tokens, error) {
+	ctx, span := tracing.Start(ctx, "OrgService.Switch")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+	if _, err := s.membership(ctx, orgID, r.UserID); err != nil {
+		return nil, err
+	}
+	if s.sess != nil {
+		return s.sess.Switch(ctx, orgID)
+	}
+
+	claims := jwtoken.Claims{UserID: r.UserID, Email: r.Email, OrgID: orgID}
+	token, err := jwtoken.GenerateToken(claims, s.jwtSecret, s.tokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Tokens{AccessToken: token}, nil
+}
+
+// SetRole changes role of a member. Admins manage members and other admins,
+// owners manage everyone. The last owner can't step down.
+func (s *OrgService) SetRole(ctx context.Context, orgID, userID int, role domain.Role) (*domain.Membership, error) {
+	ctx, span := tracing.Start(ctx, "OrgService.SetRole")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+
+	var target *domain.Membership
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		caller, err := s.membership(ctx, orgID, r.UserID)
+		if err != nil {
+			return err
+		}
+		target, err = s.repo.GetMembership(ctx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if !caller.Role.AtLeast(domain.RoleAdmin) || !caller.Role.AtLeast(target.Role) || !caller.Role.AtLeast(role) {
+			return domain.ErrForbidden
+		}
+
+		if target.Role == domain.RoleOwner && role != domain.RoleOwner {
+			owners, err := s.repo.LockOwners(ctx, orgID)
+			if err != nil {
+				return err
+			}
+			if len(owners) <= 1 {
+				return domain.ErrLastOwner
+			}
+		}
+		if err := s.repo.SetRole(ctx, orgID, userID, role); err != nil {
+			return err
+		}
+		target.Role = role
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.perms != nil {
+		s.perms.InvalidatePermissions(ctx, orgID, userID)
+	}
+	return target, nil
+}
+
+func (s *OrgService) GetValidator() *validator.Validate {
+	return s.validator
+}
+
+// membership is the caller's one, organizations of others look like they don't exist.
+func (s *OrgService) membership(ctx context.Context, orgID, userID int) (*domain.Membership, error) {
+	m, err := s.repo.GetMembership(ctx, orgID, userID)
+	if errors.Is(err, domain.ErrNotMember) {
+		return nil, domain.ErrOrgNotFound
+	}
+	return m, err
+}
+
+func (s *OrgService) dropCachedUser(ctx context.Context, id int) {
+	go func() {
+		if err := s.cache.DeleteByID(context.WithoutCancel(ctx), id); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete user from cache", "id", id, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,291 @@
+package sessionsService
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"github.com/Arh0rn/test-task1/pkg/jwtoken"
+	"github.com/Arh0rn/test-task1/pkg/lru"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var errNoRequester = errors.New("no authenticated user in context")
+
+const refreshSecretSize = 32
+
+type SessionRepository interface {
+	Create(ctx context.Context, s *domain.Session, refreshHash []byte) error
+	ListActive(ctx context.Context, userID int) ([]*domain.Session, error)
+	Touch(ctx context.Context, id, ip string) error
+	Revoke(ctx context.Context, userID int, id string) error
+	RevokeReused(ctx context.Context, id string, hash []byte) (revoked bool, err error)
+	Rotate(ctx context.Context, id string, oldHash, newHash []byte, expires time.Time, client domain.Client) (*domain.Session, string, error)
+	SetOrg(ctx context.Context, id string, orgID int) error
+	RevokeAll(ctx context.Context, userID int) ([]string, error)
+	ListByUser(ctx context.Context, userID int) ([]*domain.Session, error)
+}
+
+// Revocations spread revoked session ids between replicas, so their check caches drop them at once.
+type Revocations interface {
+	Publish(ctx context.Context, ids ...string)
+	Listen(ctx context.Context, fn func(id string))
+}
+
+// SessionService issues tokens bound to sessions and checks that sessions of
+// presented tokens are still live. Refresh tokens are "<session id>.<secret>",
+// only a hash of the secret is stored and it changes on every refresh.
+type SessionService struct {
+	repo SessionRepository
+
+	jwtSecret  []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	// sessions checked recently, they are not touched in db on every request.
+	// A revoke on another instance is seen when its revocation arrives,
+	// after checkInterval at most when it is lost or there are no revocations.
+	checked     *lru.Cache[string, struct{}]
+	revocations Revocations // optional
+}
+
+func New(repo SessionRepository, jwts []byte, accessTTL, refreshTTL time.Duration) *SessionService {
+	return &SessionService{
+		repo:       repo,
+		jwtSecret:  jwts,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// WithCheckCache remembers up to size live sessions for interval, without it every request hits db.
+func (s *SessionService) WithCheckCache(size int, interval time.Duration) *SessionService {
+	if size > 0 && interval > 0 {
+		s.checked = lru.New[string, struct{}](size, interval)
+	}
+	return s
+}
+
+// WithRevocations publishes revoked sessions to other replicas, run ListenRevocations to receive theirs.
+func (s *SessionService) WithRevocations(r Revocations) *SessionService {
+	s.revocations = r
+	return s
+}
+
+// ListenRevocations drops sessions revoked by other replicas from the check cache,
+// blocks until ctx is done.
+func (s *SessionService) ListenRevocations(ctx context.Context) {
+	if s.revocations == nil || s.checked == nil {
+		return
+	}
+	s.revocations.Listen(ctx, s.checked.Delete)
+}
+
+// Start opens a session for a user that just proved who they are.
+func (s *SessionService) Start(ctx context.Context, userID int, email string, orgID int) (*domain.Tokens, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Start")
+	defer span.End()
+
+	client := domain.ClientFrom(ctx)
+	secret, hash, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	sess := &domain.Session{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		OrgID:     orgID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if err := s.repo.Create(ctx, sess, hash); err != nil {
+		return nil, err
+	}
+
+	access, err := s.accessToken(userID, email, orgID, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Session started", "session_id", sess.ID, "user_id", userID)
+	return &domain.Tokens{
+		AccessToken:  access,
+		RefreshToken: sess.ID + "." + secret,
+		SessionID:    sess.ID,
+	}, nil
+}
+
+// Refresh trades a refresh token for new tokens of the same session. A refresh
+// token that was already used means it leaked, the session is revoked then.
+// Any other wrong secret is only rejected, knowing the session id is not enough to end it.
+func (s *SessionService) Refresh(ctx context.Context, token string) (*domain.Tokens, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Refresh")
+	defer span.End()
+
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || uuid.Validate(id) != nil || secret == "" {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+	newSecretStr, newHash, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashSecret(secret)
+	// the token is the only credential here, Rotate reads the email of any user
+	sess, email, err := s.repo.Rotate(domain.WithUnscoped(ctx), id, hash, newHash, time.Now().Add(s.refreshTTL), domain.ClientFrom(ctx))
+	if errors.Is(err, domain.ErrInvalidRefreshToken) {
+		if revoked, err := s.repo.RevokeReused(ctx, id, hash); err == nil && revoked {
+			slog.WarnContext(ctx, "Refresh token reused, session revoked", "session_id", id)
+			s.forget(ctx, id)
+		}
+		return nil, domain.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.accessToken(sess.UserID, email, sess.OrgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Tokens{
+		AccessToken:  access,
+		RefreshToken: id + "." + newSecretStr,
+		SessionID:    id,
+	}, nil
+}
+
+// Switch moves the caller's session to orgID and issues an access token for it.
+// Membership is checked by the caller.
+func (s *SessionService) Switch(ctx context.Context, orgID int) (*domain.Tokens, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Switch")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+	if err := s.repo.SetOrg(ctx, r.SessionID, orgID); err != nil {
+		return nil, err
+	}
+	access, err := s.accessToken(r.UserID, r.Email, orgID, r.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Tokens{AccessToken: access, SessionID: r.SessionID}, nil
+}
+
+// Check is ErrSessionRevoked when the session is over, it also marks the session as seen.
+func (s *SessionService) Check(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "SessionService.Check")
+	defer span.End()
+
+	if s.checked != nil {
+		if _, ok := s.checked.Get(id); ok {
+			return nil
+		}
+	}
+	if err := s.repo.Touch(ctx, id, domain.ClientFrom(ctx).IP); err != nil {
+		return err
+	}
+	if s.checked != nil {
+		s.checked.Set(id, struct{}{})
+	}
+	return nil
+}
+
+// List returns live sessions of the caller and the id of the one the request came with.
+func (s *SessionService) List(ctx context.Context) ([]*domain.Session, string, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.List")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, "", errNoRequester
+	}
+	sessions, err := s.repo.ListActive(ctx, r.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	return sessions, r.SessionID, nil
+}
+
+// Revoke ends one of the caller's sessions, tokens of it stop working.
+func (s *SessionService) Revoke(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "SessionService.Revoke")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return errNoRequester
+	}
+	if uuid.Validate(id) != nil {
+		return domain.ErrSessionNotFound
+	}
+	if err := s.repo.Revoke(ctx, r.UserID, id); err != nil {
+		return err
+	}
+	s.forget(ctx, id)
+	slog.InfoContext(ctx, "Session revoked", "session_id", id)
+	return nil
+}
+
+// RevokeAll logs the user out everywhere.
+func (s *SessionService) RevokeAll(ctx context.Context, userID int) error {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeAll")
+	defer span.End()
+
+	ids, err := s.repo.RevokeAll(ctx, userID)
+	if err != nil {
+		return err
+	}
+	s.forget(ctx, ids...)
+	slog.InfoContext(ctx, "Sessions revoked", "user_id", userID, "count", len(ids))
+	return nil
+}
+
+// History is every stored session of the user, revoked and expired ones too.
+func (s *SessionService) History(ctx context.Context, userID int) ([]*domain.Session, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.History")
+	defer span.End()
+
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *SessionService) forget(ctx context.Context, ids ...string) {
+	if s.checked != nil {
+		for _, id := range ids {
+			s.checked.Delete(id)
+		}
+	}
+	if s.revocations != nil && len(ids) > 0 {
+		s.revocations.Publish(ctx, ids...)
+	}
+}
+
+func (s *SessionService) accessToken(userID int, email string, orgID int, sid string) (string, error) {
+	claims := jwtoken.Claims{UserID: userID, Email: email, OrgID: orgID, SessionID: sid}
+	return jwtoken.GenerateToken(claims, s.jwtSecret, s.accessTTL)
+}
+
+func newSecret() (string, []byte, error) {
+	b := make([]byte, refreshSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	return secret, hashSecret(secret), nil
+}
+
+// secrets are random, a plain hash is enough to not keep usable tokens in db
+func hashSecret(secret string) []byte {
+	h := sha256.Sum256([]byte(secret))
+	return h[:]
+}
@@ -0,0 +1,180 @@
+package usersService
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"log/slog"
+	"time"
+)
+
+const defaultDeletionGrace = 30 * 24 * time.Hour
+
+// WithAccountDeletion sets how long a deleted account can still be restored by logging in.
+func (s *UserService) WithAccountDeletion(grace time.Duration) *UserService {
+	s.deletionGrace = grace
+	return s
+}
+
+// ScheduleDeletion deletes the caller's account after the grace period, password
+// must be theirs. The caller is logged out everywhere. Sole owners of organizations
+// with other members must hand them over first.
+func (s *UserService) ScheduleDeletion(ctx context.Context, password string) (time.Time, error) {
+	ctx, span := tracing.Start(ctx, "UserService.ScheduleDeletion")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return time.Time{}, errNoRequester
+	}
+	if r.Impersonated() {
+		return time.Time{}, domain.ErrImpersonating
+	}
+	account, err := s.repo.GetAccount(ctx, r.UserID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !s.hasher.Verify(password, account.Password) {
+		return time.Time{}, domain.ErrInvalidCredentials
+	}
+
+	deleteAfter := time.Now().Add(s.deletionGrace)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		memberships, err := s.orgs.ListByUser(ctx, r.UserID)
+		if err != nil {
+			return err
+		}
+		for _, m := range memberships {
+			if err := s.checkLastOwner(ctx, m.Org.ID, r.UserID); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.ScheduleDeletion(ctx, r.UserID, deleteAfter); err != nil {
+			return err
+		}
+		if s.audit == nil {
+			return nil
+		}
+		return s.audit.Create(ctx, &domain.AuditEvent{
+			OrgID:     r.OrgID,
+			ActorID:   r.UserID,
+			SubjectID: r.UserID,
+			Action:    domain.AuditDeletionScheduled,
+			Details:   map[string]any{"delete_after": deleteAfter.UTC()},
+		})
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if s.sessions != nil {
+		if err := s.sessions.RevokeAll(ctx, r.UserID); err != nil {
+			slog.ErrorContext(ctx, "Failed to revoke sessions of deleted account", "error", err)
+		}
+	}
+	slog.InfoContext(ctx, "Account deletion scheduled", "delete_after", deleteAfter)
+	return deleteAfter, nil
+}
+
+// ExportAccount collects everything stored about the caller.
+func (s *UserService) ExportAccount(ctx context.Context) (*domain.AccountExport, error) {
+	ctx, span := tracing.Start(ctx, "UserService.ExportAccount")
+	defer span.End()
+
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+	if r.Impersonated() {
+		return nil, domain.ErrImpersonating
+	}
+
+	export := &domain.AccountExport{
+		Sessions:    []*domain.Session{},
+		AuditEvents: []*domain.AuditEvent{},
+		ExportedAt:  time.Now(),
+	}
+	var err error
+	if export.Account, err = s.repo.GetAccount(ctx, r.UserID); err != nil {
+		return nil, err
+	}
+	export.Account.Password = ""
+	if export.Memberships, err = s.orgs.ListByUser(ctx, r.UserID); err != nil {
+		return nil, err
+	}
+	if s.sessions != nil {
+		if export.Sessions, err = s.sessions.History(ctx, r.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if s.audit != nil {
+		if export.AuditEvents, err = s.audit.ListByUser(ctx, r.UserID); err != nil {
+			return nil, err
+		}
+	}
+	slog.InfoContext(ctx, "Account exported")
+	return export, nil
+}
+
+// RunDeletions purges accounts whose grace period is over, every interval until ctx is done.
+func (s *UserService) RunDeletions(ctx context.Context, interval time.Duration) {
+	// due accounts are in any organization
+	ctx = domain.WithUnscoped(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				err := s.purgeNext(ctx)
+				if errors.Is(err, domain.ErrUserNotFound) {
+					break // none left
+				}
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to purge account", "error", err)
+					break
+				}
+			}
+		}
+	}
+}
+
+// purgeNext deletes one due account, it's ErrUserNotFound when there is none.
+// Memberships, group memberships and sessions go with the user, audit events
+// stay with the user's references cleared.
+func (s *UserService) purgeNext(ctx context.Context) error {
+	var id int
+	var memberships []*domain.Membership
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = s.repo.LockDueDeletion(ctx)
+		if err != nil {
+			return err
+		}
+		memberships, err = s.orgs.ListByUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.repo.Purge(ctx, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	// user:<id> holds the profile, it must not outlive the account
+	if err := s.cache.DeleteByID(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete purged user from cache, it expires with ttl", "id", id, "error", err)
+	}
+	s.invalidateLists(ctx)
+	if s.perms != nil {
+		for _, m := range memberships {
+			s.perms.InvalidatePermissions(ctx, m.Org.ID, id)
+		}
+	}
+	s.deleteAvatars(ctx, id)
+	slog.InfoContext(ctx, "Account purged", "user_id", id)
+	return nil
+}
@@ -0,0 +1,157 @@
+package usersService
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"github.com/Arh0rn/test-task1/pkg/thumbnail"
+	"log/slog"
+	"slices"
+	"strconv"
+)
+
+const (
+	avatarOriginal    = "original"
+	avatarContentType = "image/jpeg"
+)
+
+var errAvatarsDisabled = errors.New("avatar storage is not configured")
+
+// BlobStore keeps uploaded files, see internal/blobstore.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (*domain.Blob, error)
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+type AvatarOptions struct {
+	OriginalSize int   // longest side of the kept original
+	Sizes        []int // square thumbnails
+	Quality      int
+	MaxPixels    int
+	PublicURL    string // avatar_url base
+}
+
+// WithAvatars enables avatar upload, without it SetAvatar and GetAvatar fail.
+func (s *UserService) WithAvatars(store BlobStore, opts AvatarOptions) *UserService {
+	s.blobs = store
+	s.avatarOpts = opts
+	return s
+}
+
+// SetAvatar stores the image scaled to every configured size and points user's avatar_url at it.
+// The url carries a content version, so clients can cache it forever.
+func (s *UserService) SetAvatar(ctx context.Context, id int, image []byte) (*domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.SetAvatar")
+	defer span.End()
+
+	if s.blobs == nil {
+		return nil, errAvatarsDisabled
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	variants, err := s.avatarVariants(image)
+	if err != nil {
+		return nil, err
+	}
+	for variant, data := range variants {
+		if err := s.blobs.Put(ctx, avatarKey(id, variant), data, avatarContentType); err != nil {
+			return nil, err
+		}
+	}
+
+	sum := sha256.Sum256(variants[avatarOriginal])
+	url := fmt.Sprintf("%s/users/%d/avatar?v=%s", s.avatarOpts.PublicURL, id, hex.EncodeToString(sum[:6]))
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.repo.UpdateAvatarURL(ctx, id, url)
+	})
+	if errors.Is(err, domain.ErrUserNotFound) { // deleted while we were uploading
+		s.deleteAvatars(context.WithoutCancel(ctx), id)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateLists(ctx)
+
+	go func() {
+		if err := s.cache.DeleteByID(context.Background(), id); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete user from cache", "id", id, "error", err)
+		}
+	}()
+
+	user, err := s.repo.GetByID(ctx, id) // MarkWrite keeps it on the primary
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetAvatar returns the original for size 0, or a thumbnail of one of the configured sizes.
+func (s *UserService) GetAvatar(ctx context.Context, id int, size int) (*domain.Blob, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAvatar")
+	defer span.End()
+
+	if s.blobs == nil {
+		return nil, errAvatarsDisabled
+	}
+	variant := avatarOriginal
+	if size != 0 {
+		if !slices.Contains(s.avatarOpts.Sizes, size) {
+			return nil, domain.ErrAvatarSize
+		}
+		variant = strconv.Itoa(size)
+	}
+	// the same membership check as for the user itself
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	blob, err := s.blobs.Get(ctx, avatarKey(id, variant))
+	if errors.Is(err, domain.ErrBlobNotFound) {
+		return nil, domain.ErrAvatarNotFound
+	}
+	return blob, err
+}
+
+func (s *UserService) avatarVariants(image []byte) (map[string][]byte, error) {
+	img, _, err := thumbnail.Decode(image, s.avatarOpts.MaxPixels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidAvatar, err)
+	}
+
+	variants := make(map[string][]byte, len(s.avatarOpts.Sizes)+1)
+	if variants[avatarOriginal], err = thumbnail.JPEG(thumbnail.Fit(img, s.avatarOpts.OriginalSize), s.avatarOpts.Quality); err != nil {
+		return nil, err
+	}
+	for _, size := range s.avatarOpts.Sizes {
+		if variants[strconv.Itoa(size)], err = thumbnail.JPEG(thumbnail.Square(img, size), s.avatarOpts.Quality); err != nil {
+			return nil, err
+		}
+	}
+	return variants, nil
+}
+
+func (s *UserService) deleteAvatars(ctx context.Context, id int) {
+	if s.blobs == nil {
+		return
+	}
+	if err := s.blobs.DeletePrefix(ctx, avatarPrefix(id)); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete avatars", "id", id, "error", err)
+	}
+}
+
+func avatarPrefix(id int) string {
+	return "avatars/" + strconv.Itoa(id) + "/"
+}
+
+func avatarKey(id int, variant string) string {
+	return avatarPrefix(id) + variant + ".jpg"
+}
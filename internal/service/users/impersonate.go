@@ -0,0 +1,95 @@
+package usersService
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"github.com/Arh0rn/test-task1/pkg/jwtoken"
+	"log/slog"
+	"time"
+)
+
+var errImpersonationDisabled = errors.New("impersonation is not configured")
+
+// AuditLog keeps events about users, they outlive the users.
+type AuditLog interface {
+	Create(ctx context.Context, e *domain.AuditEvent) error
+	ListByUser(ctx context.Context, userID int) ([]*domain.AuditEvent, error)
+}
+
+func (s *UserService) WithAuditLog(audit AuditLog) *UserService {
+	s.audit = audit
+	return s
+}
+
+// WithImpersonation lets admins act as members of their organization for ttl, needs the audit log.
+func (s *UserService) WithImpersonation(ttl time.Duration) *UserService {
+	s.impersonationTTL = ttl
+	return s
+}
+
+// Impersonate issues a token of userID for the caller. Only owners and admins may
+// do it, for users of their organization whose role is not above theirs. The token
+// belongs to the caller's session, has no refresh token and carries the caller as actor.
+func (s *UserService) Impersonate(ctx context.Context, userID int) (*domain.Tokens, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Impersonate")
+	defer span.End()
+
+	if s.audit == nil || s.impersonationTTL <= 0 {
+		return nil, errImpersonationDisabled
+	}
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+	org, ok := domain.OrgID(ctx)
+	if !ok {
+		return nil, domain.ErrNoOrg
+	}
+	if r.Impersonated() {
+		return nil, domain.ErrImpersonating
+	}
+	if userID == r.UserID {
+		return nil, domain.ErrImpersonationNotAllowed
+	}
+
+	caller, err := s.orgs.GetMembership(ctx, org, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.orgs.GetMembership(ctx, org, userID)
+	if errors.Is(err, domain.ErrNotMember) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Role.AtLeast(domain.RoleAdmin) || !caller.Role.AtLeast(target.Role) {
+		return nil, domain.ErrImpersonationNotAllowed
+	}
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(s.impersonationTTL)
+	err = s.audit.Create(ctx, &domain.AuditEvent{
+		OrgID:     org,
+		ActorID:   r.UserID,
+		SubjectID: userID,
+		Action:    domain.AuditImpersonationStarted,
+		Details:   map[string]any{"session_id": r.SessionID, "expires_at": expires.UTC()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwtoken.Claims{UserID: user.ID, Email: user.Email, OrgID: org, SessionID: r.SessionID, ActorID: r.UserID}
+	token, err := jwtoken.GenerateToken(claims, s.jwtSecret, s.impersonationTTL)
+	if err != nil {
+		return nil, err
+	}
+	slog.WarnContext(ctx, "Impersonation started", "target_id", userID)
+	return &domain.Tokens{AccessToken: token, SessionID: r.SessionID}, nil
+}
@@ -0,0 +1,278 @@
+package usersService
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"iter"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+const (
+	defaultImportBatchSize = 500
+	defaultImportRetention = time.Hour
+)
+
+var errImportJobsDisabled = errors.New("background imports are not configured")
+
+// ImportJobRepository keeps background imports, so every instance can report them.
+// Get is scoped to the organization from ctx.
+type ImportJobRepository interface {
+	Create(ctx context.Context, job *domain.ImportJob) error
+	Update(ctx context.Context, job *domain.ImportJob) error
+	Get(ctx context.Context, id string) (*domain.ImportJob, error)
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+// WithImport enables background imports and overrides batch size of imports and how long
+// finished jobs can be queried.
+func (s *UserService) WithImport(jobs ImportJobRepository, batchSize int, retention time.Duration) *UserService {
+	s.importJobs = jobs
+	if batchSize > 0 {
+		s.importBatchSize = batchSize
+	}
+	if retention > 0 {
+		s.importRetention = retention
+	}
+	return s
+}
+
+// Import inserts valid rows in batches and reports every row. A row whose email is
+// already taken is skipped, not overwritten, so a failed import can be simply repeated.
+// Error is returned only when rows can't be read anymore, rows before it are imported.
+func (s *UserService) Import(ctx context.Context, rows iter.Seq2[*domain.ImportRow, error]) (*domain.ImportReport, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Import")
+	defer span.End()
+
+	report := &domain.ImportReport{}
+	err := s.importRows(ctx, rows, report, func() {})
+	return report, err
+}
+
+// StartImport runs Import in background, progress is stored after every batch and
+// available via GetImportJob on any instance. rows are not read when it fails.
+func (s *UserService) StartImport(ctx context.Context, rows iter.Seq2[*domain.ImportRow, error]) (*domain.ImportJob, error) {
+	ctx, span := tracing.Start(ctx, "UserService.StartImport")
+	defer span.End()
+
+	if s.importJobs == nil {
+		return nil, errImportJobsDisabled
+	}
+	org, ok := domain.OrgID(ctx)
+	if !ok {
+		return nil, domain.ErrNoOrg
+	}
+	job := &domain.ImportJob{
+		ID:        uuid.NewString(),
+		OrgID:     org,
+		Status:    domain.ImportJobRunning,
+		Report:    &domain.ImportReport{},
+		StartedAt: time.Now(),
+	}
+
+	// jobs past retention go when new ones start, running ones update every batch
+	if err := s.importJobs.DeleteStale(ctx, time.Now().Add(-s.importRetention)); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete stale import jobs", "error", err)
+	}
+	if err := s.importJobs.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	snapshot := *job
+
+	go func() {
+		ctx := context.WithoutCancel(ctx)
+		slog.InfoContext(ctx, "Import job started", "job_id", job.ID)
+
+		report := &domain.ImportReport{}
+		err := s.importRows(ctx, rows, report, func() {
+			job.Report = report
+			s.saveImportJob(ctx, job)
+		})
+
+		job.Report = report
+		job.FinishedAt = time.Now()
+		job.Status = domain.ImportJobDone
+		if err != nil {
+			job.Status = domain.ImportJobFailed
+			job.Error = err.Error()
+		}
+		s.saveImportJob(ctx, job)
+
+		slog.InfoContext(ctx, "Import job finished", "job_id", job.ID, "status", job.Status,
+			"created", report.Created, "total", report.Total)
+	}()
+
+	return &snapshot, nil
+}
+
+// saveImportJob only logs failures, the import goes on and the next save may make it.
+func (s *UserService) saveImportJob(ctx context.Context, job *domain.ImportJob) {
+	if err := s.importJobs.Update(ctx, job); err != nil {
+		slog.ErrorContext(ctx, "Failed to save import job", "job_id", job.ID, "error", err)
+	}
+}
+
+func (s *UserService) GetImportJob(ctx context.Context, id string) (*domain.ImportJob, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetImportJob")
+	defer span.End()
+
+	if s.importJobs == nil || uuid.Validate(id) != nil {
+		return nil, domain.ErrImportJobNotFound
+	}
+	return s.importJobs.Get(ctx, id)
+}
+
+// Export streams all users to fn, it is not cached.
+func (s *UserService) Export(ctx context.Context, fn func(*domain.User) error) error {
+	ctx, span := tracing.Start(ctx, "UserService.Export")
+	defer span.End()
+
+	return s.repo.Export(ctx, fn)
+}
+
+// importRows fills report as rows are processed, progress is called after every batch.
+func (s *UserService) importRows(ctx context.Context, rows iter.Seq2[*domain.ImportRow, error], report *domain.ImportReport, progress func()) error {
+	batch := make([]*domain.ImportRow, 0, s.importBatchSize)
+	created := 0
+	defer func() {
+		if created > 0 {
+			s.invalidateLists(ctx)
+		}
+	}()
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := s.importBatch(ctx, batch, report)
+		created += n
+		batch = batch[:0]
+		progress()
+		return err
+	}
+
+	for row, err := range rows {
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read import rows", "error", err)
+			if flushErr := flush(); flushErr != nil {
+				return flushErr
+			}
+			return err
+		}
+
+		report.Total++
+		batch = append(batch, row)
+		if len(batch) == s.importBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// importBatch hashes passwords and inserts valid rows of the batch, returns number of created users.
+// A failed batch marks its rows failed, only ctx errors stop the import.
+func (s *UserService) importBatch(ctx context.Context, batch []*domain.ImportRow, report *domain.ImportReport) (int, error) {
+	results := make([]domain.ImportRowResult, len(batch)) // in file order
+	inputs := make([]*domain.SignUpInput, 0, len(batch))
+	rowIndex := make([]int, 0, len(batch)) // inputs[i] belongs to batch[rowIndex[i]]
+
+	hashed := make([]string, len(batch))
+	hashErrs := make([]error, len(batch))
+	var g errgroup.Group
+	g.SetLimit(runtime.GOMAXPROCS(0)) // bcrypt is cpu bound
+	for _, row := range batch {
+		if row.Err == nil {
+			if err := s.validateMetadata(row.Input.Metadata); err != nil {
+				row.Err = err
+			}
+		}
+	}
+	for i, row := range batch {
+		if row.Err != nil {
+			continue
+		}
+		g.Go(func() error {
+			hashed[i], hashErrs[i] = s.hasher.Hash(row.Input.Password)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	for i, row := range batch {
+		results[i] = domain.ImportRowResult{Line: row.Line}
+		if row.Input != nil {
+			results[i].Email = row.Input.Email
+		}
+		if row.Err != nil {
+			results[i].Status = domain.ImportRowInvalid
+			results[i].Error = row.Err.Error()
+			continue
+		}
+		if hashErrs[i] != nil {
+			results[i].Status = domain.ImportRowFailed
+			results[i].Error = hashErrs[i].Error()
+			continue
+		}
+		inputs = append(inputs, &domain.SignUpInput{
+			Name:     row.Input.Name,
+			Email:    row.Input.Email,
+			Password: hashed[i],
+			Profile:  row.Input.Profile,
+		})
+		rowIndex = append(rowIndex, i)
+	}
+
+	var (
+		users []*domain.User
+		err   error
+	)
+	if len(inputs) > 0 {
+		err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			var err error
+			users, err = s.repo.ImportBatch(ctx, inputs)
+			return err
+		})
+		if err != nil && ctx.Err() != nil {
+			return 0, err
+		}
+	}
+
+	created := 0
+	for j, i := range rowIndex {
+		switch {
+		case err != nil:
+			results[i].Status = domain.ImportRowFailed
+			results[i].Error = err.Error()
+		case users[j] == nil:
+			results[i].Status = domain.ImportRowExists
+			results[i].Error = domain.ErrUserAlreadyExists.Error()
+		default:
+			results[i].Status = domain.ImportRowCreated
+			results[i].ID = users[j].ID
+			created++
+		}
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case domain.ImportRowCreated:
+			report.Created++
+		case domain.ImportRowExists:
+			report.Exists++
+		case domain.ImportRowInvalid:
+			report.Invalid++
+		default:
+			report.Failed++
+		}
+	}
+	report.Rows = append(report.Rows, results...)
+	return created, nil
+}
@@ -0,0 +1,242 @@
+package usersService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
+	"github.com/Arh0rn/test-task1/pkg/signedtoken"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	errInvitationsDisabled = errors.New("invitations are not configured")
+	errNoRequester         = errors.New("no authenticated user in context")
+)
+
+type InvitationRepository interface {
+	Create(ctx context.Context, inv *domain.Invitation) error
+	ListPending(ctx context.Context) ([]*domain.Invitation, error)
+	DeleteByID(ctx context.Context, id string) error
+	LockPending(ctx context.Context, id string) (*domain.Invitation, error)
+	MarkAccepted(ctx context.Context, id string) error
+}
+
+// Notifier delivers messages to users, see internal/notifier.
+type Notifier interface {
+	Send(ctx context.Context, msg *domain.Message) error
+}
+
+type InvitationOptions struct {
+	TTL       time.Duration
+	AcceptURL string // {token} is replaced with the invite token
+}
+
+// WithInvitations enables invitations, tokens are signed with a key derived from the jwt secret.
+func (s *UserService) WithInvitations(repo InvitationRepository, notifier Notifier, opts InvitationOptions) *UserService {
+	s.invites = repo
+	s.notifier = notifier
+	s.inviteOpts = opts
+	s.inviteKey = signedtoken.Key(s.jwtSecret, "invitation")
+	return s
+}
+
+// WithOpenSignUp false makes SignUp fail, users join by invitation only.
+func (s *UserService) WithOpenSignUp(open bool) *UserService {
+	s.openSignUp = open
+	return s
+}
+
+// Invite sends an invitation to the current organization. The caller can't
+// invite with a role above their own.
+func (s *UserService) Invite(ctx context.Context, input *domain.InvitationInput) (*domain.Invitation, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Invite")
+	defer span.End()
+
+	if s.invites == nil {
+		return nil, errInvitationsDisabled
+	}
+	r, ok := domain.RequesterFrom(ctx)
+	if !ok {
+		return nil, errNoRequester
+	}
+	org, ok := domain.OrgID(ctx)
+	if !ok {
+		return nil, domain.ErrNoOrg
+	}
+	caller, err := s.orgs.GetMembership(ctx, org, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Role.AtLeast(input.Role) {
+		return nil, domain.ErrForbidden
+	}
+
+	inv := &domain.Invitation{
+		ID:        uuid.NewString(),
+		OrgName:   caller.Org.Name,
+		Email:     input.Email,
+		Role:      input.Role,
+		InvitedBy: r.UserID,
+		ExpiresAt: time.Now().Add(s.inviteOpts.TTL).Truncate(time.Second),
+	}
+	if err := s.invites.Create(ctx, inv); err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.Send(ctx, s.invitationMessage(inv)); err != nil {
+		slog.ErrorContext(ctx, "Failed to send invitation", "invitation_id", inv.ID, "error", err)
+		// nobody can accept it anyway
+		if err := s.invites.DeleteByID(context.WithoutCancel(ctx), inv.ID); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete undelivered invitation", "invitation_id", inv.ID, "error", err)
+		}
+		return nil, fmt.Errorf("%w: %w", domain.ErrNotifier, err)
+	}
+
+	slog.InfoContext(ctx, "Invitation sent", "invitation_id", inv.ID, "role", inv.Role)
+	return inv, nil
+}
+
+func (s *UserService) ListInvitations(ctx context.Context) ([]*domain.Invitation, error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListInvitations")
+	defer span.End()
+
+	if s.invites == nil {
+		return nil, errInvitationsDisabled
+	}
+	return s.invites.ListPending(ctx)
+}
+
+func (s *UserService) RevokeInvitation(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "UserService.RevokeInvitation")
+	defer span.End()
+
+	if s.invites == nil {
+		return errInvitationsDisabled
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return domain.ErrInvitationNotFound
+	}
+	return s.invites.DeleteByID(ctx, id)
+}
+
+// AcceptInvitation creates the account, or takes the password of an existing one,
+// and adds it to the organization. Returns tokens for that organization.
+func (s *UserService) AcceptInvitation(ctx context.Context, token string, input *domain.AcceptInvitationInput) (*domain.Tokens, error) {
+	ctx, span := tracing.Start(ctx, "UserService.AcceptInvitation")
+	defer span.End()
+
+	if s.invites == nil {
+		return nil, errInvitationsDisabled
+	}
+	id, err := signedtoken.Parse(s.inviteKey, token, time.Now())
+	if errors.Is(err, signedtoken.ErrExpired) {
+		return nil, domain.ErrInvitationExpired
+	}
+	if err != nil {
+		return nil, domain.ErrInvitationNotFound
+	}
+
+	// the invitee may be a member of other organizations or of none yet
+	ctx = domain.WithUnscoped(ctx)
+
+	// passwords are hashed before the invitation is locked, bcrypt is slow
+	inv, err := s.pendingInvitation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetByEmail(ctx, inv.Email)
+	switch {
+	case err == nil:
+		if !s.hasher.Verify(input.Password, user.Password) {
+			return nil, domain.ErrInvalidCredentials
+		}
+	case errors.Is(err, domain.ErrUserNotFound):
+		if input.Name == "" {
+			return nil, domain.ErrNameRequired
+		}
+		if err := s.validateMetadata(input.Metadata); err != nil {
+			return nil, err
+		}
+		hashed, err := s.hasher.Hash(input.Password)
+		if err != nil {
+			return nil, err
+		}
+		user = nil
+		input.Password = hashed
+	default:
+		return nil, err
+	}
+
+	existing := user
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// locked now, another accept of the same invitation waits and then finds it used
+		if _, err := s.invites.LockPending(ctx, id); err != nil {
+			return err
+		}
+		user = existing // fn may be retried, a user created by a rolled back attempt is gone
+		if user == nil {
+			var err error
+			user, err = s.repo.Create(ctx, &domain.SignUpInput{
+				Name:     input.Name,
+				Email:    inv.Email,
+				Password: input.Password,
+				Profile:  input.Profile,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := s.orgs.AddMember(ctx, inv.OrgID, user.ID, inv.Role); err != nil {
+			return err
+		}
+		return s.invites.MarkAccepted(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// user's organizations changed, cached entries and permissions are stale
+	s.invalidateLists(ctx)
+	if err := s.cache.DeleteByID(ctx, user.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete user from cache", "id", user.ID, "error", err)
+	}
+	if s.perms != nil {
+		s.perms.InvalidatePermissions(ctx, inv.OrgID, user.ID)
+	}
+	slog.InfoContext(ctx, "Invitation accepted", "invitation_id", id, "user_id", user.ID, "org_id", inv.OrgID)
+
+	return s.issueTokens(ctx, user, inv.OrgID)
+}
+
+func (s *UserService) pendingInvitation(ctx context.Context, id string) (*domain.Invitation, error) {
+	var inv *domain.Invitation
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		inv, err = s.invites.LockPending(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(inv.ExpiresAt) {
+		return nil, domain.ErrInvitationExpired
+	}
+	return inv, nil
+}
+
+func (s *UserService) invitationMessage(inv *domain.Invitation) *domain.Message {
+	token := signedtoken.New(s.inviteKey, inv.ID, inv.ExpiresAt)
+	link := strings.ReplaceAll(s.inviteOpts.AcceptURL, "{token}", url.PathEscape(token))
+	return &domain.Message{
+		To:      inv.Email,
+		Subject: fmt.Sprintf("You are invited to %s", inv.OrgName),
+		Text: fmt.Sprintf("You are invited to join %s as %s.\n\nAccept the invitation: %s\n\nThe link expires on %s.\n",
+			inv.OrgName, inv.Role, link, inv.ExpiresAt.UTC().Format(time.RFC1123)),
+	}
+}
@@ -0,0 +1,139 @@
+package usersService
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+const (
+	lockPollInterval = 25 * time.Millisecond
+	defaultLoadTime  = 10 * time.Millisecond // until we measured a real one
+)
+
+// Locker lets only one replica reload a key, see internal/cache/redis/lock.
+type Locker interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(context.Context), ok bool, err error)
+}
+
+// ExpiringUserCache is implemented by caches that can report entry ttl,
+// it enables probabilistic early refresh.
+type ExpiringUserCache interface {
+	GetByIDWithTTL(ctx context.Context, id int) (*domain.User, time.Duration, error)
+}
+
+// WithLoadLock makes cache miss loads coordinated across replicas.
+// beta > 0 also enables early refresh (XFetch), 1 is the usual value, bigger refreshes earlier.
+func (s *UserService) WithLoadLock(locker Locker, lockTTL time.Duration, beta float64) *UserService {
+	s.locker = locker
+	s.lockTTL = lockTTL
+	s.refreshBeta = beta
+	return s
+}
+
+func (s *UserService) getCachedByID(ctx context.Context, id int) (*domain.User, error) {
+	ec, ok := s.cache.(ExpiringUserCache)
+	if !ok || s.refreshBeta <= 0 {
+		return s.cache.GetByID(ctx, id)
+	}
+
+	user, ttl, err := ec.GetByIDWithTTL(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 && s.shouldRefresh(ttl) {
+		slog.DebugContext(ctx, "Refreshing user before expiry", "user_id", id, "ttl", ttl)
+		go s.refreshByID(context.WithoutCancel(ctx), id)
+	}
+	return user, nil
+}
+
+// shouldRefresh is XFetch: the closer to expiry and the slower the load, the more likely.
+func (s *UserService) shouldRefresh(ttl time.Duration) bool {
+	delta := time.Duration(s.loadTime.Load())
+	if delta == 0 {
+		delta = defaultLoadTime
+	}
+	gap := float64(delta) * s.refreshBeta * -math.Log(1-rand.Float64())
+	return gap >= float64(ttl)
+}
+
+// loadByID coalesces concurrent misses of the same user into one db query.
+// Loads are scoped to the organization, so only callers of the same one share them.
+func (s *UserService) loadByID(ctx context.Context, id int) (*domain.User, error) {
+	// Shared by every waiter, so it must not die with the first caller's request
+	loadCtx := context.WithoutCancel(ctx)
+	v, err, shared := s.loads.Do(loadKey(ctx, id), func() (any, error) {
+		return s.load(loadCtx, id, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		slog.DebugContext(ctx, "User load shared", "user_id", id)
+	}
+	return v.(*domain.User).Clone(), nil // each caller gets its own metadata and organizations
+}
+
+func (s *UserService) refreshByID(ctx context.Context, id int) {
+	_, err, _ := s.loads.Do("refresh:"+loadKey(ctx, id), func() (any, error) {
+		return s.load(ctx, id, false)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to refresh user", "user_id", id, "error", err)
+	}
+}
+
+// load reads the user from db and puts it to cache. With a locker only the lock holder
+// goes to db, the others wait for the cache to be filled when wait is set or give up.
+func (s *UserService) load(ctx context.Context, id int, wait bool) (*domain.User, error) {
+	if s.locker != nil {
+		unlock, ok, err := s.locker.TryLock(ctx, "user:"+strconv.Itoa(id), s.lockTTL)
+		switch {
+		case err != nil:
+			// Lock backend is down, better to hit db than to fail
+		case ok:
+			defer unlock(ctx)
+		case !wait:
+			return nil, nil
+		default:
+			if user, err := s.waitForCache(ctx, id); err == nil {
+				return user, nil
+			}
+		}
+	}
+
+	start := time.Now()
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.loadTime.Store(int64(time.Since(start)))
+
+	// Synchronously, replicas waiting on the lock look for it right after unlock
+	if err := s.cache.Set(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "Failed to set user in cache", "user_id", id, "error", err)
+	}
+	return user, nil
+}
+
+func (s *UserService) waitForCache(ctx context.Context, id int) (*domain.User, error) {
+	deadline := time.Now().Add(s.lockTTL)
+	for time.Now().Before(deadline) {
+		time.Sleep(lockPollInterval)
+		user, err := s.cache.GetByID(ctx, id)
+		if err == nil && user != nil && visible(ctx, user) {
+			return user, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func loadKey(ctx context.Context, id int) string {
+	org, _ := domain.OrgID(ctx)
+	return strconv.Itoa(org) + ":" + strconv.Itoa(id)
+}
@@ -0,0 +1,154 @@
+package usersService
+
+import (
+	"context"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loadRepo is the db, GetByID blocks until release is closed.
+type loadRepo struct {
+	UserRepository
+	release chan struct{}
+	queries atomic.Int64
+}
+
+func (r *loadRepo) GetByID(_ context.Context, id int) (*domain.User, error) {
+	r.queries.Add(1)
+	<-r.release
+	return &domain.User{
+		ID:      id,
+		Name:    "John Doe",
+		Email:   "john.doe@example.com",
+		Profile: domain.Profile{Metadata: map[string]any{"plan": "pro", "tags": []any{"a"}}},
+		OrgIDs:  []int{1, 2},
+	}, nil
+}
+
+// memCache starts cold, like right after the hot key expired.
+type memCache struct {
+	UserCache
+	users  sync.Map
+	misses atomic.Int64
+}
+
+func (c *memCache) Set(_ context.Context, user *domain.User) error {
+	c.users.Store(user.ID, *user)
+	return nil
+}
+
+func (c *memCache) GetByID(_ context.Context, id int) (*domain.User, error) {
+	v, ok := c.users.Load(id)
+	if !ok {
+		c.misses.Add(1)
+		return nil, domain.ErrUserNotFound
+	}
+	user := v.(domain.User)
+	return &user, nil
+}
+
+// herd calls GetByID of user 1 from n goroutines per organization at once,
+// the db answers only after all of them missed the cache.
+func herd(tb testing.TB, n int, orgs []int) (queries int64) {
+	repo := &loadRepo{release: make(chan struct{})}
+	cache := &memCache{}
+	s := New(repo, nil, cache, fakeTx{}, nil, nil, nil, time.Minute)
+
+	var wg sync.WaitGroup
+	for _, org := range orgs {
+		ctx := domain.WithRequester(context.Background(), domain.Requester{UserID: 2, OrgID: org})
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.GetByID(ctx, 1); err != nil {
+					tb.Error(err)
+				}
+			}()
+		}
+	}
+
+	total := int64(n * len(orgs))
+	for cache.misses.Load() < total {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // from the cache miss into the shared load
+	close(repo.release)
+	wg.Wait()
+	return repo.queries.Load()
+}
+
+func TestGetByIDCoalescesMisses(t *testing.T) {
+	tests := []struct {
+		name        string
+		n           int
+		orgs        []int
+		wantQueries int64
+	}{
+		{name: "single request", n: 1, orgs: []int{1}, wantQueries: 1},
+		{name: "herd of one organization", n: 1000, orgs: []int{1}, wantQueries: 1},
+		{name: "loads are not shared across organizations", n: 100, orgs: []int{1, 2}, wantQueries: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := herd(t, tt.n, tt.orgs); got != tt.wantQueries {
+				t.Errorf("db queries = %d, want %d", got, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestGetByIDSharedLoadIsCopied(t *testing.T) {
+	const n = 50
+	repo := &loadRepo{release: make(chan struct{})}
+	cache := &memCache{}
+	s := New(repo, nil, cache, fakeTx{}, nil, nil, nil, time.Minute)
+	ctx := domain.WithRequester(context.Background(), domain.Requester{UserID: 2, OrgID: 1})
+
+	users := make([]*domain.User, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := s.GetByID(ctx, 1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			user.Metadata["plan"] = i
+			user.Metadata["tags"].([]any)[0] = i
+			user.OrgIDs[0] = i
+			users[i] = user
+		}()
+	}
+	for cache.misses.Load() < n {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	if q := repo.queries.Load(); q != 1 {
+		t.Fatalf("db queries = %d, want 1, the load must be shared for this test", q)
+	}
+	for i, user := range users {
+		if user == nil {
+			continue
+		}
+		if user.Metadata["plan"] != i || user.Metadata["tags"].([]any)[0] != i || user.OrgIDs[0] != i {
+			t.Errorf("caller %d sees another caller's changes: metadata %v, org ids %v", i, user.Metadata, user.OrgIDs)
+		}
+	}
+}
+
+func BenchmarkGetByIDThunderingHerd(b *testing.B) {
+	var queries int64
+	for range b.N {
+		queries += herd(b, 1000, []int{1})
+	}
+	b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+}
@@ -2,32 +2,89 @@ package usersService
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/Arh0rn/test-task1/internal/domain"
+	"github.com/Arh0rn/test-task1/internal/tracing"
 	"github.com/Arh0rn/test-task1/pkg/jwtoken"
 	"github.com/go-playground/validator/v10"
+	"golang.org/x/sync/singleflight"
 	"log/slog"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
 type UserRepository interface {
 	Create(context.Context, *domain.SignUpInput) (*domain.User, error)
-	GetAll(context.Context) ([]*domain.User, error)
+	GetAll(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, error)
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	Search(ctx context.Context, query *domain.UserSearchQuery) (*domain.UserSearchResult, error)
 	GetByID(ctx context.Context, id int) (*domain.User, error)
 	UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) (*domain.UserUpdate, error)
-	DeleteByID(ctx context.Context, id int) error
+	UpdateAvatarURL(ctx context.Context, id int, url string) error
+	DeleteByID(ctx context.Context, id int) (deleted bool, err error)
+	// ImportBatch returns users in input order, nil for ones whose email is taken.
+	ImportBatch(ctx context.Context, users []*domain.SignUpInput) ([]*domain.User, error)
+	Export(ctx context.Context, fn func(*domain.User) error) error
+	GetAccount(ctx context.Context, id int) (*domain.Account, error)
+	ScheduleDeletion(ctx context.Context, id int, at time.Time) error
+	CancelDeletion(ctx context.Context, id int) (bool, error)
+	LockDueDeletion(ctx context.Context) (int, error)
+	Purge(ctx context.Context, id int) error
+}
+
+// OrgRepository is the part of organizations users depend on: sign up creates one,
+// login picks one, the last owner can't leave and users of several can't be edited by their admins.
+type OrgRepository interface {
+	Create(ctx context.Context, name string) (*domain.Organization, error)
+	AddMember(ctx context.Context, orgID, userID int, role domain.Role) (*domain.Membership, error)
+	ListByUser(ctx context.Context, userID int) ([]*domain.Membership, error)
+	GetMembership(ctx context.Context, orgID, userID int) (*domain.Membership, error)
+	LockOwners(ctx context.Context, orgID int) ([]int, error)
+	CountMembers(ctx context.Context, orgID int) (int, error)
+	CountUserOrgs(ctx context.Context, userID int) (int, error)
+}
+
+// PermissionInvalidator drops cached permissions of users that left an organization.
+type PermissionInvalidator interface {
+	InvalidatePermissions(ctx context.Context, orgID int, userIDs ...int)
+}
+
+// Sessions issues tokens bound to a session, so the user can revoke them.
+type Sessions interface {
+	Start(ctx context.Context, userID int, email string, orgID int) (*domain.Tokens, error)
+	RevokeAll(ctx context.Context, userID int) error
+	History(ctx context.Context, userID int) ([]*domain.Session, error)
 }
 
 type UserCache interface {
 	Set(context.Context, *domain.User) error
-	GetAll(context.Context) ([]*domain.User, error)
-	SetAll(context.Context, []*domain.User) error
+	GetList(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, int64, error)
+	SetList(ctx context.Context, gen int64, query *domain.UserListQuery, list *domain.UserList) error
+	InvalidateLists(ctx context.Context) error
 	GetByID(ctx context.Context, id int) (*domain.User, error)
 	UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) error
 	DeleteByID(ctx context.Context, id int) error
 }
 
+// TxManager runs fn in a db transaction, repositories called with the ctx passed to fn join it.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// MetadataValidator checks custom user attributes against the deployment's schema.
+type MetadataValidator interface {
+	Validate(metadata map[string]any) error
+	Schema() json.RawMessage
+}
+
+// LoginRecorder counts login attempts by result.
+type LoginRecorder interface {
+	Login(result string)
+}
+
 type Hasher interface {
 	Hash(password string) (string, error)
 	Verify(password, hashed string) bool
@@ -35,18 +92,49 @@ type Hasher interface {
 
 type UserService struct {
 	repo  UserRepository
+	orgs  OrgRepository
 	cache UserCache
+	tx    TxManager
 
 	hasher    Hasher
 	validator *validator.Validate
+	metadata  MetadataValidator     // optional
+	perms     PermissionInvalidator // optional
+	sessions  Sessions              // optional, plain access tokens without it
+	logins    LoginRecorder         // optional
 
 	jwtSecret []byte
 	tokenTTL  time.Duration
+
+	loads       singleflight.Group
+	loadTime    atomic.Int64 // last db load duration, ns
+	locker      Locker       // optional
+	lockTTL     time.Duration
+	refreshBeta float64
+
+	importJobs      ImportJobRepository // optional, no background imports without it
+	importBatchSize int
+	importRetention time.Duration
+
+	blobs      BlobStore // optional
+	avatarOpts AvatarOptions
+
+	openSignUp bool
+	invites    InvitationRepository // optional
+	notifier   Notifier
+	inviteOpts InvitationOptions
+	inviteKey  []byte
+
+	audit            AuditLog // optional, impersonation is off without it
+	impersonationTTL time.Duration
+	deletionGrace    time.Duration
 }
 
 func New(
 	repo UserRepository,
+	orgs OrgRepository,
 	cache UserCache,
+	tx TxManager,
 	hasher Hasher,
 	validator *validator.Validate,
 	jwts []byte,
@@ -54,15 +142,54 @@ func New(
 ) *UserService {
 	return &UserService{
 		repo:      repo,
+		orgs:      orgs,
 		cache:     cache,
+		tx:        tx,
 		hasher:    hasher,
 		validator: validator,
 		jwtSecret: jwts,
 		tokenTTL:  tttl,
+
+		importBatchSize: defaultImportBatchSize,
+		importRetention: defaultImportRetention,
+		openSignUp:      true,
+		deletionGrace:   defaultDeletionGrace,
 	}
 }
 
+// WithMetadataValidator enables checking of user metadata, without it any object is accepted.
+func (s *UserService) WithMetadataValidator(v MetadataValidator) *UserService {
+	s.metadata = v
+	return s
+}
+
+// WithPermissionInvalidator is needed when permissions are cached.
+func (s *UserService) WithPermissionInvalidator(p PermissionInvalidator) *UserService {
+	s.perms = p
+	return s
+}
+
+func (s *UserService) WithSessions(sessions Sessions) *UserService {
+	s.sessions = sessions
+	return s
+}
+
+func (s *UserService) WithLoginRecorder(r LoginRecorder) *UserService {
+	s.logins = r
+	return s
+}
+
+// SignUp creates the user together with their own organization, they are its owner.
 func (s *UserService) SignUp(ctx context.Context, userInput *domain.SignUpInput) (*domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.SignUp")
+	defer span.End()
+
+	if !s.openSignUp {
+		return nil, domain.ErrSignUpDisabled
+	}
+	if err := s.validateMetadata(userInput.Metadata); err != nil {
+		return nil, err
+	}
 
 	hashedPassword, err := s.hasher.Hash(userInput.Password)
 	if err != nil {
@@ -70,10 +197,28 @@ func (s *UserService) SignUp(ctx context.Context, userInput *domain.SignUpInput)
 	}
 
 	userInput.Password = hashedPassword
-	user, err := s.repo.Create(ctx, userInput)
+	// the user has no organization yet, nothing is visible to it
+	ctx = domain.WithUnscoped(ctx)
+	var user *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err = s.repo.Create(ctx, userInput)
+		if err != nil {
+			return err
+		}
+		org, err := s.orgs.Create(ctx, user.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := s.orgs.AddMember(ctx, org.ID, user.ID, domain.RoleOwner); err != nil {
+			return err
+		}
+		user.OrgIDs = []int{org.ID}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	s.invalidateLists(ctx)
 
 	go func() {
 		err = s.cache.Set(context.Background(), user)
@@ -82,71 +227,152 @@ func (s *UserService) SignUp(ctx context.Context, userInput *domain.SignUpInput)
 	return user, nil
 }
 
-func (s *UserService) Login(ctx context.Context, email, password string) (string, error) {
+// Login issues tokens for orgID, 0 picks the organization the user joined first.
+func (s *UserService) Login(ctx context.Context, email, password string, orgID int) (*domain.Tokens, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	defer span.End()
+
+	tokens, err := s.login(ctx, email, password, orgID)
+	if s.logins != nil {
+		s.logins.Login(loginResult(err))
+	}
+	return tokens, err
+}
+
+func loginResult(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.Is(err, domain.ErrNotMember):
+		return "not_member"
+	default:
+		return "error"
+	}
+}
+
+func (s *UserService) login(ctx context.Context, email, password string, orgID int) (*domain.Tokens, error) {
+	// emails are global, the organization is known only after the lookup
+	ctx = domain.WithUnscoped(ctx)
 	user, err := s.repo.GetByEmail(ctx, email)
 	if errors.Is(err, domain.ErrUserNotFound) {
-		return "", domain.ErrInvalidCredentials
+		return nil, domain.ErrInvalidCredentials
 
 	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	valid := s.hasher.Verify(password, user.Password)
 	if !valid {
-		return "", domain.ErrInvalidCredentials
+		return nil, domain.ErrInvalidCredentials
 	}
-	token, err := jwtoken.GenerateToken(user.ID, user.Email, s.jwtSecret, s.tokenTTL)
+
+	// logging in during the grace period keeps the account
+	cancelled, err := s.repo.CancelDeletion(ctx, user.ID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if cancelled {
+		slog.InfoContext(ctx, "Account deletion cancelled by login", "user_id", user.ID)
 	}
 
-	slog.DebugContext(ctx, "Token generated", "token", token)
-	return token, nil
-}
+	memberships, err := s.orgs.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case orgID != 0:
+		if !slices.ContainsFunc(memberships, func(m *domain.Membership) bool { return m.Org.ID == orgID }) {
+			return nil, domain.ErrNotMember
+		}
+	case len(memberships) > 0:
+		orgID = memberships[0].Org.ID
+	}
 
-func (s *UserService) GetAll(ctx context.Context) ([]*domain.User, error) {
-	//TODO: add pagination if needed
-	//users, err := s.cache.GetAll(ctx)
-	//if err == nil && len(users) > 0 {
-	//	return users, nil
-	//}
+	return s.issueTokens(ctx, user, orgID)
+}
 
-	users, err := s.repo.GetAll(ctx)
+func (s *UserService) issueTokens(ctx context.Context, user *domain.User, orgID int) (*domain.Tokens, error) {
+	if s.sessions != nil {
+		return s.sessions.Start(ctx, user.ID, user.Email, orgID)
+	}
+	claims := jwtoken.Claims{UserID: user.ID, Email: user.Email, OrgID: orgID}
+	token, err := jwtoken.GenerateToken(claims, s.jwtSecret, s.tokenTTL)
 	if err != nil {
 		return nil, err
 	}
-
-	go func() {
-		err = s.cache.SetAll(context.Background(), users)
-	}()
-	return users, nil
+	return &domain.Tokens{AccessToken: token}, nil
 }
 
-func (s *UserService) GetByID(ctx context.Context, id int) (*domain.User, error) {
-	user, err := s.cache.GetByID(ctx, id)
-	if err == nil && user != nil {
-		slog.DebugContext(ctx, "User found in cache", "user", user)
-		return user, nil
+func (s *UserService) GetAll(ctx context.Context, query *domain.UserListQuery) (*domain.UserList, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAll")
+	defer span.End()
+
+	list, gen, cacheErr := s.cache.GetList(ctx, query)
+	if cacheErr == nil && list != nil {
+		return list, nil
 	}
 
-	user, err = s.repo.GetByID(ctx, id)
+	list, err := s.repo.GetAll(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		err = s.cache.Set(context.Background(), user)
-	}()
+	if cacheErr == nil { // without generation we can't tell if the page is current
+		go func() {
+			_ = s.cache.SetList(context.WithoutCancel(ctx), gen, query, list)
+		}()
+	}
+	return list, nil
+}
 
-	return user, nil
+// Search is not cached, results depend on every user and change with any write.
+func (s *UserService) Search(ctx context.Context, query *domain.UserSearchQuery) (*domain.UserSearchResult, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Search")
+	defer span.End()
+
+	return s.repo.Search(ctx, query)
+}
+
+func (s *UserService) GetByID(ctx context.Context, id int) (*domain.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetByID")
+	defer span.End()
+
+	if _, ok := domain.OrgID(ctx); !ok {
+		return nil, domain.ErrNoOrg
+	}
+	user, err := s.getCachedByID(ctx, id)
+	// Not a member per cache may be a stale entry, db decides then
+	if err == nil && user != nil && visible(ctx, user) {
+		slog.DebugContext(ctx, "User found in cache", "user", user)
+		return user, nil
+	}
+
+	return s.loadByID(ctx, id)
 }
 
-func (s *UserService) UpdateByID(ctx context.Context, user *domain.UserUpdate, id int) (*domain.UserUpdate, error) {
-	user, err := s.repo.UpdateByID(ctx, user, id)
+func (s *UserService) UpdateByID(ctx context.Context, update *domain.UserUpdate, id int) (*domain.UserUpdate, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateByID")
+	defer span.End()
+
+	if err := s.validateMetadata(update.Metadata); err != nil {
+		return nil, err
+	}
+	var user *domain.UserUpdate
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkProfileOwner(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		user, err = s.repo.UpdateByID(ctx, update, id)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	s.invalidateLists(ctx)
 	//Strange decision, but otherwise i need to make double request to db
 	//Or add extra logic to the repo.UpdateByID method that allows to decide when to get the user from db
 	go func() {
@@ -155,7 +381,7 @@ func (s *UserService) UpdateByID(ctx context.Context, user *domain.UserUpdate, i
 			slog.DebugContext(ctx, "User updated in cache", "user", user)
 			return
 		}
-		dbUser, err := s.repo.GetByID(context.Background(), id)
+		dbUser, err := s.repo.GetByID(context.WithoutCancel(ctx), id)
 		if err != nil {
 			slog.ErrorContext(ctx, "Failed to get user by ID", "id", id)
 			return
@@ -169,18 +395,116 @@ func (s *UserService) UpdateByID(ctx context.Context, user *domain.UserUpdate, i
 	return user, nil
 }
 
+// DeleteByID removes the user from the current organization,
+// users that are left without any organization are deleted for good.
 func (s *UserService) DeleteByID(ctx context.Context, id int) error {
-	err := s.repo.DeleteByID(ctx, id)
+	ctx, span := tracing.Start(ctx, "UserService.DeleteByID")
+	defer span.End()
+
+	org, ok := domain.OrgID(ctx)
+	if !ok {
+		return domain.ErrNoOrg
+	}
+	if r, _ := domain.RequesterFrom(ctx); r.Impersonated() && r.UserID == id {
+		return domain.ErrImpersonating
+	}
+	var deleted bool
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkLastOwner(ctx, org, id); err != nil {
+			return err
+		}
+		var err error
+		deleted, err = s.repo.DeleteByID(ctx, id)
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	s.invalidateLists(ctx)
+	if s.perms != nil {
+		s.perms.InvalidatePermissions(ctx, org, id)
+	}
 
 	go func() {
 		err = s.cache.DeleteByID(context.Background(), id)
 	}()
+	if deleted {
+		go s.deleteAvatars(context.WithoutCancel(ctx), id)
+	}
 	return nil
 }
 
+// checkProfileOwner lets admins edit only users that are in their organization alone.
+// Email and name are shared by all organizations of the user, an admin of one changing
+// the email could reset the password and take the account over in the others.
+func (s *UserService) checkProfileOwner(ctx context.Context, id int) error {
+	if r, _ := domain.RequesterFrom(ctx); r.UserID == id {
+		return nil
+	}
+	orgs, err := s.orgs.CountUserOrgs(ctx, id)
+	if err != nil {
+		return err
+	}
+	if orgs > 1 {
+		return domain.ErrSharedUser
+	}
+	return nil
+}
+
+// checkLastOwner stops the only owner from leaving while the organization has other members.
+func (s *UserService) checkLastOwner(ctx context.Context, org, userID int) error {
+	m, err := s.orgs.GetMembership(ctx, org, userID)
+	if errors.Is(err, domain.ErrNotMember) {
+		return domain.ErrUserNotFound
+	}
+	if err != nil || m.Role != domain.RoleOwner {
+		return err
+	}
+	owners, err := s.orgs.LockOwners(ctx, org)
+	if err != nil || len(owners) > 1 {
+		return err
+	}
+	members, err := s.orgs.CountMembers(ctx, org)
+	if err != nil {
+		return err
+	}
+	if members > 1 {
+		return domain.ErrLastOwner
+	}
+	return nil
+}
+
+// visible tells if the user is a member of the caller's organization.
+func visible(ctx context.Context, user *domain.User) bool {
+	org, _ := domain.OrgID(ctx)
+	return slices.Contains(user.OrgIDs, org)
+}
+
+// invalidateLists is synchronous so the caller's next list request already sees the change.
+func (s *UserService) invalidateLists(ctx context.Context) {
+	if err := s.cache.InvalidateLists(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to invalidate user lists", "error", err)
+	}
+}
+
+func (s *UserService) validateMetadata(metadata map[string]any) error {
+	if s.metadata == nil {
+		return nil
+	}
+	if err := s.metadata.Validate(metadata); err != nil {
+		return fmt.Errorf("%w: %s", domain.ErrInvalidMetadata, err)
+	}
+	return nil
+}
+
+// MetadataSchema is the JSON Schema user metadata must match, nil when any object is accepted.
+func (s *UserService) MetadataSchema() json.RawMessage {
+	if s.metadata == nil {
+		return nil
+	}
+	return s.metadata.Schema()
+}
+
 func (s *UserService) GetValidator() *validator.Validate {
 	return s.validator
 }
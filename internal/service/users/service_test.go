@@ -0,0 +1,84 @@
+package usersService
+
+import (
+	"context"
+	"errors"
+	"github.com/Arh0rn/test-task1/internal/domain"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeRepo embeds the interface, tests implement what they call and the rest panics.
+type fakeRepo struct {
+	UserRepository
+	updates atomic.Int32
+}
+
+func (r *fakeRepo) UpdateByID(_ context.Context, user *domain.UserUpdate, _ int) (*domain.UserUpdate, error) {
+	r.updates.Add(1)
+	return user, nil
+}
+
+type fakeOrgs struct {
+	OrgRepository
+	userOrgs map[int]int
+}
+
+func (o *fakeOrgs) CountUserOrgs(_ context.Context, userID int) (int, error) {
+	return o.userOrgs[userID], nil
+}
+
+type fakeCache struct {
+	UserCache
+}
+
+func (fakeCache) InvalidateLists(context.Context) error                     { return nil }
+func (fakeCache) UpdateByID(context.Context, *domain.UserUpdate, int) error { return nil }
+func (fakeCache) Set(context.Context, *domain.User) error                   { return nil }
+
+type fakeTx struct{}
+
+func (fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestUpdateByIDSharedUser(t *testing.T) {
+	const (
+		orgA   = 1
+		admin  = 10
+		shared = 20 // member of orgA and orgB
+		local  = 30 // member of orgA only
+	)
+	orgs := &fakeOrgs{userOrgs: map[int]int{admin: 1, shared: 2, local: 1}}
+
+	tests := []struct {
+		name      string
+		requester int
+		target    int
+		wantErr   error
+	}{
+		{name: "admin of org A on user shared with org B", requester: admin, target: shared, wantErr: domain.ErrSharedUser},
+		{name: "admin of org A on user of org A only", requester: admin, target: local},
+		{name: "shared user on themselves", requester: shared, target: shared},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{}
+			s := New(repo, orgs, fakeCache{}, fakeTx{}, nil, nil, nil, 0)
+			ctx := domain.WithRequester(context.Background(), domain.Requester{UserID: tt.requester, OrgID: orgA})
+
+			update := &domain.UserUpdate{Name: "Mallory", Email: "mallory@example.com"}
+			_, err := s.UpdateByID(ctx, update, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateByID() error = %v, want %v", err, tt.wantErr)
+			}
+			wantUpdates := int32(1)
+			if tt.wantErr != nil {
+				wantUpdates = 0
+			}
+			if got := repo.updates.Load(); got != wantUpdates {
+				t.Errorf("repo.UpdateByID called %d times, want %d", got, wantUpdates)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+DROP TRIGGER users_set_updated_at ON users;
+DROP FUNCTION set_updated_at();
+
+DROP INDEX users_email_lower_key;
+
+ALTER TABLE users
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at,
+    ADD CONSTRAINT users_email_key UNIQUE (email),
+    ALTER COLUMN password DROP NOT NULL,
+    ALTER COLUMN email DROP NOT NULL,
+    ALTER COLUMN name DROP NOT NULL;
@@ -0,0 +1,55 @@
+-- Report rows the new constraints would reject and stop, the error DETAIL lists them.
+-- Resolve them by hand and run the migration again.
+DO $$
+DECLARE
+    report text;
+BEGIN
+    SELECT string_agg(format('%s (ids %s)', d.email, d.ids), '; ' ORDER BY d.email)
+    INTO report
+    FROM (
+        SELECT lower(email) AS email, array_agg(id ORDER BY id)::text AS ids
+        FROM users
+        WHERE email IS NOT NULL
+        GROUP BY lower(email)
+        HAVING count(*) > 1
+    ) d;
+
+    IF report IS NOT NULL THEN
+        RAISE EXCEPTION 'users table has emails that differ only by case'
+            USING DETAIL = report,
+                  HINT = 'merge or rename these users before making email case-insensitive unique';
+    END IF;
+
+    SELECT string_agg(id::text, ', ' ORDER BY id)
+    INTO report
+    FROM users
+    WHERE name IS NULL OR email IS NULL OR password IS NULL;
+
+    IF report IS NOT NULL THEN
+        RAISE EXCEPTION 'users table has rows with NULL name, email or password'
+            USING DETAIL = 'ids: ' || report;
+    END IF;
+END
+$$;
+
+ALTER TABLE users
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN email SET NOT NULL,
+    ALTER COLUMN password SET NOT NULL,
+    DROP CONSTRAINT users_email_key,
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email));
+
+CREATE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_set_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,4 @@
+DROP INDEX users_email_trgm_idx;
+DROP INDEX users_name_trgm_idx;
+
+-- pg_trgm is left installed, other schemas may use it
@@ -0,0 +1,7 @@
+-- Trigram indexes for GET /users/search, they serve both LIKE '%q%' and fuzzy (<%) matches.
+-- Needs a role allowed to create the extension, on managed postgres pg_trgm is usually whitelisted.
+-- Plain CREATE INDEX: migrations run in a transaction, so CONCURRENTLY is not possible.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_name_trgm_idx ON users USING gin (lower(name) gin_trgm_ops);
+CREATE INDEX users_email_trgm_idx ON users USING gin (lower(email) gin_trgm_ops);
@@ -0,0 +1,7 @@
+ALTER TABLE users
+    DROP COLUMN metadata,
+    DROP COLUMN phone,
+    DROP COLUMN timezone,
+    DROP COLUMN locale,
+    DROP COLUMN avatar_url,
+    DROP COLUMN display_name;
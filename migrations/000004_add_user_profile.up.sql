@@ -0,0 +1,10 @@
+-- Profile fields are NOT NULL with empty defaults, empty string means "not set".
+ALTER TABLE users
+    ADD COLUMN display_name text NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url text NOT NULL DEFAULT '',
+    ADD COLUMN locale text NOT NULL DEFAULT '',
+    ADD COLUMN timezone text NOT NULL DEFAULT '',
+    ADD COLUMN phone text NOT NULL DEFAULT '',
+    -- custom attributes, shape is checked by the app against profile.metadata-schema
+    ADD COLUMN metadata jsonb NOT NULL DEFAULT '{}'
+        CONSTRAINT users_metadata_is_object CHECK (jsonb_typeof(metadata) = 'object');
@@ -0,0 +1,6 @@
+DROP POLICY users_org_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+DROP FUNCTION app_org_id();
+DROP TABLE memberships;
+DROP TABLE organizations;
@@ -0,0 +1,50 @@
+-- Users are global (one login), organizations see the users that are their members.
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE memberships (
+    org_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role text NOT NULL DEFAULT 'member'
+        CONSTRAINT memberships_role_check CHECK (role IN ('owner', 'admin', 'member')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX memberships_user_id_idx ON memberships (user_id);
+
+-- Existing users go to one organization, the first of them owns it.
+DO $$
+DECLARE
+    default_org integer;
+BEGIN
+    IF EXISTS (SELECT 1 FROM users) THEN
+        INSERT INTO organizations (name) VALUES ('Default') RETURNING id INTO default_org;
+        INSERT INTO memberships (org_id, user_id, role)
+        SELECT default_org, id, CASE WHEN id = (SELECT min(id) FROM users) THEN 'owner' ELSE 'member' END
+        FROM users;
+    END IF;
+END
+$$;
+
+-- Organization of the current request, the app sets app.org_id on every connection it takes
+-- from the pool. Empty means not scoped: sign up and login, which look users up globally.
+CREATE FUNCTION app_org_id() RETURNS integer
+    LANGUAGE sql STABLE
+AS $$ SELECT nullif(current_setting('app.org_id', true), '')::integer $$;
+
+-- Backs up the org filter of the repository. Users without memberships are visible too:
+-- that is only a user being created or deleted, within that transaction.
+-- Superusers and roles with BYPASSRLS are not subject to it, run the app as a plain role.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY users_org_isolation ON users
+    USING (
+        app_org_id() IS NULL
+        OR EXISTS (SELECT 1 FROM memberships m WHERE m.user_id = users.id AND m.org_id = app_org_id())
+        OR NOT EXISTS (SELECT 1 FROM memberships m WHERE m.user_id = users.id)
+    );
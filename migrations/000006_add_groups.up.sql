@@ -0,0 +1,26 @@
+-- Groups live in one organization and give their members extra permissions,
+-- permission names are checked by the app.
+CREATE TABLE groups (
+    id SERIAL PRIMARY KEY,
+    org_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name text NOT NULL,
+    permissions text[] NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT groups_org_name_key UNIQUE (org_id, name),
+    CONSTRAINT groups_id_org_key UNIQUE (id, org_id)
+);
+
+-- Both foreign keys carry org_id: a group member is always a member of the group's organization,
+-- and leaving the organization removes them from its groups.
+CREATE TABLE group_members (
+    group_id integer NOT NULL,
+    org_id integer NOT NULL,
+    user_id integer NOT NULL,
+    PRIMARY KEY (group_id, user_id),
+    CONSTRAINT group_members_group_fkey FOREIGN KEY (group_id, org_id)
+        REFERENCES groups (id, org_id) ON DELETE CASCADE,
+    CONSTRAINT group_members_membership_fkey FOREIGN KEY (org_id, user_id)
+        REFERENCES memberships (org_id, user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX group_members_org_user_idx ON group_members (org_id, user_id);
@@ -0,0 +1,13 @@
+-- Only the id goes into invite tokens, they are signed by the app, so the table holds no secrets.
+CREATE TABLE invitations (
+    id uuid PRIMARY KEY,
+    org_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    email text NOT NULL,
+    role text NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    invited_by integer REFERENCES users (id) ON DELETE SET NULL,
+    expires_at timestamptz NOT NULL,
+    accepted_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX invitations_pending_idx ON invitations (org_id, created_at) WHERE accepted_at IS NULL;
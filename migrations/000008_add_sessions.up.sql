@@ -0,0 +1,15 @@
+-- Refresh tokens are "<id>.<secret>", only sha256 of the current secret is kept.
+CREATE TABLE sessions (
+    id uuid PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    org_id integer REFERENCES organizations (id) ON DELETE SET NULL,
+    refresh_hash bytea NOT NULL,
+    user_agent text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    last_seen_at timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz
+);
+
+CREATE INDEX sessions_user_idx ON sessions (user_id);
@@ -0,0 +1,13 @@
+-- Deleting a user keeps their events, references to them are cleared.
+CREATE TABLE audit_events (
+    id bigserial PRIMARY KEY,
+    org_id integer REFERENCES organizations (id) ON DELETE SET NULL,
+    actor_id integer REFERENCES users (id) ON DELETE SET NULL,
+    subject_id integer REFERENCES users (id) ON DELETE SET NULL,
+    action text NOT NULL,
+    details jsonb NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_actor_idx ON audit_events (actor_id);
+CREATE INDEX audit_events_subject_idx ON audit_events (subject_id);
@@ -0,0 +1,4 @@
+-- Set by DELETE /me, the account is purged after it unless the user logs in again.
+ALTER TABLE users ADD COLUMN delete_after timestamptz;
+
+CREATE INDEX users_delete_after_idx ON users (delete_after) WHERE delete_after IS NOT NULL;
@@ -0,0 +1,28 @@
+DROP POLICY memberships_unscoped ON memberships;
+DROP POLICY memberships_org_isolation ON memberships;
+ALTER TABLE memberships NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE memberships DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY users_unscoped ON users;
+DROP POLICY users_org_isolation ON users;
+CREATE POLICY users_org_isolation ON users
+    USING (
+        app_org_id() IS NULL
+        OR EXISTS (SELECT 1 FROM memberships m WHERE m.user_id = users.id AND m.org_id = app_org_id())
+        OR NOT EXISTS (SELECT 1 FROM memberships m WHERE m.user_id = users.id)
+    );
+
+DROP FUNCTION app_membership_count(integer);
+DROP FUNCTION app_user_org_ids();
+DROP FUNCTION app_user_id();
+
+-- The role is cluster wide and may be used by other databases, only its grants here go
+DO $$
+BEGIN
+    EXECUTE format('ALTER DEFAULT PRIVILEGES IN SCHEMA %I REVOKE ALL ON SEQUENCES FROM app_unscoped', current_schema());
+    EXECUTE format('ALTER DEFAULT PRIVILEGES IN SCHEMA %I REVOKE ALL ON TABLES FROM app_unscoped', current_schema());
+    EXECUTE format('REVOKE ALL ON ALL SEQUENCES IN SCHEMA %I FROM app_unscoped', current_schema());
+    EXECUTE format('REVOKE ALL ON ALL TABLES IN SCHEMA %I FROM app_unscoped', current_schema());
+    EXECUTE format('REVOKE USAGE ON SCHEMA %I FROM app_unscoped', current_schema());
+END
+$$;
@@ -0,0 +1,74 @@
+-- Nothing is visible without an organization anymore. Paths that need every tenant (sign up,
+-- login, invitation accept, token refresh, account purge) switch to the app_unscoped role,
+-- the app does it per connection (see tenant.Scope). The app role must be able to SET ROLE to it
+-- and must not be a superuser or have BYPASSRLS, those skip all of this.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'app_unscoped') THEN
+        CREATE ROLE app_unscoped NOLOGIN;
+    END IF;
+END
+$$;
+
+-- Migrations run as the app role normally, grant it to the app role by hand otherwise.
+GRANT app_unscoped TO CURRENT_USER;
+
+DO $$
+BEGIN
+    EXECUTE format('GRANT USAGE ON SCHEMA %I TO app_unscoped', current_schema());
+    EXECUTE format('GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA %I TO app_unscoped', current_schema());
+    EXECUTE format('GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA %I TO app_unscoped', current_schema());
+    EXECUTE format('ALTER DEFAULT PRIVILEGES IN SCHEMA %I GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO app_unscoped', current_schema());
+    EXECUTE format('ALTER DEFAULT PRIVILEGES IN SCHEMA %I GRANT USAGE, SELECT ON SEQUENCES TO app_unscoped', current_schema());
+END
+$$;
+
+-- The requester, set with app.org_id. A user always sees their own row and memberships.
+CREATE FUNCTION app_user_id() RETURNS integer
+    LANGUAGE sql STABLE
+AS $$ SELECT nullif(current_setting('app.user_id', true), '')::integer $$;
+
+-- Policies can't read memberships through memberships policies (that recurses), these helpers run
+-- as app_unscoped and see all of them. They answer only about one user, never list other tenants.
+CREATE FUNCTION app_user_org_ids() RETURNS SETOF integer
+    LANGUAGE sql STABLE SECURITY DEFINER SET search_path FROM CURRENT
+AS $$ SELECT org_id FROM memberships WHERE user_id = app_user_id() $$;
+
+CREATE FUNCTION app_membership_count(uid integer) RETURNS integer
+    LANGUAGE sql STABLE SECURITY DEFINER SET search_path FROM CURRENT
+AS $$ SELECT count(*)::integer FROM memberships WHERE user_id = uid $$;
+
+-- Changing the owner needs CREATE on the schema for the new owner, only for this moment
+DO $$
+BEGIN
+    EXECUTE format('GRANT CREATE ON SCHEMA %I TO app_unscoped', current_schema());
+    ALTER FUNCTION app_user_org_ids() OWNER TO app_unscoped;
+    ALTER FUNCTION app_membership_count(integer) OWNER TO app_unscoped;
+    EXECUTE format('REVOKE CREATE ON SCHEMA %I FROM app_unscoped', current_schema());
+END
+$$;
+
+-- Users of the current organization, the requester, and users without memberships: those exist
+-- only within the transaction creating (import) or deleting them.
+DROP POLICY users_org_isolation ON users;
+CREATE POLICY users_org_isolation ON users
+    USING (
+        id = app_user_id()
+        OR app_org_id() IS NOT NULL AND (
+            EXISTS (SELECT 1 FROM memberships m WHERE m.user_id = users.id AND m.org_id = app_org_id())
+            OR app_membership_count(users.id) = 0
+        )
+    );
+CREATE POLICY users_unscoped ON users TO app_unscoped
+    USING (true) WITH CHECK (true);
+
+-- Members of the current organization and of the requester's organizations. New rows also for
+-- the requester themselves, creating an organization makes them its owner.
+ALTER TABLE memberships ENABLE ROW LEVEL SECURITY;
+ALTER TABLE memberships FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY memberships_org_isolation ON memberships
+    USING (org_id = app_org_id() OR org_id IN (SELECT app_user_org_ids()))
+    WITH CHECK (org_id = app_org_id() OR org_id IN (SELECT app_user_org_ids()) OR user_id = app_user_id());
+CREATE POLICY memberships_unscoped ON memberships TO app_unscoped
+    USING (true) WITH CHECK (true);
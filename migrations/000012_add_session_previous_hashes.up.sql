@@ -0,0 +1,3 @@
+-- Hashes of the last refresh secrets the session rotated away from. Presenting one of them means
+-- the token leaked and the session is revoked, any other wrong secret is just rejected.
+ALTER TABLE sessions ADD COLUMN previous_hashes bytea[] NOT NULL DEFAULT '{}';
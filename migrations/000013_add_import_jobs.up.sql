@@ -0,0 +1,14 @@
+-- Background imports, any instance can report them. report is the progress while running.
+-- updated_at moves with every batch, a running job that stopped moving lost its instance.
+CREATE TABLE import_jobs (
+    id uuid PRIMARY KEY,
+    org_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    status text NOT NULL,
+    report jsonb NOT NULL DEFAULT '{}',
+    error text NOT NULL DEFAULT '',
+    started_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    finished_at timestamptz
+);
+
+CREATE INDEX import_jobs_updated_at_idx ON import_jobs (updated_at);
@@ -0,0 +1,7 @@
+// Package migrations embeds sql migrations into the binary, see internal/migrator.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
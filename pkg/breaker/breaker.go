@@ -0,0 +1,128 @@
+package breaker
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker opens after threshold consecutive failures, stays open for openTimeout
+// and then lets a single probe through. Probe success closes it, failure opens again.
+type Breaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	onChange func(from, to State) // optional
+}
+
+func New(name string, threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// OnStateChange sets fn to be called on every transition, e.g. to export the state as a metric.
+// It runs with the breaker locked, keep it short and don't call the breaker from it.
+// Set it before the breaker is used.
+func (b *Breaker) OnStateChange(fn func(from, to State)) *Breaker {
+	b.onChange = fn
+	return b
+}
+
+// Allow must be followed by Success, Failure or Release when it returns nil.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return ErrOpen
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return ErrOpen // one probe at a time
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != Closed {
+		b.setState(Closed)
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.setState(Open)
+	}
+}
+
+// Release ends the call without judging the dependency, e.g. the caller went away.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) setState(s State) {
+	slog.Warn("Circuit breaker state changed", "breaker", b.name, "from", b.state.String(), "to", s.String())
+	if b.onChange != nil {
+		b.onChange(b.state, s)
+	}
+	b.state = s
+}
@@ -0,0 +1,138 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type op int
+
+const (
+	allow op = iota
+	success
+	failure
+	release
+)
+
+type step struct {
+	op        op
+	wantErr   error // of allow
+	wantState State
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   int
+		openTimeout time.Duration
+		steps       []step
+	}{
+		{
+			name: "stays closed below threshold", threshold: 3, openTimeout: time.Hour,
+			steps: []step{
+				{op: allow, wantState: Closed},
+				{op: failure, wantState: Closed},
+				{op: allow, wantState: Closed},
+				{op: failure, wantState: Closed},
+			},
+		},
+		{
+			name: "success resets failures", threshold: 2, openTimeout: time.Hour,
+			steps: []step{
+				{op: failure, wantState: Closed},
+				{op: success, wantState: Closed},
+				{op: failure, wantState: Closed},
+			},
+		},
+		{
+			name: "opens at threshold and rejects", threshold: 2, openTimeout: time.Hour,
+			steps: []step{
+				{op: failure, wantState: Closed},
+				{op: failure, wantState: Open},
+				{op: allow, wantErr: ErrOpen, wantState: Open},
+			},
+		},
+		{
+			name: "half-open lets one probe through", threshold: 1, openTimeout: 0,
+			steps: []step{
+				{op: failure, wantState: Open},
+				{op: allow, wantState: HalfOpen},
+				{op: allow, wantErr: ErrOpen, wantState: HalfOpen},
+			},
+		},
+		{
+			name: "probe success closes", threshold: 1, openTimeout: 0,
+			steps: []step{
+				{op: failure, wantState: Open},
+				{op: allow, wantState: HalfOpen},
+				{op: success, wantState: Closed},
+				{op: allow, wantState: Closed},
+			},
+		},
+		{
+			name: "probe failure opens again", threshold: 3, openTimeout: 0,
+			steps: []step{
+				{op: failure, wantState: Closed},
+				{op: failure, wantState: Closed},
+				{op: failure, wantState: Open},
+				{op: allow, wantState: HalfOpen},
+				{op: failure, wantState: Open},
+			},
+		},
+		{
+			name: "released probe lets the next one through", threshold: 1, openTimeout: 0,
+			steps: []step{
+				{op: failure, wantState: Open},
+				{op: allow, wantState: HalfOpen},
+				{op: release, wantState: HalfOpen},
+				{op: allow, wantState: HalfOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New("test", tt.threshold, tt.openTimeout)
+			for i, s := range tt.steps {
+				var err error
+				switch s.op {
+				case allow:
+					err = b.Allow()
+				case success:
+					b.Success()
+				case failure:
+					b.Failure()
+				case release:
+					b.Release()
+				}
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: Allow() error = %v, want %v", i, err, s.wantErr)
+				}
+				if got := b.State(); got != s.wantState {
+					t.Fatalf("step %d: state = %v, want %v", i, got, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerOnStateChange(t *testing.T) {
+	var changes [][2]State
+	b := New("test", 1, 0).OnStateChange(func(from, to State) {
+		changes = append(changes, [2]State{from, to})
+	})
+
+	b.Failure()
+	_ = b.Allow()
+	b.Success()
+
+	want := [][2]State{{Closed, Open}, {Open, HalfOpen}, {HalfOpen, Closed}}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes %v, want %v", len(changes), changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %v, want %v", i, changes[i], want[i])
+		}
+	}
+}
@@ -14,6 +14,16 @@ type Config struct {
 	HTTPServer `yaml:"http-server"`
 	Database   `yaml:"db"`
 	Cache      `yaml:"cache"`
+	Import     `yaml:"import"`
+	Profile    `yaml:"profile"`
+	Avatar     `yaml:"avatar"`
+	BlobStore  `yaml:"blob-store"`
+	SignUp     `yaml:"sign-up"`
+	Notifier   `yaml:"notifier"`
+	Sessions   `yaml:"sessions"`
+	Account    `yaml:"account"`
+	Tracing    `yaml:"tracing"`
+	Health     `yaml:"health"`
 }
 
 type HTTPServer struct {
@@ -24,24 +34,210 @@ type HTTPServer struct {
 	ShutdownTimeout time.Duration `yaml:"shutdown-timeout" env-default:"5s"`
 	AccessTokenTTL  time.Duration `yaml:"access-token-ttl" env-default:"1h"`
 	RefreshTokenTTL time.Duration `yaml:"refresh-token-ttl" env-default:"24h"`
-	HashCost        int           `env:"HASH_COST" env-required:"true"`
-	JWTSecret       string        `env:"JWT_SECRET" env-required:"true"`
+	// ImpersonationTTL is the lifetime of tokens from POST /users/{id}/impersonate
+	ImpersonationTTL time.Duration `yaml:"impersonation-token-ttl" env-default:"15m"`
+	// MetricsAddress serves /metrics on its own listener, empty means on the main one
+	MetricsAddress string     `yaml:"metrics-address"`
+	RequestLog     RequestLog `yaml:"request-log"`
+	HashCost       int        `env:"HASH_COST" env-required:"true"`
+	JWTSecret      string     `env:"JWT_SECRET" env-required:"true"`
+}
+
+// RequestLog is about bodies in the "Request processed" log line, the line itself is always there.
+type RequestLog struct {
+	// off, errors (responses with status >= 400) or sampled
+	Body       string  `yaml:"body" env:"REQUEST_LOG_BODY" env-default:"errors"`
+	SampleRate float64 `yaml:"sample-rate" env-default:"0.01"` // share of requests logged with bodies in sampled mode
+	// MaxBodySize is captured per body, the rest is cut off
+	MaxBodySize int `yaml:"max-body-size" env-default:"4096"`
+	// RedactFields are json keys whose string values are masked, keys ending with them too (refresh_token)
+	RedactFields []string `yaml:"redact-fields" env-default:"token,password,email"`
 }
 
 type Database struct {
 	Host     string `yaml:"host" env-default:"localhost"`
 	Port     int    `yaml:"port" env-default:"5432"`
-	DBName   string `yaml:"name" env-required:"true"`
-	User     string `yaml:"users" env-default:"postgres"`
-	Password string `env:"DB_PASSWORD" env-required:"true"`
+	DBName   string `yaml:"name"`
+	User     string `yaml:"user" env-default:"postgres"`
+	Password string `env:"DB_PASSWORD"`
+	// disable, allow, prefer, require, verify-ca, verify-full
+	SSLMode         string `yaml:"sslmode" env-default:"disable"`
+	SSLRootCert     string `yaml:"sslrootcert"` // CA, needed for verify-ca and verify-full
+	SSLCert         string `yaml:"sslcert"`     // client cert
+	SSLKey          string `yaml:"sslkey"`
+	SearchPath      string `yaml:"search-path"`
+	ApplicationName string `yaml:"application-name" env-default:"test-task1"`
+	// Full DSN or postgres:// URL, when set all connection fields above are ignored
+	DSN  string `env:"DB_DSN"`
+	Pool DBPool `yaml:"pool"`
+	Tx   DBTx   `yaml:"tx"`
+	// Optional read replicas for user reads
+	Replicas DBReplicas `yaml:"replicas"`
+	// Apply pending migrations on start, replicas serialize on an advisory lock
+	AutoMigrate bool `yaml:"auto-migrate" env-default:"false"`
+}
+
+type DBPool struct {
+	MaxConns          int32         `yaml:"max-conns" env-default:"25"`
+	MinConns          int32         `yaml:"min-conns" env-default:"2"` // kept open even when idle
+	ConnMaxLifetime   time.Duration `yaml:"conn-max-lifetime" env-default:"30m"`
+	ConnMaxIdleTime   time.Duration `yaml:"conn-max-idle-time" env-default:"5m"`
+	HealthCheckPeriod time.Duration `yaml:"health-check-period" env-default:"1m"`
+}
+
+type DBReplicas struct {
+	// DSNs or postgres:// URLs, only their host, port, dbname, user and password are used,
+	// the rest (pool, sslmode and certificates, application_name, search_path) comes from the primary
+	DSNs                []string      `yaml:"dsns" env:"DB_REPLICA_DSNS" env-separator:";"`
+	HealthCheckInterval time.Duration `yaml:"health-check-interval" env-default:"5s"`
+	HealthCheckTimeout  time.Duration `yaml:"health-check-timeout" env-default:"1s"`
+	MaxLag              time.Duration `yaml:"max-lag" env-default:"10s"` // replica is taken out of rotation above it, 0 disables the check
+	// How long a user reads from primary after their own write
+	StickyWindow time.Duration `yaml:"sticky-window" env-default:"5s"`
 }
 
 type Cache struct {
-	Host     string        `yaml:"host" env-default:"localhost"`
-	Port     int           `yaml:"port" env-default:"6379"`
-	DBIndex  int           `yaml:"db-index" env-default:"0"`
-	TTL      time.Duration `yaml:"ttl" env-default:"10m"`
-	Password string        `env:"CACHE_PASSWORD" env-required:"TRUE"`
+	Enabled bool          `yaml:"enabled" env-default:"true"`
+	Host    string        `yaml:"host" env-default:"localhost"`
+	Port    int           `yaml:"port" env-default:"6379"`
+	DBIndex int           `yaml:"db-index" env-default:"0"`
+	TTL     time.Duration `yaml:"ttl" env-default:"10m"`
+	ListTTL time.Duration `yaml:"list-ttl" env-default:"1m"`
+	// Effective permissions, group changes invalidate them right away.
+	PermissionsTTL time.Duration `yaml:"permissions-ttl" env-default:"5m"`
+	Password       string        `env:"CACHE_PASSWORD"`
+	// Optional, base64 of 32 bytes. When set cached payloads are encrypted with AES-GCM.
+	EncryptionKey string     `env:"CACHE_ENCRYPTION_KEY"`
+	Local         LocalCache `yaml:"local"`
+	LoadLock      LoadLock   `yaml:"load-lock"`
+	Breaker       Breaker    `yaml:"breaker"`
+}
+
+// Breaker stops using redis after Threshold failures in a row for OpenTimeout.
+type Breaker struct {
+	Threshold   int           `yaml:"threshold" env-default:"5"`
+	OpenTimeout time.Duration `yaml:"open-timeout" env-default:"10s"`
+}
+
+// LocalCache is in-process tier in front of redis.
+type LocalCache struct {
+	Enabled bool          `yaml:"enabled" env-default:"false"`
+	Size    int           `yaml:"size" env-default:"10000"`
+	TTL     time.Duration `yaml:"ttl" env-default:"30s"`
+}
+
+// LoadLock lets only one replica reload a missed user, RefreshBeta > 0 enables early refresh.
+type LoadLock struct {
+	Enabled     bool          `yaml:"enabled" env-default:"false"`
+	TTL         time.Duration `yaml:"ttl" env-default:"2s"`
+	RefreshBeta float64       `yaml:"refresh-beta" env-default:"1"`
+}
+
+// DBTx is for transactions opened by the service layer.
+// Serialization failures and deadlocks are retried up to MaxRetries times.
+type DBTx struct {
+	// read committed, repeatable read, serializable
+	Isolation  string `yaml:"isolation" env-default:"read committed"`
+	MaxRetries int    `yaml:"max-retries" env-default:"3"`
+}
+
+// Import is for bulk user import, bodies over SyncMaxBytes run as a background job.
+type Import struct {
+	BatchSize    int           `yaml:"batch-size" env-default:"500"`
+	MaxBytes     int64         `yaml:"max-bytes" env-default:"104857600"`  // 100MB
+	SyncMaxBytes int64         `yaml:"sync-max-bytes" env-default:"65536"` // ~1000 rows, bcrypt makes rows slow
+	JobRetention time.Duration `yaml:"job-retention" env-default:"1h"`     // how long finished job status is kept
+}
+
+// Profile configures custom user attributes (metadata).
+type Profile struct {
+	// Path to JSON Schema file metadata must match, empty allows any object
+	MetadataSchema   string `yaml:"metadata-schema"`
+	MetadataMaxBytes int    `yaml:"metadata-max-bytes" env-default:"16384"`
+}
+
+// Avatar is for uploaded user pictures, the original is kept scaled down to OriginalSize
+// and every size in Sizes gets a square thumbnail.
+type Avatar struct {
+	MaxUploadBytes int64         `yaml:"max-upload-bytes" env-default:"5242880"` // 5MB
+	MaxPixels      int           `yaml:"max-pixels" env-default:"40000000"`      // decoded size limit
+	OriginalSize   int           `yaml:"original-size" env-default:"1024"`
+	Sizes          []int         `yaml:"sizes" env-default:"256,64"`
+	Quality        int           `yaml:"quality" env-default:"85"`                       // jpeg
+	PublicURL      string        `yaml:"public-url" env-default:"http://localhost:8081"` // avatar_url is built on it
+	CacheMaxAge    time.Duration `yaml:"cache-max-age" env-default:"5m"`                 // for unversioned avatar urls
+}
+
+// BlobStore is where uploaded files go, Driver is fs or s3.
+type BlobStore struct {
+	Driver string `yaml:"driver" env-default:"fs"`
+	Dir    string `yaml:"dir" env-default:"./data/blobs"`
+	S3     S3     `yaml:"s3"`
+}
+
+// S3 works with AWS and compatible servers, e.g. a local MinIO (make minio).
+type S3 struct {
+	Endpoint  string        `yaml:"endpoint" env-default:"http://localhost:9000"`
+	Region    string        `yaml:"region" env-default:"us-east-1"`
+	Bucket    string        `yaml:"bucket"`
+	PathStyle bool          `yaml:"path-style" env-default:"true"` // false for virtual hosted buckets
+	Timeout   time.Duration `yaml:"timeout" env-default:"10s"`
+	AccessKey string        `env:"S3_ACCESS_KEY"`
+	SecretKey string        `env:"S3_SECRET_KEY"`
+}
+
+// SignUp with Open false lets new users in by invitation only.
+// AcceptURL is the link sent in invitations, {token} is replaced with the invite token.
+type SignUp struct {
+	Open          bool          `yaml:"open" env:"OPEN_SIGN_UP" env-default:"true"`
+	InvitationTTL time.Duration `yaml:"invitation-ttl" env-default:"72h"`
+	AcceptURL     string        `yaml:"accept-url" env-default:"http://localhost:8081/invitations/{token}/accept"`
+}
+
+// Notifier delivers invitations, Driver is log (development only) or smtp.
+type Notifier struct {
+	Driver string `yaml:"driver" env-default:"log"`
+	SMTP   SMTP   `yaml:"smtp"`
+}
+
+type SMTP struct {
+	Host     string        `yaml:"host"`
+	Port     int           `yaml:"port" env-default:"587"`
+	From     string        `yaml:"from"`
+	Username string        `yaml:"username"`
+	Password string        `env:"SMTP_PASSWORD"`
+	Timeout  time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+// Sessions are checked on every authenticated request. A live session is not
+// checked again for CheckInterval. Revokes reach other replicas over redis pub/sub right away,
+// CheckInterval is how long they may take without redis or when a message is lost. 0 checks every time.
+type Sessions struct {
+	CheckInterval  time.Duration `yaml:"check-interval" env-default:"10s"`
+	CheckCacheSize int           `yaml:"check-cache-size" env-default:"10000"`
+}
+
+// Account deleted with DELETE /me is purged DeletionGrace later, logging in before cancels it.
+// Due accounts are looked for every PurgeInterval.
+type Account struct {
+	DeletionGrace time.Duration `yaml:"deletion-grace" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge-interval" env-default:"1h"`
+}
+
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check-timeout" env-default:"2s"` // per dependency on GET /readyz
+	// ShutdownDelay keeps serving after /readyz went not ready, so the load balancer notices before the server stops
+	ShutdownDelay time.Duration `yaml:"shutdown-delay" env-default:"0s"`
+}
+
+type Tracing struct {
+	// none, stdout (pretty printed spans, local only) or otlp
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// OTLP/HTTP collector host:port, OTEL_EXPORTER_OTLP_ENDPOINT works too
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	Insecure    bool    `yaml:"insecure"` // plain http to the collector
+	ServiceName string  `yaml:"service-name" env-default:"test-task1"`
+	SampleRatio float64 `yaml:"sample-ratio" env-default:"1"` // of traces started here, incoming sampled ones are always kept
 }
 
 func LoadConfig() (*Config, error) {
@@ -0,0 +1,140 @@
+package highlight
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Words at least this similar to a query word count as its misspelling, same as pg_trgm.similarity_threshold.
+const similarityThreshold = 0.3
+
+// Range is a byte range [Start, End) of text.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Match finds what to highlight in text for each query word: its case-insensitive occurrences,
+// or when there are none, text words similar to it (typos). Ranges are sorted and don't overlap.
+func Match(text, query string) []Range {
+	runes, offsets := lowerRunes(text)
+	words := splitWords(runes)
+
+	var ranges []Range
+	for _, q := range strings.FieldsFunc(strings.ToLower(query), isSeparator) {
+		qr := []rune(q)
+		found := false
+		for i := 0; i+len(qr) <= len(runes); i++ {
+			if slices.Equal(runes[i:i+len(qr)], qr) {
+				ranges = append(ranges, Range{offsets[i], offsets[i+len(qr)]})
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		for _, w := range words {
+			if Similarity(string(runes[w.Start:w.End]), q) >= similarityThreshold {
+				ranges = append(ranges, Range{offsets[w.Start], offsets[w.End]})
+			}
+		}
+	}
+	return merge(ranges)
+}
+
+// HTML escapes text and wraps matched fragments in <mark>.
+func HTML(text, query string) string {
+	var b strings.Builder
+	last := 0
+	for _, r := range Match(text, query) {
+		b.WriteString(html.EscapeString(text[last:r.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[r.Start:r.End]))
+		b.WriteString("</mark>")
+		last = r.End
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// Similarity is pg_trgm similarity: shared trigrams of padded words over all trigrams.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(strings.ToLower(a)), trigrams(strings.ToLower(b))
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(word string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(word, isSeparator) {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// lowerRunes lowercases text keeping byte offset of every rune, offsets has one extra for the end.
+func lowerRunes(text string) ([]rune, []int) {
+	runes := make([]rune, 0, len(text))
+	offsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		runes = append(runes, unicode.ToLower(r))
+		offsets = append(offsets, i)
+	}
+	return runes, append(offsets, len(text))
+}
+
+// splitWords returns rune ranges of words, email "john.doe@mail.com" has words john, doe, mail, com.
+func splitWords(runes []rune) []Range {
+	var words []Range
+	start := -1
+	for i, r := range runes {
+		if isSeparator(r) {
+			if start >= 0 {
+				words = append(words, Range{start, i})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, Range{start, len(runes)})
+	}
+	return words
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func merge(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	slices.SortFunc(ranges, func(a, b Range) int { return a.Start - b.Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			last.End = max(last.End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
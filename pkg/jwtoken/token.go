@@ -8,20 +8,40 @@ import (
 	"time"
 )
 
-func GenerateToken(id int, email string, secret []byte, ttl time.Duration) (string, error) {
+// Claims are what the app puts in access tokens.
+type Claims struct {
+	UserID int
+	Email  string
+	OrgID  int // 0 for tokens without organization
+	// SessionID is "sid", the session the token was issued for
+	SessionID string
+	// ActorID is who really acts when UserID is impersonated, "act" claim like in RFC 8693
+	ActorID int
+}
+
+func GenerateToken(c Claims, secret []byte, ttl time.Duration) (string, error) {
 	claims := jwt.MapClaims{
-		"user_id": id,
-		"email":   email,
+		"user_id": c.UserID,
+		"email":   c.Email,
 		"exp":     time.Now().Add(ttl).Unix(),
 		"iat":     time.Now().Unix(),
 	}
+	if c.OrgID != 0 {
+		claims["org_id"] = c.OrgID
+	}
+	if c.SessionID != "" {
+		claims["sid"] = c.SessionID
+	}
+	if c.ActorID != 0 {
+		claims["act"] = map[string]any{"user_id": c.ActorID}
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return token.SignedString(secret)
 }
 
-func ParseToken(tokenString string, secret []byte) (int, error) {
+func ParseToken(tokenString string, secret []byte) (*Claims, error) {
 	t, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
@@ -29,18 +49,31 @@ func ParseToken(tokenString string, secret []byte) (int, error) {
 		return secret, nil
 	})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	claims, ok := t.Claims.(jwt.MapClaims)
 	if !ok || !t.Valid {
-		return 0, jwt.ErrInvalidKey
+		return nil, jwt.ErrInvalidKey
 	}
 
 	id, ok := claims["user_id"].(float64)
 	if !ok {
-		return 0, jwt.ErrInvalidKey
+		return nil, jwt.ErrInvalidKey
+	}
+	c := &Claims{UserID: int(id)}
+	c.Email, _ = claims["email"].(string)
+	if org, ok := claims["org_id"].(float64); ok {
+		c.OrgID = int(org)
+	}
+	c.SessionID, _ = claims["sid"].(string)
+	if act, ok := claims["act"].(map[string]any); ok {
+		id, ok := act["user_id"].(float64)
+		if !ok {
+			return nil, jwt.ErrInvalidKey
+		}
+		c.ActorID = int(id)
 	}
-	return int(id), nil
+	return c, nil
 }
 
 func ExtractTokenFromRequest(r *http.Request) (string, error) {
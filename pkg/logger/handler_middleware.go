@@ -2,6 +2,7 @@ package logger
 
 import (
 	"context"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 )
 
@@ -23,8 +24,17 @@ func (m *SlogHandlerMiddleware) Enabled(ctx context.Context, rec slog.Level) boo
 func (m *SlogHandlerMiddleware) Handle(ctx context.Context, rec slog.Record) error {
 	if c, ok := ctx.Value(key).(logCtx); ok {
 		rec.Add("UserID", c.UserID)
+		if c.OrgID != "" {
+			rec.Add("OrgID", c.OrgID)
+		}
+		if c.ActorID != "" {
+			rec.Add("ActorID", c.ActorID)
+		}
 		rec.Add("RequestID", c.RequestID)
 	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.Add("TraceID", sc.TraceID().String(), "SpanID", sc.SpanID().String())
+	}
 	return m.next.Handle(ctx, rec)
 }
 
@@ -4,7 +4,9 @@ import "context"
 
 type logCtx struct {
 	UserID    string
+	OrgID     string
 	RequestID string
+	ActorID   string // set when the user is impersonated
 }
 
 type keyType int
@@ -26,3 +28,19 @@ func WithLogRequestID(ctx context.Context, requestID string) context.Context {
 	}
 	return context.WithValue(ctx, key, logCtx{RequestID: requestID})
 }
+
+func WithLogOrgID(ctx context.Context, orgID string) context.Context {
+	if c, ok := ctx.Value(key).(logCtx); ok {
+		c.OrgID = orgID
+		return context.WithValue(ctx, key, c)
+	}
+	return context.WithValue(ctx, key, logCtx{OrgID: orgID})
+}
+
+func WithLogActorID(ctx context.Context, actorID string) context.Context {
+	if c, ok := ctx.Value(key).(logCtx); ok {
+		c.ActorID = actorID
+		return context.WithValue(ctx, key, c)
+	}
+	return context.WithValue(ctx, key, logCtx{ActorID: actorID})
+}
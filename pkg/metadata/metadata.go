@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Used when no schema is configured, any object goes.
+const permissiveSchema = `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object"}`
+
+const schemaURL = "metadata.schema.json"
+
+var ErrTooLarge = errors.New("metadata is too large")
+
+// Validator checks user metadata against a JSON Schema of the deployment.
+type Validator struct {
+	schema   *jsonschema.Schema
+	raw      json.RawMessage
+	maxBytes int
+}
+
+// NewValidator loads schema from path, empty path allows any object.
+// maxBytes limits encoded metadata size, 0 means no limit.
+func NewValidator(path string, maxBytes int) (*Validator, error) {
+	raw := []byte(permissiveSchema)
+	if path != "" {
+		var err error
+		if raw, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("read metadata schema: %w", err)
+		}
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	compiler.LoadURL = func(s string) (_ io.ReadCloser, err error) { // no remote $ref
+		return nil, fmt.Errorf("loading %s is not allowed, metadata schema must be self-contained", s)
+	}
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("parse metadata schema: %w", err)
+	}
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("compile metadata schema: %w", err)
+	}
+
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, raw); err != nil {
+		return nil, err
+	}
+	return &Validator{schema: schema, raw: compact.Bytes(), maxBytes: maxBytes}, nil
+}
+
+// Validate returns ErrTooLarge or a readable list of violations, nil metadata is an empty object.
+func (v *Validator) Validate(metadata map[string]any) error {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	if v.maxBytes > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		if len(data) > v.maxBytes {
+			return fmt.Errorf("%w: %d bytes, limit is %d", ErrTooLarge, len(data), v.maxBytes)
+		}
+	}
+
+	// schema validator wants values as encoding/json decodes them
+	doc, err := normalize(metadata)
+	if err != nil {
+		return err
+	}
+	err = v.schema.Validate(doc)
+	var ve *jsonschema.ValidationError
+	if errors.As(err, &ve) {
+		return errors.New(describe(ve))
+	}
+	return err
+}
+
+// Schema is the schema document, for clients that build forms from it.
+func (v *Validator) Schema() json.RawMessage {
+	return v.raw
+}
+
+func normalize(metadata map[string]any) (any, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// describe lists leaf causes as "/path: message", root path is shown as "/".
+func describe(ve *jsonschema.ValidationError) string {
+	var leaves []string
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			location := e.InstanceLocation
+			if location == "" {
+				location = "/"
+			}
+			leaves = append(leaves, location+": "+e.Message)
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+	sort.Strings(leaves)
+	return strings.Join(leaves, "; ")
+}
@@ -0,0 +1,53 @@
+package signedtoken
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	secret := []byte("secret")
+	key := Key(secret, "avatar")
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	valid := New(key, "42", now.Add(time.Hour))
+	id, rest, _ := strings.Cut(valid, ".")
+	exp, sig, _ := strings.Cut(rest, ".")
+
+	tests := []struct {
+		name    string
+		key     []byte
+		token   string
+		now     time.Time
+		wantID  string
+		wantErr error
+	}{
+		{name: "valid", key: key, token: valid, now: now, wantID: "42"},
+		{name: "just before expiry", key: key, token: valid, now: now.Add(time.Hour - time.Second), wantID: "42"},
+		{name: "at expiry", key: key, token: valid, now: now.Add(time.Hour), wantErr: ErrExpired},
+		{name: "after expiry", key: key, token: valid, now: now.Add(2 * time.Hour), wantErr: ErrExpired},
+		{name: "other id", key: key, token: "43." + exp + "." + sig, now: now, wantErr: ErrInvalid},
+		{name: "extended expiry", key: key, token: id + "." + strconv.FormatInt(now.Add(24*time.Hour).Unix(), 10) + "." + sig, now: now, wantErr: ErrInvalid},
+		{name: "tampered signature", key: key, token: id + "." + exp + "." + strings.ToUpper(sig), now: now, wantErr: ErrInvalid},
+		{name: "missing signature", key: key, token: id + "." + exp, now: now, wantErr: ErrInvalid},
+		{name: "no dots", key: key, token: "42", now: now, wantErr: ErrInvalid},
+		{name: "empty", key: key, token: "", now: now, wantErr: ErrInvalid},
+		{name: "other purpose", key: Key(secret, "invite"), token: valid, now: now, wantErr: ErrInvalid},
+		{name: "other secret", key: Key([]byte("other"), "avatar"), token: valid, now: now, wantErr: ErrInvalid},
+		{name: "signed payload without expiry", key: key, token: "42." + sign(key, "42"), now: now, wantErr: ErrInvalid},
+		{name: "signed payload with bad expiry", key: key, token: "42.soon." + sign(key, "42.soon"), now: now, wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.key, tt.token, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.wantID {
+				t.Errorf("Parse() = %q, want %q", got, tt.wantID)
+			}
+		})
+	}
+}
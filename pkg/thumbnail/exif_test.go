@@ -0,0 +1,178 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+const tagMake, tagOrientation = 0x010F, 0x0112
+
+// exifSegment is an APP1 segment with one IFD of SHORT entries, tag -> value.
+func exifSegment(order binary.AppendByteOrder, entries ...[2]uint16) []byte {
+	tiff := []byte("II")
+	if order == binary.BigEndian {
+		tiff = []byte("MM")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, uint16(len(entries)))
+	for _, e := range entries {
+		tiff = order.AppendUint16(tiff, e[0])
+		tiff = order.AppendUint16(tiff, 3) // SHORT
+		tiff = order.AppendUint32(tiff, 1)
+		tiff = order.AppendUint16(tiff, e[1])
+		tiff = append(tiff, 0, 0)
+	}
+	tiff = order.AppendUint32(tiff, 0) // no next IFD
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	return segment(0xE1, payload)
+}
+
+func segment(marker byte, payload []byte) []byte {
+	s := []byte{0xFF, marker}
+	s = binary.BigEndian.AppendUint16(s, uint16(len(payload)+2))
+	return append(s, payload...)
+}
+
+// withSegments inserts segments right after SOI of jpeg.
+func withSegments(jpeg []byte, segments ...[]byte) []byte {
+	out := append([]byte(nil), jpeg[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, jpeg[2:]...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExifOrientation(t *testing.T) {
+	plain := encodeJPEG(t, image.NewGray(image.Rect(0, 0, 4, 4)))
+	jfif := segment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	overcounted := exifSegment(binary.LittleEndian, [2]uint16{tagMake, 1})
+	binary.LittleEndian.PutUint16(overcounted[4+6+8:], 5) // 5 entries claimed, 1 there
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "no exif", data: plain, want: 1},
+		{name: "little endian", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{tagOrientation, 6})), want: 6},
+		{name: "big endian", data: withSegments(plain, exifSegment(binary.BigEndian, [2]uint16{tagOrientation, 8})), want: 8},
+		{name: "after jfif", data: withSegments(plain, jfif, exifSegment(binary.BigEndian, [2]uint16{tagOrientation, 3})), want: 3},
+		{name: "not the first tag", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{tagMake, 7}, [2]uint16{tagOrientation, 5})), want: 5},
+		{name: "no orientation tag", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{tagMake, 6})), want: 1},
+		{name: "out of range", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{tagOrientation, 9})), want: 1},
+		{name: "zero", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{tagOrientation, 0})), want: 1},
+		{name: "app1 that is not exif", data: withSegments(plain, segment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"))), want: 1},
+		{name: "truncated segment", data: append([]byte{0xFF, 0xD8}, exifSegment(binary.LittleEndian, [2]uint16{tagOrientation, 6})[:12]...), want: 1},
+		{name: "entry count past the ifd", data: withSegments(plain, overcounted), want: 1},
+		{name: "not a jpeg", data: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+		{name: "empty", data: nil, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.data); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// stored image, 3x2:
+	//   A B C
+	//   D E F
+	const A, B, C, D, E, F = 10, 20, 30, 40, 50, 60
+	src := image.NewGray(image.Rect(5, 5, 8, 7)) // bounds not at origin
+	for i, v := range []uint8{A, B, C, D, E, F} {
+		src.SetGray(5+i%3, 5+i/3, color.Gray{Y: v})
+	}
+
+	tests := []struct {
+		orientation int
+		want        [][]uint8 // rows of the upright image
+	}{
+		{orientation: 1, want: [][]uint8{{A, B, C}, {D, E, F}}},
+		{orientation: 2, want: [][]uint8{{C, B, A}, {F, E, D}}},   // mirrored
+		{orientation: 3, want: [][]uint8{{F, E, D}, {C, B, A}}},   // rotated 180
+		{orientation: 4, want: [][]uint8{{D, E, F}, {A, B, C}}},   // flipped
+		{orientation: 5, want: [][]uint8{{A, D}, {B, E}, {C, F}}}, // transposed
+		{orientation: 6, want: [][]uint8{{D, A}, {E, B}, {F, C}}}, // rotated 90 clockwise
+		{orientation: 7, want: [][]uint8{{F, C}, {E, B}, {D, A}}}, // transversed
+		{orientation: 8, want: [][]uint8{{C, F}, {B, E}, {A, D}}}, // rotated 90 counterclockwise
+		{orientation: 0, want: [][]uint8{{A, B, C}, {D, E, F}}},   // unknown is kept
+	}
+	for _, tt := range tests {
+		img := orient(src, tt.orientation)
+		b := img.Bounds()
+		if b.Dx() != len(tt.want[0]) || b.Dy() != len(tt.want) {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), len(tt.want[0]), len(tt.want))
+			continue
+		}
+		for y, row := range tt.want {
+			for x, want := range row {
+				if got := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y; got != want {
+					t.Errorf("orientation %d: pixel (%d,%d) = %d, want %d", tt.orientation, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeAppliesOrientation(t *testing.T) {
+	// 16x8, left half black and right half white
+	src := image.NewGray(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 8; x < 16; x++ {
+			src.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	data := encodeJPEG(t, src)
+
+	tests := []struct {
+		name        string
+		orientation uint16
+		wantSize    image.Point
+		dark        image.Point // a pixel that must stay black
+		light       image.Point // and one that must stay white
+	}{
+		{name: "none", orientation: 1, wantSize: image.Pt(16, 8), dark: image.Pt(2, 4), light: image.Pt(13, 4)},
+		{name: "rotated 180", orientation: 3, wantSize: image.Pt(16, 8), dark: image.Pt(13, 4), light: image.Pt(2, 4)},
+		{name: "rotated 90 clockwise", orientation: 6, wantSize: image.Pt(8, 16), dark: image.Pt(4, 2), light: image.Pt(4, 13)},
+		{name: "rotated 90 counterclockwise", orientation: 8, wantSize: image.Pt(8, 16), dark: image.Pt(4, 13), light: image.Pt(4, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, format, err := Decode(withSegments(data, exifSegment(binary.BigEndian, [2]uint16{tagOrientation, tt.orientation})), 1<<20)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if format != "jpeg" {
+				t.Errorf("format = %s, want jpeg", format)
+			}
+			b := img.Bounds()
+			if b.Size() != tt.wantSize {
+				t.Fatalf("size = %v, want %v", b.Size(), tt.wantSize)
+			}
+			if y := color.GrayModel.Convert(img.At(b.Min.X+tt.dark.X, b.Min.Y+tt.dark.Y)).(color.Gray).Y; y > 64 {
+				t.Errorf("pixel %v = %d, want dark", tt.dark, y)
+			}
+			if y := color.GrayModel.Convert(img.At(b.Min.X+tt.light.X, b.Min.Y+tt.light.Y)).(color.Gray).Y; y < 192 {
+				t.Errorf("pixel %v = %d, want light", tt.light, y)
+			}
+		})
+	}
+}
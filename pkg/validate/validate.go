@@ -1,9 +1,21 @@
 package validate
 
-import "github.com/go-playground/validator/v10"
+import (
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+)
 
 func New() *validator.Validate {
 	var validate *validator.Validate
 	validate = validator.New()
+	// errors name fields as clients send them
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
 	return validate
 }